@@ -2,9 +2,15 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
+
+	"codeberg.org/codeberg/pages/server/database"
 )
 
 var Certs = &cli.Command{
@@ -21,6 +27,98 @@ var Certs = &cli.Command{
 			Usage:  "remove a certificate from the database",
 			Action: removeCert,
 		},
+		{
+			Name:      "show",
+			Usage:     "show details of a certificate in the database",
+			ArgsUsage: "<domain>",
+			Action:    showCert,
+		},
+		{
+			Name:      "export",
+			Usage:     "export a certificate from the database to PEM or PKCS#12 files",
+			ArgsUsage: "<domain>",
+			Action:    exportCert,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "export format, valid options are \"pem\" and \"pkcs12\"",
+					Value: "pem",
+				},
+				&cli.StringFlag{
+					Name:  "out-dir",
+					Usage: "directory to write the exported files to",
+					Value: ".",
+				},
+				&cli.StringFlag{
+					Name:    "password",
+					Usage:   "password to protect the PKCS#12 file with",
+					EnvVars: []string{"PKCS12_PASSWORD"},
+				},
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "import a certificate and its private key from PEM files into the database",
+			ArgsUsage: "<domain> <cert.pem> <key.pem>",
+			Action:    importCert,
+		},
+		{
+			Name:   "migrate",
+			Usage:  "copy all certificates from one database to another",
+			Action: migrateCerts,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "from-type",
+					Usage:    "database driver of the source database",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "from-conn",
+					Usage:    "connection of the source database",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "to-type",
+					Usage:    "database driver of the target database",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "to-conn",
+					Usage:    "connection of the target database",
+					Required: true,
+				},
+				&cli.IntFlag{
+					Name:  "page-size",
+					Usage: "number of certificates copied at once",
+					Value: 100,
+				},
+				&cli.BoolFlag{
+					Name:  "overwrite",
+					Usage: "allow migrating into a target database that already contains certificates",
+				},
+			},
+		},
+		{
+			Name:   "rotate-key",
+			Usage:  "re-encrypt all private keys and deploy tokens with the current encryption key, this also encrypts plaintext ones",
+			Action: rotateEncryptionKey,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "old-key",
+					Usage:   "base64 encoded previous encryption key, can be given multiple times",
+					EnvVars: []string{"DB_ENCRYPTION_OLD_KEYS"},
+				},
+				&cli.StringSliceFlag{
+					Name:  "old-key-file",
+					Usage: "file containing a previous encryption key, can be given multiple times",
+				},
+				&cli.IntFlag{
+					Name:  "page-size",
+					Usage: "number of certificates re-encrypted at once",
+					Value: 100,
+				},
+			},
+		},
 	},
 	Flags: CertStorageFlags,
 }
@@ -37,12 +135,69 @@ func listCerts(ctx *cli.Context) error {
 		return err
 	}
 
-	fmt.Printf("Domain\tValidTill\n\n")
+	fmt.Printf("Domain\tValidTill\tIssuer\n\n")
 	for _, cert := range items {
-		fmt.Printf("%s\t%s\n",
+		fmt.Printf("%s\t%s\t%s\n",
 			cert.Domain,
-			time.Unix(cert.ValidTill, 0).Format(time.RFC3339))
+			time.Unix(cert.ValidTill, 0).Format(time.RFC3339),
+			cert.Issuer)
+	}
+	return nil
+}
+
+func showCert(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		return fmt.Errorf("'certs show' requires exactly one domain as an argument")
+	}
+
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	return printCert(os.Stdout, certDB, ctx.Args().First())
+}
+
+// printCert writes the details and the renewal state of the cert of domain to w.
+func printCert(w io.Writer, certDB database.CertDB, domain string) error {
+	res, err := certDB.Get(domain)
+	if err != nil {
+		return err
+	}
+	info, err := database.ParseCertInfo(res)
+	if err != nil {
+		return err
+	}
+
+	// only certs with a scheduled renewal have a renewal state
+	pending, err := certDB.PendingRenewals(math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	renewal := &database.Cert{}
+	for _, cert := range pending {
+		if cert.Domain == res.Domain {
+			renewal = cert
+		}
+	}
+
+	fmt.Fprintf(w, "Domain:\t\t%s\n", info.Domain)
+	fmt.Fprintf(w, "Subject:\t%s\n", info.Subject)
+	fmt.Fprintf(w, "Issuer:\t\t%s\n", info.Issuer)
+	fmt.Fprintf(w, "SANs:\t\t%s\n", strings.Join(info.SANs, ", "))
+	fmt.Fprintf(w, "Serial:\t\t%s\n", info.Serial)
+	fmt.Fprintf(w, "KeyType:\t%s\n", info.KeyType)
+	fmt.Fprintf(w, "NotBefore:\t%s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "NotAfter:\t%s\n", info.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "CertURL:\t%s\n", info.CertURL)
+	if renewal.NextAttempt == 0 {
+		fmt.Fprintf(w, "RenewalRetry:\tnone scheduled\n")
+		return nil
 	}
+	fmt.Fprintf(w, "RenewalRetry:\t%s\n", time.Unix(renewal.NextAttempt, 0).Format(time.RFC3339))
+	fmt.Fprintf(w, "Attempts:\t%d\n", renewal.Attempts)
+	fmt.Fprintf(w, "LastError:\t%s\n", renewal.LastError)
 	return nil
 }
 
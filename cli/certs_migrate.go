@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func migrateCerts(ctx *cli.Context) error {
+	// both databases use the same key, rotate the key afterwards if needed
+	encryption, err := keyEncryption(ctx.String("db-encryption-key"), ctx.String("db-encryption-key-file"))
+	if err != nil {
+		return err
+	}
+
+	from, closeFrom, err := openCertDB(ctx.String("from-type"), ctx.String("from-conn"), encryption)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer closeFrom()
+
+	to, closeTo, err := openCertDB(ctx.String("to-type"), ctx.String("to-conn"), encryption)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	defer closeTo()
+
+	existing, err := to.Items(0, 0)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	if len(existing) > 0 && !ctx.Bool("overwrite") {
+		return fmt.Errorf("target database already contains %d certificates, use --overwrite to migrate anyway", len(existing))
+	}
+
+	migrated, err := copyCerts(from, to, ctx.Int("page-size"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d certificates\n", len(migrated))
+
+	// verify every migrated cert arrived
+	targetCerts, err := to.Items(0, 0)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	targetDomains := make(map[string]bool, len(targetCerts))
+	for _, cert := range targetCerts {
+		targetDomains[cert.Domain] = true
+	}
+	missing := 0
+	for _, domain := range migrated {
+		if !targetDomains[domain] {
+			fmt.Printf("Certificate for %s is missing in the target database\n", domain)
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("verification failed: %d of %d certificates are missing in the target database", missing, len(migrated))
+	}
+	fmt.Printf("Verified: source has %d, target has %d certificates\n", len(migrated), len(targetCerts))
+
+	tokens, err := copyDeployTokens(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d deploy tokens\n", tokens)
+
+	takedowns, err := copyTakedowns(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d takedowns\n", takedowns)
+
+	quotas, err := copyQuotas(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d certificate quotas\n", quotas)
+	return nil
+}
+
+// copyCerts streams all certs page by page from one database to the other, returning the copied domains.
+func copyCerts(from, to database.CertDB, pageSize int) ([]string, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size has to be positive")
+	}
+
+	var migrated []string
+	for page := 1; ; page++ {
+		certs, err := from.Items(page, pageSize)
+		if err != nil {
+			return migrated, fmt.Errorf("source: %w", err)
+		}
+
+		for _, cert := range certs {
+			if err := to.PutCert(cert); err != nil {
+				return migrated, fmt.Errorf("could not migrate certificate for %s: %w", cert.Domain, err)
+			}
+			migrated = append(migrated, cert.Domain)
+		}
+
+		// pages can be shorter than pageSize if certs were skipped, so only an empty page ends the listing
+		if len(certs) == 0 {
+			return migrated, nil
+		}
+	}
+}
+
+// copyDeployTokens copies the deploy tokens of all repositories, returning the number of copied tokens.
+func copyDeployTokens(from, to database.CertDB) (int, error) {
+	tokens, err := from.DeployTokens()
+	if err != nil {
+		return 0, fmt.Errorf("source: %w", err)
+	}
+	for i, token := range tokens {
+		if err := to.PutDeployToken(token); err != nil {
+			return i, fmt.Errorf("could not migrate deploy token of %s/%s: %w", token.Owner, token.Repo, err)
+		}
+	}
+	return len(tokens), nil
+}
+
+// copyTakedowns copies all takedowns, returning the number of copied takedowns.
+func copyTakedowns(from, to database.CertDB) (int, error) {
+	takedowns, err := from.Takedowns()
+	if err != nil {
+		return 0, fmt.Errorf("source: %w", err)
+	}
+	for i, takedown := range takedowns {
+		if err := to.PutTakedown(takedown); err != nil {
+			return i, fmt.Errorf("could not migrate takedown of %s %s: %w", takedown.Kind, takedown.Target, err)
+		}
+	}
+	return len(takedowns), nil
+}
+
+// copyQuotas copies the certificate quotas of all owners, returning the number of copied quotas.
+func copyQuotas(from, to database.CertDB) (int, error) {
+	quotas, err := from.Quotas()
+	if err != nil {
+		return 0, fmt.Errorf("source: %w", err)
+	}
+	for i, quota := range quotas {
+		if err := to.PutQuota(quota); err != nil {
+			return i, fmt.Errorf("could not migrate certificate quota of %s: %w", quota.Owner, err)
+		}
+	}
+	return len(quotas), nil
+}
+
+func rotateEncryptionKey(ctx *cli.Context) error {
+	var oldKeys [][]byte
+	for _, encoded := range ctx.StringSlice("old-key") {
+		key, err := database.ParseEncryptionKey(encoded)
+		if err != nil {
+			return fmt.Errorf("old key: %w", err)
+		}
+		oldKeys = append(oldKeys, key)
+	}
+	for _, file := range ctx.StringSlice("old-key-file") {
+		key, err := database.ReadEncryptionKey("", file)
+		if err != nil {
+			return fmt.Errorf("old key: %w", err)
+		}
+		oldKeys = append(oldKeys, key)
+	}
+
+	encryption, err := keyEncryption(ctx.String("db-encryption-key"), ctx.String("db-encryption-key-file"), oldKeys...)
+	if err != nil {
+		return err
+	}
+	if encryption == nil {
+		return fmt.Errorf("no new encryption key set (DB_ENCRYPTION_KEY or DB_ENCRYPTION_KEY_FILE)")
+	}
+
+	certDB, closeFn, err := openCertDB(ctx.String("db-type"), ctx.String("db-conn"), encryption)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	// every cert is read with any of the keys and written back encrypted with the new one
+	rotated, err := copyCerts(certDB, certDB, ctx.Int("page-size"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d private keys with the new key\n", len(rotated))
+
+	tokens, err := copyDeployTokens(certDB, certDB)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d deploy tokens with the new key\n", tokens)
+	return nil
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestCopyQuotas(t *testing.T) {
+	from, err := database.NewCertDB("file", t.TempDir(), nil)
+	assert.NoError(t, err)
+	defer from.Close()
+	to, err := database.NewCertDB("sqlite3", filepath.Join(t.TempDir(), "certs.sqlite"), nil)
+	assert.NoError(t, err)
+	defer to.Close()
+
+	for _, owner := range []string{"example", "example", "other"} {
+		_, ok, err := from.TakeQuota(owner, 10, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	copied, err := copyQuotas(from, to)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, copied)
+
+	expected, err := from.Quotas()
+	assert.NoError(t, err)
+	migrated, err := to.Quotas()
+	assert.NoError(t, err)
+	assert.EqualValues(t, expected, migrated)
+
+	// the owners keep their used certificates
+	quota, ok, err := to.TakeQuota("example", 3, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, quota.Used)
+	_, ok, err = to.TakeQuota("example", 3, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
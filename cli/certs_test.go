@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestPrintCert(t *testing.T) {
+	certDB, err := database.NewCertDB("file", t.TempDir(), nil)
+	assert.NoError(t, err)
+	defer certDB.Close()
+
+	certPEM, keyPEM := newTestCertPEM(t, []string{"example.com", "www.example.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.NoError(t, certDB.Put("example.com", "", &certificate.Resource{Domain: "example.com", Certificate: certPEM, PrivateKey: keyPEM}))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printCert(out, certDB, "example.com"))
+	assert.Contains(t, out.String(), "Domain:\t\texample.com\n")
+	assert.Contains(t, out.String(), "SANs:\t\texample.com, www.example.com\n")
+	assert.Contains(t, out.String(), "KeyType:\tEC256\n")
+	assert.Contains(t, out.String(), "RenewalRetry:\tnone scheduled\n")
+
+	retry := time.Now().Add(6 * time.Hour).Truncate(time.Second)
+	assert.NoError(t, certDB.UpdateRenewal(&database.Cert{Domain: "example.com", NextAttempt: retry.Unix(), Attempts: 2, LastError: "rate limited"}))
+	out.Reset()
+	assert.NoError(t, printCert(out, certDB, "example.com"))
+	assert.Contains(t, out.String(), "RenewalRetry:\t"+retry.Format(time.RFC3339)+"\n")
+	assert.Contains(t, out.String(), "Attempts:\t2\n")
+	assert.Contains(t, out.String(), "LastError:\trate limited\n")
+
+	assert.Error(t, printCert(out, certDB, "example.org"))
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/urfave/cli/v2"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func exportCert(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		return fmt.Errorf("'certs export' requires exactly one domain as an argument")
+	}
+	domain := ctx.Args().First()
+
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	res, err := certDB.Get(domain)
+	if err != nil {
+		return err
+	}
+
+	// use the same file names as lego does
+	baseName := filepath.Join(ctx.String("out-dir"), strings.ReplaceAll(res.Domain, "*", "_"))
+
+	switch ctx.String("format") {
+	case "pem":
+		files := []struct {
+			name    string
+			content []byte
+			perm    os.FileMode
+		}{
+			{baseName + ".crt", res.Certificate, 0o644},
+			{baseName + ".issuer.crt", res.IssuerCertificate, 0o644},
+			{baseName + ".key", res.PrivateKey, 0o600},
+		}
+		for _, file := range files {
+			if len(file.content) == 0 {
+				continue
+			}
+			if err := os.WriteFile(file.name, file.content, file.perm); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %s\n", file.name)
+		}
+
+	case "pkcs12":
+		pfx, err := encodePKCS12(res, ctx.String("password"))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(baseName+".p12", pfx, 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", baseName+".p12")
+
+	default:
+		return fmt.Errorf("unknown export format %q", ctx.String("format"))
+	}
+
+	return nil
+}
+
+func encodePKCS12(res *certificate.Resource, password string) ([]byte, error) {
+	// unlike certcrypto.ParsePEMPrivateKey this doesn't panic on a broken key
+	tlsCertificate, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("certificate and key do not match: %w", err)
+	}
+	certs, err := certcrypto.ParsePEMBundle(res.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	return pkcs12.Modern.Encode(tlsCertificate.PrivateKey, certs[0], certs[1:], password)
+}
+
+func importCert(ctx *cli.Context) error {
+	if ctx.Args().Len() != 3 {
+		return fmt.Errorf("'certs import' requires a domain, a certificate file and a key file as arguments")
+	}
+	domain := strings.ToLower(ctx.Args().Get(0))
+
+	certPEM, err := os.ReadFile(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(ctx.Args().Get(2))
+	if err != nil {
+		return err
+	}
+
+	res, err := newImportedResource(domain, certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	// the certificate is stored next to certificates with other key types of the same domain
+	info, err := database.ParseCertInfo(res)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Importing %s certificate for %s into the database...\n", info.KeyType, res.Domain)
+	return certDB.Put(database.NameWithKeyType(strings.TrimPrefix(domain, "*"), info.KeyType), "", res)
+}
+
+// newImportedResource validates that the certificate matches the key, is currently valid and covers domain.
+// Wildcard certificates can be imported as "*.example.com" or ".example.com".
+func newImportedResource(domain string, certPEM, keyPEM []byte) (*certificate.Resource, error) {
+	tlsCertificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificate and key do not match: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(tlsCertificate.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate is only valid from %s till %s", leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	certDomain := domain
+	hostname := domain
+	if strings.HasPrefix(domain, ".") || strings.HasPrefix(domain, "*.") {
+		certDomain = "*." + strings.TrimLeft(domain, "*.")
+		// any name directly below the wildcard has to be covered
+		hostname = "wildcard-check" + strings.TrimPrefix(certDomain, "*")
+	}
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		return nil, fmt.Errorf("certificate is not valid for %q: %w", certDomain, err)
+	}
+
+	// everything after the leaf certificate is the issuer chain
+	var issuerPEM []byte
+	for _, der := range tlsCertificate.Certificate[1:] {
+		issuerPEM = append(issuerPEM, certcrypto.PEMEncode(certcrypto.DERCertificateBytes(der))...)
+	}
+
+	return &certificate.Resource{
+		Domain:            certDomain,
+		PrivateKey:        keyPEM,
+		Certificate:       certPEM,
+		IssuerCertificate: issuerPEM,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCertPEM returns a certificate for names issued by a throwaway CA, followed by the CA, and its key
+func newTestCertPEM(t *testing.T, names []string, notBefore, notAfter time.Time) ([]byte, []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	assert.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}, ca, key.Public(), caKey)
+	assert.NoError(t, err)
+
+	certPEM := append(certcrypto.PEMEncode(certcrypto.DERCertificateBytes(leafDER)), certcrypto.PEMEncode(certcrypto.DERCertificateBytes(caDER))...)
+	return certPEM, certcrypto.PEMEncode(key)
+}
+
+func TestNewImportedResource(t *testing.T) {
+	now := time.Now()
+	validFrom, validTill := now.Add(-time.Hour), now.Add(time.Hour)
+	certPEM, keyPEM := newTestCertPEM(t, []string{"example.com"}, validFrom, validTill)
+	_, otherKeyPEM := newTestCertPEM(t, []string{"example.com"}, validFrom, validTill)
+	wildcardPEM, wildcardKeyPEM := newTestCertPEM(t, []string{"*.example.com"}, validFrom, validTill)
+	expiredPEM, expiredKeyPEM := newTestCertPEM(t, []string{"example.com"}, now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	for _, tc := range []struct {
+		name            string
+		domain          string
+		certPEM, keyPEM []byte
+		expectedDomain  string
+		expectedErr     string
+	}{
+		{name: "matching", domain: "example.com", certPEM: certPEM, keyPEM: keyPEM, expectedDomain: "example.com"},
+		{name: "mismatched key", domain: "example.com", certPEM: certPEM, keyPEM: otherKeyPEM, expectedErr: "certificate and key do not match"},
+		{name: "other domain", domain: "example.org", certPEM: certPEM, keyPEM: keyPEM, expectedErr: "not valid for \"example.org\""},
+		{name: "subdomain of non-wildcard", domain: "*.example.com", certPEM: certPEM, keyPEM: keyPEM, expectedErr: "not valid for \"*.example.com\""},
+		{name: "wildcard", domain: "*.example.com", certPEM: wildcardPEM, keyPEM: wildcardKeyPEM, expectedDomain: "*.example.com"},
+		{name: "wildcard with leading dot", domain: ".example.com", certPEM: wildcardPEM, keyPEM: wildcardKeyPEM, expectedDomain: "*.example.com"},
+		{name: "wildcard covering the domain", domain: "pages.example.com", certPEM: wildcardPEM, keyPEM: wildcardKeyPEM, expectedDomain: "pages.example.com"},
+		{name: "wildcard not covering the apex", domain: "example.com", certPEM: wildcardPEM, keyPEM: wildcardKeyPEM, expectedErr: "not valid for \"example.com\""},
+		{name: "expired", domain: "example.com", certPEM: expiredPEM, keyPEM: expiredKeyPEM, expectedErr: "certificate is only valid from"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := newImportedResource(tc.domain, tc.certPEM, tc.keyPEM)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.EqualValues(t, tc.expectedDomain, res.Domain)
+				assert.EqualValues(t, tc.certPEM, res.Certificate)
+				assert.EqualValues(t, tc.keyPEM, res.PrivateKey)
+				// the CA behind the leaf certificate ends up in the issuer chain
+				issuers, err := certcrypto.ParsePEMBundle(res.IssuerCertificate)
+				assert.NoError(t, err)
+				if assert.Len(t, issuers, 1) {
+					assert.EqualValues(t, "Test CA", issuers[0].Subject.CommonName)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	certPEM, keyPEM := newTestCertPEM(t, []string{"example.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	res, err := newImportedResource("example.com", certPEM, keyPEM)
+	assert.NoError(t, err)
+
+	pfx, err := encodePKCS12(res, "secret")
+	assert.NoError(t, err)
+
+	privateKey, leaf, caCerts, err := pkcs12.DecodeChain(pfx, "secret")
+	assert.NoError(t, err)
+	expectedKey, err := certcrypto.ParsePEMPrivateKey(keyPEM)
+	assert.NoError(t, err)
+	assert.True(t, expectedKey.(*ecdsa.PrivateKey).Equal(privateKey))
+	assert.EqualValues(t, []string{"example.com"}, leaf.DNSNames)
+	if assert.Len(t, caCerts, 1) {
+		assert.EqualValues(t, "Test CA", caCerts[0].Subject.CommonName)
+	}
+
+	_, _, _, err = pkcs12.DecodeChain(pfx, "wrong")
+	assert.Error(t, err)
+
+	_, err = encodePKCS12(&certificate.Resource{Certificate: certPEM, PrivateKey: []byte("no key")}, "")
+	assert.Error(t, err)
+}
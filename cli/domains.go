@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/dns"
+)
+
+var Domains = &cli.Command{
+	Name:  "domains",
+	Usage: "help owners to set up custom domains",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "token",
+			Usage:     "show the TXT record an owner needs to verify a custom domain, and check it if a domain is given",
+			ArgsUsage: "<owner> [<domain>]",
+			Action:    showVerificationToken,
+		},
+	},
+	Flags: []cli.Flag{
+		DomainVerificationSecretFlag,
+	},
+}
+
+func showVerificationToken(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 || ctx.Args().Len() > 2 {
+		return fmt.Errorf("'domains token' requires an owner and optionally a domain as arguments")
+	}
+	owner, domain := ctx.Args().Get(0), ctx.Args().Get(1)
+
+	secret := ctx.String("domain-verification-secret")
+	if secret == "" {
+		return fmt.Errorf("the domain verification secret is not set")
+	}
+	token := dns.VerificationToken(secret, owner)
+
+	if domain == "" {
+		fmt.Printf("Token of %s: %s\n", owner, token)
+		fmt.Printf("Add it as TXT record at %s<domain>\n", dns.VerificationPrefix)
+		return nil
+	}
+
+	fmt.Printf("%s IN TXT %q\n", dns.VerificationRecord(domain), token)
+	if dns.IsVerified(domain, token, cache.NewInMemoryCache()) {
+		fmt.Printf("%s is verified\n", domain)
+	} else {
+		fmt.Printf("%s is not verified yet\n", domain)
+	}
+	return nil
+}
@@ -5,19 +5,35 @@ import (
 )
 
 var (
+	DomainVerificationSecretFlag = &cli.StringFlag{
+		Name:    "domain-verification-secret",
+		Usage:   "secret the domain verification tokens of all owners are derived from",
+		EnvVars: []string{"DOMAIN_VERIFICATION_SECRET"},
+	}
+
 	CertStorageFlags = []cli.Flag{
 		&cli.StringFlag{
 			Name:    "db-type",
-			Usage:   "Specify the database driver. Valid options are \"sqlite3\", \"mysql\" and \"postgres\". Read more at https://xorm.io",
+			Usage:   "Specify the database driver. Valid options are \"sqlite3\", \"mysql\", \"postgres\" and \"file\". Read more at https://xorm.io",
 			Value:   "sqlite3",
 			EnvVars: []string{"DB_TYPE"},
 		},
 		&cli.StringFlag{
 			Name:    "db-conn",
-			Usage:   "Specify the database connection. For \"sqlite3\" it's the filepath. Read more at https://go.dev/doc/tutorial/database-access",
+			Usage:   "Specify the database connection. For \"sqlite3\" it's the filepath, for \"file\" the directory to store the certificates in. Read more at https://go.dev/doc/tutorial/database-access",
 			Value:   "certs.sqlite",
 			EnvVars: []string{"DB_CONN"},
 		},
+		&cli.StringFlag{
+			Name:    "db-encryption-key",
+			Usage:   "base64 encoded 32 byte key to encrypt private keys in the database with, e.g. generated by \"openssl rand -base64 32\"",
+			EnvVars: []string{"DB_ENCRYPTION_KEY"},
+		},
+		&cli.StringFlag{
+			Name:    "db-encryption-key-file",
+			Usage:   "file containing the key to encrypt private keys in the database with, ignored if \"db-encryption-key\" is set",
+			EnvVars: []string{"DB_ENCRYPTION_KEY_FILE"},
+		},
 	}
 
 	ServerFlags = append(CertStorageFlags, []cli.Flag{
@@ -36,6 +52,11 @@ var (
 			Usage:   "specifies an api token for the Gitea instance",
 			EnvVars: []string{"GITEA_API_TOKEN"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "gitea-owner-token",
+			Usage:   "api token used instead of the global one for the repositories of an owner as <owner>=<token>, can be given multiple times",
+			EnvVars: []string{"GITEA_OWNER_TOKENS"},
+		},
 		&cli.BoolFlag{
 			Name:    "enable-lfs-support",
 			Usage:   "enable lfs support, require gitea >= v1.17.0 as backend",
@@ -125,6 +146,55 @@ var (
 			Usage:   "return an error on these url paths.Use this flag multiple times for multiple paths.",
 			EnvVars: []string{"BLACKLISTED_PATHS"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "public-ips",
+			Usage:   "public IP addresses of the server, apex domains mapped by TXT records must resolve to them. Use this flag multiple times for multiple addresses.",
+			EnvVars: []string{"PUBLIC_IPS"},
+		},
+		&cli.BoolFlag{
+			Name:    "enable-directory-listing",
+			Usage:   "allow sites to list directories without index page by setting directoryListing in their .pages.toml",
+			EnvVars: []string{"ENABLE_DIRECTORY_LISTING"},
+		},
+		&cli.BoolFlag{
+			Name:    "enable-proxy-protocol",
+			Usage:   "read the client address from PROXY protocol v1/v2 headers of connections from proxy-protocol-sources",
+			EnvVars: []string{"ENABLE_PROXY_PROTOCOL"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-protocol-sources",
+			Usage:   "IP addresses or CIDR ranges of load balancers allowed to send PROXY protocol headers. Use this flag multiple times for multiple sources.",
+			EnvVars: []string{"PROXY_PROTOCOL_SOURCES"},
+		},
+		&cli.StringFlag{
+			Name:    "custom-domain-hsts",
+			Usage:   "Strict-Transport-Security header sent for custom domains, e.g. \"max-age=31536000\". Custom domains get none by default.",
+			EnvVars: []string{"CUSTOM_DOMAIN_HSTS"},
+		},
+		&cli.StringFlag{
+			Name:    "raw-domain-csp",
+			Usage:   "Content-Security-Policy header sent for the raw domain, an empty value disables it",
+			EnvVars: []string{"RAW_DOMAIN_CSP"},
+			Value:   "sandbox",
+		},
+		&cli.StringFlag{
+			Name:    "cache-control-default",
+			Usage:   "Cache-Control header of files no rule of the config file or the .pages.toml of the site matches",
+			EnvVars: []string{"CACHE_CONTROL_DEFAULT"},
+			Value:   "public, max-age=600",
+		},
+		&cli.StringFlag{
+			Name:    "cache-control-immutable",
+			Usage:   "Cache-Control header of fingerprinted files like app.3f9a1c.js, an empty value disables the detection",
+			EnvVars: []string{"CACHE_CONTROL_IMMUTABLE"},
+			Value:   "public, max-age=31536000, immutable",
+		},
+		&cli.StringFlag{
+			Name:    "cache-control-errors",
+			Usage:   "Cache-Control header of error pages and redirects",
+			EnvVars: []string{"CACHE_CONTROL_ERRORS"},
+			Value:   "public, max-age=60",
+		},
 
 		&cli.StringFlag{
 			Name:    "log-level",
@@ -184,5 +254,184 @@ var (
 			Value:   "acme-account.json",
 			EnvVars: []string{"ACME_ACCOUNT_CONFIG"},
 		},
+		&cli.StringFlag{
+			Name:    "acme-key-type",
+			Usage:   "key type of obtained certificates, valid options are \"EC256\", \"EC384\", \"RSA2048\" and \"RSA4096\"",
+			Value:   "RSA2048",
+			EnvVars: []string{"ACME_KEY_TYPE"},
+		},
+		&cli.StringFlag{
+			Name:    "acme-secondary-key-type",
+			Usage:   "additionally obtain certificates of this key type for clients that don't support the main key type, e.g. \"RSA2048\" next to \"EC256\"",
+			EnvVars: []string{"ACME_SECONDARY_KEY_TYPE"},
+		},
+		&cli.IntFlag{
+			Name:    "cert-quota",
+			Usage:   "number of certificates every owner may obtain for custom domains per quota period, -1 disables the quota",
+			EnvVars: []string{"CERT_QUOTA"},
+			Value:   10,
+		},
+		&cli.StringFlag{
+			Name:    "cert-quota-period",
+			Usage:   "duration of the certificate quota period, e.g. \"24h\"",
+			EnvVars: []string{"CERT_QUOTA_PERIOD"},
+			Value:   "24h",
+		},
+		&cli.StringSliceFlag{
+			Name:    "cert-quota-owner",
+			Usage:   "certificate quota of a single owner as <owner>=<certificates>, can be given multiple times",
+			EnvVars: []string{"CERT_QUOTA_OWNERS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "cert-quota-unlimited-owner",
+			Usage:   "owner, e.g. a trusted organization, without certificate quota, can be given multiple times",
+			EnvVars: []string{"CERT_QUOTA_UNLIMITED_OWNERS"},
+		},
+		&cli.BoolFlag{
+			Name:    "domain-verification",
+			Usage:   "only obtain certificates for custom domains with a _pages-verify TXT record containing the token of their owner",
+			EnvVars: []string{"DOMAIN_VERIFICATION"},
+			Value:   false,
+		},
+		DomainVerificationSecretFlag,
+
+		// ####################
+		// ### DNS Settings ###
+		// ####################
+		&cli.StringSliceFlag{
+			Name:    "dns-servers",
+			Usage:   "resolve custom domains with these DNS servers as host[:port] instead of the system resolver. Use this flag multiple times for multiple servers.",
+			EnvVars: []string{"DNS_SERVERS"},
+		},
+		&cli.StringFlag{
+			Name:    "dns-protocol",
+			Usage:   "protocol to query the DNS servers with, valid options are \"udp\", \"tcp\" and \"tcp-tls\" (DNS over TLS)",
+			EnvVars: []string{"DNS_PROTOCOL"},
+			Value:   "udp",
+		},
+		&cli.StringFlag{
+			Name:    "dns-timeout",
+			Usage:   "timeout of every DNS query, e.g. \"5s\"",
+			EnvVars: []string{"DNS_TIMEOUT"},
+			Value:   "5s",
+		},
+		&cli.BoolFlag{
+			Name:    "dns-require-dnssec",
+			Usage:   "only accept answers the DNS servers validated with DNSSEC, unsigned custom domains won't work",
+			EnvVars: []string{"DNS_REQUIRE_DNSSEC"},
+			Value:   false,
+		},
+
+		// ######################
+		// ### OAuth Settings ###
+		// ######################
+		&cli.BoolFlag{
+			Name:    "enable-oauth",
+			Usage:   "serve private repositories only to users that can read them on Gitea, after they logged in with OAuth2",
+			EnvVars: []string{"ENABLE_OAUTH"},
+			Value:   false,
+		},
+		&cli.StringFlag{
+			Name:    "oauth-client-id",
+			Usage:   "client id of the OAuth2 application registered on Gitea",
+			EnvVars: []string{"OAUTH_CLIENT_ID"},
+		},
+		&cli.StringFlag{
+			Name:    "oauth-client-secret",
+			Usage:   "client secret of the OAuth2 application registered on Gitea",
+			EnvVars: []string{"OAUTH_CLIENT_SECRET"},
+		},
+		&cli.StringFlag{
+			Name:    "oauth-domain",
+			Usage:   "domain serving the OAuth2 callback https://{domain}/.pages-oauth/callback, defaults to the raw domain",
+			EnvVars: []string{"OAUTH_DOMAIN"},
+		},
+		&cli.StringFlag{
+			Name:    "oauth-session-secret",
+			Usage:   "secret the session cookies are signed with",
+			EnvVars: []string{"OAUTH_SESSION_SECRET"},
+		},
+		&cli.StringFlag{
+			Name:    "oauth-session-timeout",
+			Usage:   "how long a login and the permission checked with it are valid, e.g. \"8h\"",
+			EnvVars: []string{"OAUTH_SESSION_TIMEOUT"},
+			Value:   "8h",
+		},
+
+		// ###########################
+		// ### Rate Limit Settings ###
+		// ###########################
+		&cli.BoolFlag{
+			Name:    "enable-rate-limit",
+			Usage:   "throttle requests per client IP and per site, throttled requests get a 429 response",
+			EnvVars: []string{"ENABLE_RATE_LIMIT"},
+			Value:   false,
+		},
+		&cli.IntFlag{
+			Name:    "rate-limit-client-requests",
+			Usage:   "number of requests a single client IP may send per client period, 0 disables the limit",
+			EnvVars: []string{"RATE_LIMIT_CLIENT_REQUESTS"},
+			Value:   600,
+		},
+		&cli.StringFlag{
+			Name:    "rate-limit-client-period",
+			Usage:   "duration in which the requests of a client are limited, e.g. \"1m\"",
+			EnvVars: []string{"RATE_LIMIT_CLIENT_PERIOD"},
+			Value:   "1m",
+		},
+		&cli.IntFlag{
+			Name:    "rate-limit-site-requests",
+			Usage:   "number of requests a single owner/repo may be requested with per site period, 0 disables the limit",
+			EnvVars: []string{"RATE_LIMIT_SITE_REQUESTS"},
+			Value:   6000,
+		},
+		&cli.StringFlag{
+			Name:    "rate-limit-site-period",
+			Usage:   "duration in which the requests of a site are limited, e.g. \"1m\"",
+			EnvVars: []string{"RATE_LIMIT_SITE_PERIOD"},
+			Value:   "1m",
+		},
+		&cli.StringSliceFlag{
+			Name:    "rate-limit-allowed-clients",
+			Usage:   "IP or CIDR range of clients that are never throttled, can be given multiple times",
+			EnvVars: []string{"RATE_LIMIT_ALLOWED_CLIENTS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "rate-limit-allowed-sites",
+			Usage:   "owner or owner/repo that is never throttled, can be given multiple times",
+			EnvVars: []string{"RATE_LIMIT_ALLOWED_SITES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "rate-limit-trusted-proxies",
+			Usage:   "IP or CIDR range of a reverse proxy whose X-Forwarded-For header names the client, can be given multiple times",
+			EnvVars: []string{"RATE_LIMIT_TRUSTED_PROXIES"},
+		},
+
+		// ##########################
+		// ### Admin API Settings ###
+		// ##########################
+		&cli.BoolFlag{
+			Name:    "enable-admin-api",
+			Usage:   "start the admin api on a separate listener",
+			EnvVars: []string{"ENABLE_ADMIN_API"},
+			Value:   false,
+		},
+		&cli.StringFlag{
+			Name:    "admin-host",
+			Usage:   "specifies host of the admin api listening address",
+			EnvVars: []string{"ADMIN_HOST"},
+			Value:   "127.0.0.1",
+		},
+		&cli.UintFlag{
+			Name:    "admin-port",
+			Usage:   "specifies the port of the admin api",
+			EnvVars: []string{"ADMIN_PORT"},
+			Value:   9090,
+		},
+		&cli.StringFlag{
+			Name:    "admin-token",
+			Usage:   "bearer token required to access the admin api",
+			EnvVars: []string{"ADMIN_TOKEN"},
+		},
 	}...)
 )
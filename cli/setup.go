@@ -18,13 +18,43 @@ func CreatePagesApp() *cli.App {
 	app.Flags = ServerFlags
 	app.Commands = []*cli.Command{
 		Certs,
+		Domains,
+		Takedowns,
 	}
 
 	return app
 }
 
+// OpenCertDB opens the database set by the db-* flags.
 func OpenCertDB(ctx *cli.Context) (certDB database.CertDB, closeFn func(), err error) {
-	certDB, err = database.NewXormDB(ctx.String("db-type"), ctx.String("db-conn"))
+	return OpenCertDBWithKey(ctx.String("db-type"), ctx.String("db-conn"), ctx.String("db-encryption-key"), ctx.String("db-encryption-key-file"))
+}
+
+// OpenCertDBWithKey opens a database whose private keys are encrypted with encryptionKey or the key in encryptionKeyFile,
+// they are stored unencrypted if neither is set.
+func OpenCertDBWithKey(dbType, dbConn, encryptionKey, encryptionKeyFile string) (certDB database.CertDB, closeFn func(), err error) {
+	encryption, err := keyEncryption(encryptionKey, encryptionKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return openCertDB(dbType, dbConn, encryption)
+}
+
+// keyEncryption returns the private key encryption with the given key, or nil if no key is set.
+func keyEncryption(encryptionKey, encryptionKeyFile string, oldKeys ...[]byte) (*database.KeyEncryption, error) {
+	key, err := database.ReadEncryptionKey(encryptionKey, encryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		log.Warn().Msg("No database encryption key set, private keys are stored unencrypted")
+		return nil, nil
+	}
+	return database.NewKeyEncryption(key, oldKeys...)
+}
+
+func openCertDB(dbType, dbConn string, encryption *database.KeyEncryption) (certDB database.CertDB, closeFn func(), err error) {
+	certDB, err = database.NewCertDB(dbType, dbConn, encryption)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not connect to database: %w", err)
 	}
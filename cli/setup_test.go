@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenCertDBWithKey(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	assert.NoError(t, err)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	dir := t.TempDir()
+
+	certPEM, keyPEM := newTestCertPEM(t, []string{"example.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	certDB, closeFn, err := OpenCertDBWithKey("file", dir, encodedKey, "")
+	assert.NoError(t, err)
+	assert.NoError(t, certDB.Put("example.com", "", &certificate.Resource{Domain: "example.com", Certificate: certPEM, PrivateKey: keyPEM}))
+	res, err := certDB.Get("example.com")
+	assert.NoError(t, err)
+	assert.EqualValues(t, keyPEM, res.PrivateKey)
+	closeFn()
+
+	// the private key was stored encrypted, so it can't be read without the key
+	certDB, closeFn, err = OpenCertDBWithKey("file", dir, "", "")
+	assert.NoError(t, err)
+	defer closeFn()
+	_, err = certDB.Get("example.com")
+	assert.ErrorContains(t, err, "encrypted")
+
+	_, _, err = OpenCertDBWithKey("file", dir, "", dir+"/missing.key")
+	assert.Error(t, err)
+}
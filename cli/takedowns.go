@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+var Takedowns = &cli.Command{
+	Name:  "takedowns",
+	Usage: "block owners, repositories, branches or custom domains, a running server applies changes within a minute",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "list",
+			Usage:  "list all takedowns",
+			Action: listTakedowns,
+		},
+		{
+			Name:      "add",
+			Usage:     "take down an owner, a repo (owner/repo), a branch (owner/repo/branch) or a custom domain and its subdomains",
+			ArgsUsage: "<owner|repo|branch|domain> <target>",
+			Action:    addTakedown,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "reason",
+					Usage: "reason shown on the takedown page",
+				},
+				&cli.IntFlag{
+					Name:  "status",
+					Usage: "status code of the takedown page, 451 (Unavailable For Legal Reasons) or 403 (Forbidden)",
+					Value: 451,
+				},
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "lift a takedown",
+			ArgsUsage: "<owner|repo|branch|domain> <target>",
+			Action:    removeTakedown,
+		},
+	},
+	Flags: CertStorageFlags,
+}
+
+func listTakedowns(ctx *cli.Context) error {
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	takedowns, err := certDB.Takedowns()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Kind\tTarget\tStatus\tCreated\tReason\n\n")
+	for _, takedown := range takedowns {
+		fmt.Printf("%s\t%s\t%d\t%s\t%s\n",
+			takedown.Kind,
+			takedown.Target,
+			takedown.Status,
+			time.Unix(takedown.Created, 0).Format(time.RFC3339),
+			takedown.Reason)
+	}
+	return nil
+}
+
+func addTakedown(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return fmt.Errorf("'takedowns add' requires a kind and a target as arguments")
+	}
+	takedown, err := database.NewTakedown(ctx.Args().Get(0), ctx.Args().Get(1), ctx.String("reason"), ctx.Int("status"))
+	if err != nil {
+		return err
+	}
+
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := certDB.PutTakedown(takedown); err != nil {
+		return err
+	}
+	fmt.Printf("Took down %s %s\n", takedown.Kind, takedown.Target)
+	return nil
+}
+
+func removeTakedown(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return fmt.Errorf("'takedowns remove' requires a kind and a target as arguments")
+	}
+	kind := ctx.Args().Get(0)
+	target, err := database.NormalizeTakedownTarget(kind, ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	certDB, closeFn, err := OpenCertDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := certDB.DeleteTakedown(kind, target); err != nil {
+		return err
+	}
+	fmt.Printf("Lifted takedown of %s %s\n", kind, target)
+	return nil
+}
@@ -1,11 +1,15 @@
 package config
 
 type Config struct {
-	LogLevel string `default:"warn"`
-	Server   ServerConfig
-	Gitea    GiteaConfig
-	Database DatabaseConfig
-	ACME     ACMEConfig
+	LogLevel  string `default:"warn"`
+	Server    ServerConfig
+	Gitea     GiteaConfig
+	Database  DatabaseConfig
+	ACME      ACMEConfig
+	DNS       DNSConfig
+	OAuth     OAuthConfig
+	RateLimit RateLimitConfig
+	Admin     AdminConfig
 }
 
 type ServerConfig struct {
@@ -18,6 +22,62 @@ type ServerConfig struct {
 	PagesBranches      []string
 	AllowedCorsDomains []string
 	BlacklistedPaths   []string
+	// PublicIPs of the server, domains mapped by TXT records must resolve to them
+	PublicIPs []string
+	// DirectoryListing allows sites to opt in to listing directories without index page
+	DirectoryListing bool `default:"false"`
+	// ProxyProtocol reads the client address from PROXY protocol headers sent by ProxyProtocolSources
+	ProxyProtocol        bool `default:"false"`
+	ProxyProtocolSources []string
+	// Headers are the security headers sent for the main, raw and custom domains
+	Headers HeadersConfig
+	// CacheControl selects the Cache-Control header of files and error pages
+	CacheControl CacheControlConfig
+}
+
+// CacheControlConfig selects the Cache-Control header of responses, sites can add their own rules in their .pages.toml
+type CacheControlConfig struct {
+	// Default is used for files no rule matches
+	Default string `default:"public, max-age=600"`
+	// Immutable is used for fingerprinted file names like "app.3f9a1c.js", empty disables the detection
+	Immutable string `default:"public, max-age=31536000, immutable"`
+	// Errors is used for error pages and redirects
+	Errors string `default:"public, max-age=60"`
+	// Rules are tried in order before the fingerprint detection and the default
+	Rules []CacheControlRule
+}
+
+// CacheControlRule sets the Cache-Control header of files matching Path and MimeType, empty ones match all files
+type CacheControlRule struct {
+	// Path is a glob pattern, patterns without "/" match the file name (e.g. "*.html"), others the path
+	// from the site root (e.g. "/images/*.png"), a trailing "/**" matches everything in a directory
+	Path string
+	// MimeType is a media type like "text/html", or a type like "image/*"
+	MimeType string
+	// Value of the Cache-Control header
+	Value string
+}
+
+// HeadersConfig selects the security headers by the class of the requested domain
+type HeadersConfig struct {
+	// Main is used for the subdomains of the main domain
+	Main HeaderPolicy
+	// Raw is used for the raw domain
+	Raw HeaderPolicy
+	// Custom is used for custom domains
+	Custom HeaderPolicy
+}
+
+// HeaderPolicy are the values of security headers, headers with an empty value are not sent
+type HeaderPolicy struct {
+	// HSTS is the Strict-Transport-Security header
+	HSTS                      string
+	ContentTypeOptions        string `default:"nosniff"`
+	ReferrerPolicy            string `default:"strict-origin-when-cross-origin"`
+	ContentSecurityPolicy     string
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+	CrossOriginResourcePolicy string
 }
 
 type GiteaConfig struct {
@@ -27,11 +87,15 @@ type GiteaConfig struct {
 	FollowSymlinks     bool   `default:"false"`
 	DefaultMimeType    string `default:"application/octet-stream"`
 	ForbiddenMimeTypes []string
+	// OwnerTokens are used instead of Token for the repositories of an owner
+	OwnerTokens map[string]string
 }
 
 type DatabaseConfig struct {
-	Type string `default:"sqlite3"`
-	Conn string `default:"certs.sqlite"`
+	Type              string `default:"sqlite3"`
+	Conn              string `default:"certs.sqlite"`
+	EncryptionKey     string
+	EncryptionKeyFile string
 }
 
 type ACMEConfig struct {
@@ -43,4 +107,88 @@ type ACMEConfig struct {
 	EAB_KID           string
 	DNSProvider       string
 	AccountConfigFile string `default:"acme-account.json"`
+	KeyType           string `default:"RSA2048"`
+	SecondaryKeyType  string
+	// Issuers are tried in order until one of them issues a certificate.
+	// If none are given, the single issuer configured above is used.
+	Issuers      []ACMEIssuerConfig
+	Quota        QuotaConfig
+	Verification VerificationConfig
+}
+
+type ACMEIssuerConfig struct {
+	Name              string
+	APIEndpoint       string
+	UseRateLimits     bool
+	EAB_HMAC          string
+	EAB_KID           string
+	AccountConfigFile string
+}
+
+// QuotaConfig limits how many certificates every owner may obtain for custom domains
+type QuotaConfig struct {
+	// Certificates per owner and period, -1 disables the quota
+	Certificates int    `default:"10"`
+	Period       string `default:"24h"`
+	// Owners overrides the number of certificates of single owners
+	Owners map[string]int
+	// UnlimitedOwners, e.g. trusted organizations, are not limited at all
+	UnlimitedOwners []string
+}
+
+// VerificationConfig requires owners to prove control of custom domains by a TXT record before certificates are obtained
+type VerificationConfig struct {
+	Enabled bool `default:"false"`
+	// Secret the verification tokens of all owners are derived from
+	Secret string
+}
+
+// DNSConfig selects the servers custom domains are resolved with
+type DNSConfig struct {
+	// Servers as host[:port], the system resolver is used if none are given
+	Servers []string
+	// Protocol to query the servers with, one of "udp", "tcp" and "tcp-tls" (DNS over TLS)
+	Protocol string `default:"udp"`
+	// Timeout of every single query
+	Timeout string `default:"5s"`
+	// RequireDNSSEC only accepts answers the servers validated with DNSSEC
+	RequireDNSSEC bool `default:"false"`
+}
+
+// OAuthConfig restricts private repositories to users that can read them on Gitea, who log in with OAuth2
+type OAuthConfig struct {
+	Enabled bool `default:"false"`
+	// ClientID and ClientSecret of the OAuth2 application registered on Gitea
+	ClientID     string
+	ClientSecret string
+	// Domain serves the OAuth2 callback "/.pages-oauth/callback", the raw domain if empty
+	Domain string
+	// SessionSecret signs the session cookies
+	SessionSecret string
+	// SessionTimeout limits how long a login and the permission checked with it are valid
+	SessionTimeout string `default:"8h"`
+}
+
+// RateLimitConfig throttles requests with token buckets per client IP and per site
+type RateLimitConfig struct {
+	Enabled bool `default:"false"`
+	// ClientRequests a single client IP may send per ClientPeriod, 0 disables the limit
+	ClientRequests int    `default:"600"`
+	ClientPeriod   string `default:"1m"`
+	// SiteRequests a single owner/repo may be requested with per SitePeriod, 0 disables the limit
+	SiteRequests int    `default:"6000"`
+	SitePeriod   string `default:"1m"`
+	// AllowedClients are IPs or CIDR ranges that are never throttled
+	AllowedClients []string
+	// AllowedSites are owners or owner/repo pairs that are never throttled
+	AllowedSites []string
+	// TrustedProxies are IPs or CIDR ranges of reverse proxies, whose X-Forwarded-For header names the client
+	TrustedProxies []string
+}
+
+type AdminConfig struct {
+	Enabled bool   `default:"false"`
+	Host    string `default:"127.0.0.1"`
+	Port    uint16 `default:"9090"`
+	Token   string
 }
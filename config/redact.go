@@ -0,0 +1,39 @@
+package config
+
+const redacted = "[redacted]"
+
+// Redacted returns a copy of the config with all secrets replaced, so it can be shown to operators.
+func (c Config) Redacted() Config {
+	redact(&c.Gitea.Token)
+	// the owner tokens are shared with the original config, so they are redacted in a copy
+	if c.Gitea.OwnerTokens != nil {
+		ownerTokens := make(map[string]string, len(c.Gitea.OwnerTokens))
+		for owner, token := range c.Gitea.OwnerTokens {
+			redact(&token)
+			ownerTokens[owner] = token
+		}
+		c.Gitea.OwnerTokens = ownerTokens
+	}
+	redact(&c.ACME.EAB_HMAC)
+	// the issuers are shared with the original config, so they are redacted in a copy
+	c.ACME.Issuers = append([]ACMEIssuerConfig(nil), c.ACME.Issuers...)
+	for i := range c.ACME.Issuers {
+		redact(&c.ACME.Issuers[i].EAB_HMAC)
+	}
+	redact(&c.ACME.Verification.Secret)
+	redact(&c.OAuth.ClientSecret)
+	redact(&c.OAuth.SessionSecret)
+	redact(&c.Admin.Token)
+	redact(&c.Database.EncryptionKey)
+	// the connection string of network databases may contain credentials
+	if c.Database.Type != "sqlite3" && c.Database.Type != "file" {
+		redact(&c.Database.Conn)
+	}
+	return c
+}
+
+func redact(value *string) {
+	if *value != "" {
+		*value = redacted
+	}
+}
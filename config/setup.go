@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
 	"github.com/creasty/defaults"
 	"github.com/pelletier/go-toml/v2"
@@ -14,6 +16,9 @@ var ALWAYS_BLACKLISTED_PATHS = []string{
 	"/.well-known/acme-challenge/",
 }
 
+// DefaultHSTS is the Strict-Transport-Security header of the main and raw domain
+const DefaultHSTS = "max-age=63072000; includeSubdomains; preload"
+
 func NewDefaultConfig() Config {
 	config := Config{}
 	if err := defaults.Set(&config); err != nil {
@@ -23,6 +28,12 @@ func NewDefaultConfig() Config {
 	// defaults does not support setting arrays from strings
 	config.Server.PagesBranches = []string{"main", "master", "pages"}
 
+	// the domains of the operator are preloaded, custom domains have to opt in to HSTS
+	config.Server.Headers.Main.HSTS = DefaultHSTS
+	config.Server.Headers.Raw.HSTS = DefaultHSTS
+	// raw files must not run scripts on the raw domain
+	config.Server.Headers.Raw.ContentSecurityPolicy = "sandbox"
+
 	return config
 }
 
@@ -54,6 +65,10 @@ func MergeConfig(ctx *cli.Context, config *Config) {
 	mergeGiteaConfig(ctx, &config.Gitea)
 	mergeDatabaseConfig(ctx, &config.Database)
 	mergeACMEConfig(ctx, &config.ACME)
+	mergeDNSConfig(ctx, &config.DNS)
+	mergeOAuthConfig(ctx, &config.OAuth)
+	mergeRateLimitConfig(ctx, &config.RateLimit)
+	mergeAdminConfig(ctx, &config.Admin)
 }
 
 func mergeServerConfig(ctx *cli.Context, config *ServerConfig) {
@@ -84,6 +99,33 @@ func mergeServerConfig(ctx *cli.Context, config *ServerConfig) {
 	if ctx.IsSet("blacklisted-paths") {
 		config.BlacklistedPaths = ctx.StringSlice("blacklisted-paths")
 	}
+	if ctx.IsSet("public-ips") {
+		config.PublicIPs = ctx.StringSlice("public-ips")
+	}
+	if ctx.IsSet("enable-directory-listing") {
+		config.DirectoryListing = ctx.Bool("enable-directory-listing")
+	}
+	if ctx.IsSet("enable-proxy-protocol") {
+		config.ProxyProtocol = ctx.Bool("enable-proxy-protocol")
+	}
+	if ctx.IsSet("proxy-protocol-sources") {
+		config.ProxyProtocolSources = ctx.StringSlice("proxy-protocol-sources")
+	}
+	if ctx.IsSet("custom-domain-hsts") {
+		config.Headers.Custom.HSTS = ctx.String("custom-domain-hsts")
+	}
+	if ctx.IsSet("raw-domain-csp") {
+		config.Headers.Raw.ContentSecurityPolicy = ctx.String("raw-domain-csp")
+	}
+	if ctx.IsSet("cache-control-default") {
+		config.CacheControl.Default = ctx.String("cache-control-default")
+	}
+	if ctx.IsSet("cache-control-immutable") {
+		config.CacheControl.Immutable = ctx.String("cache-control-immutable")
+	}
+	if ctx.IsSet("cache-control-errors") {
+		config.CacheControl.Errors = ctx.String("cache-control-errors")
+	}
 
 	// add the paths that should always be blacklisted
 	config.BlacklistedPaths = append(config.BlacklistedPaths, ALWAYS_BLACKLISTED_PATHS...)
@@ -108,6 +150,17 @@ func mergeGiteaConfig(ctx *cli.Context, config *GiteaConfig) {
 	if ctx.IsSet("forbidden-mime-types") {
 		config.ForbiddenMimeTypes = ctx.StringSlice("forbidden-mime-types")
 	}
+	if ctx.IsSet("gitea-owner-token") {
+		config.OwnerTokens = map[string]string{}
+		for _, entry := range ctx.StringSlice("gitea-owner-token") {
+			owner, token, ok := strings.Cut(entry, "=")
+			if !ok || owner == "" || token == "" {
+				log.Error().Msg("ignoring invalid gitea owner token, expected <owner>=<token>")
+				continue
+			}
+			config.OwnerTokens[strings.ToLower(owner)] = token
+		}
+	}
 }
 
 func mergeDatabaseConfig(ctx *cli.Context, config *DatabaseConfig) {
@@ -117,6 +170,12 @@ func mergeDatabaseConfig(ctx *cli.Context, config *DatabaseConfig) {
 	if ctx.IsSet("db-conn") {
 		config.Conn = ctx.String("db-conn")
 	}
+	if ctx.IsSet("db-encryption-key") {
+		config.EncryptionKey = ctx.String("db-encryption-key")
+	}
+	if ctx.IsSet("db-encryption-key-file") {
+		config.EncryptionKeyFile = ctx.String("db-encryption-key-file")
+	}
 }
 
 func mergeACMEConfig(ctx *cli.Context, config *ACMEConfig) {
@@ -144,4 +203,124 @@ func mergeACMEConfig(ctx *cli.Context, config *ACMEConfig) {
 	if ctx.IsSet("acme-account-config") {
 		config.AccountConfigFile = ctx.String("acme-account-config")
 	}
+	if ctx.IsSet("acme-key-type") {
+		config.KeyType = ctx.String("acme-key-type")
+	}
+	if ctx.IsSet("acme-secondary-key-type") {
+		config.SecondaryKeyType = ctx.String("acme-secondary-key-type")
+	}
+
+	mergeQuotaConfig(ctx, &config.Quota)
+	mergeVerificationConfig(ctx, &config.Verification)
+}
+
+func mergeQuotaConfig(ctx *cli.Context, config *QuotaConfig) {
+	if ctx.IsSet("cert-quota") {
+		config.Certificates = ctx.Int("cert-quota")
+	}
+	if ctx.IsSet("cert-quota-period") {
+		config.Period = ctx.String("cert-quota-period")
+	}
+	if ctx.IsSet("cert-quota-owner") {
+		config.Owners = map[string]int{}
+		for _, entry := range ctx.StringSlice("cert-quota-owner") {
+			owner, limit, _ := strings.Cut(entry, "=")
+			certificates, err := strconv.Atoi(limit)
+			if err != nil {
+				log.Error().Msgf("ignoring invalid certificate quota %q, expected <owner>=<certificates>", entry)
+				continue
+			}
+			config.Owners[owner] = certificates
+		}
+	}
+	if ctx.IsSet("cert-quota-unlimited-owner") {
+		config.UnlimitedOwners = ctx.StringSlice("cert-quota-unlimited-owner")
+	}
+}
+
+func mergeVerificationConfig(ctx *cli.Context, config *VerificationConfig) {
+	if ctx.IsSet("domain-verification") {
+		config.Enabled = ctx.Bool("domain-verification")
+	}
+	if ctx.IsSet("domain-verification-secret") {
+		config.Secret = ctx.String("domain-verification-secret")
+	}
+}
+
+func mergeDNSConfig(ctx *cli.Context, config *DNSConfig) {
+	if ctx.IsSet("dns-servers") {
+		config.Servers = ctx.StringSlice("dns-servers")
+	}
+	if ctx.IsSet("dns-protocol") {
+		config.Protocol = ctx.String("dns-protocol")
+	}
+	if ctx.IsSet("dns-timeout") {
+		config.Timeout = ctx.String("dns-timeout")
+	}
+	if ctx.IsSet("dns-require-dnssec") {
+		config.RequireDNSSEC = ctx.Bool("dns-require-dnssec")
+	}
+}
+
+func mergeOAuthConfig(ctx *cli.Context, config *OAuthConfig) {
+	if ctx.IsSet("enable-oauth") {
+		config.Enabled = ctx.Bool("enable-oauth")
+	}
+	if ctx.IsSet("oauth-client-id") {
+		config.ClientID = ctx.String("oauth-client-id")
+	}
+	if ctx.IsSet("oauth-client-secret") {
+		config.ClientSecret = ctx.String("oauth-client-secret")
+	}
+	if ctx.IsSet("oauth-domain") {
+		config.Domain = ctx.String("oauth-domain")
+	}
+	if ctx.IsSet("oauth-session-secret") {
+		config.SessionSecret = ctx.String("oauth-session-secret")
+	}
+	if ctx.IsSet("oauth-session-timeout") {
+		config.SessionTimeout = ctx.String("oauth-session-timeout")
+	}
+}
+
+func mergeRateLimitConfig(ctx *cli.Context, config *RateLimitConfig) {
+	if ctx.IsSet("enable-rate-limit") {
+		config.Enabled = ctx.Bool("enable-rate-limit")
+	}
+	if ctx.IsSet("rate-limit-client-requests") {
+		config.ClientRequests = ctx.Int("rate-limit-client-requests")
+	}
+	if ctx.IsSet("rate-limit-client-period") {
+		config.ClientPeriod = ctx.String("rate-limit-client-period")
+	}
+	if ctx.IsSet("rate-limit-site-requests") {
+		config.SiteRequests = ctx.Int("rate-limit-site-requests")
+	}
+	if ctx.IsSet("rate-limit-site-period") {
+		config.SitePeriod = ctx.String("rate-limit-site-period")
+	}
+	if ctx.IsSet("rate-limit-allowed-clients") {
+		config.AllowedClients = ctx.StringSlice("rate-limit-allowed-clients")
+	}
+	if ctx.IsSet("rate-limit-allowed-sites") {
+		config.AllowedSites = ctx.StringSlice("rate-limit-allowed-sites")
+	}
+	if ctx.IsSet("rate-limit-trusted-proxies") {
+		config.TrustedProxies = ctx.StringSlice("rate-limit-trusted-proxies")
+	}
+}
+
+func mergeAdminConfig(ctx *cli.Context, config *AdminConfig) {
+	if ctx.IsSet("enable-admin-api") {
+		config.Enabled = ctx.Bool("enable-admin-api")
+	}
+	if ctx.IsSet("admin-host") {
+		config.Host = ctx.String("admin-host")
+	}
+	if ctx.IsSet("admin-port") {
+		config.Port = uint16(ctx.Uint("admin-port"))
+	}
+	if ctx.IsSet("admin-token") {
+		config.Token = ctx.String("admin-token")
+	}
 }
@@ -136,15 +136,21 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 			cfg := &Config{
 				LogLevel: "original",
 				Server: ServerConfig{
-					Host:               "original",
-					Port:               8080,
-					HttpPort:           80,
-					HttpServerEnabled:  false,
-					MainDomain:         "original",
-					RawDomain:          "original",
-					PagesBranches:      []string{"original"},
-					AllowedCorsDomains: []string{"original"},
-					BlacklistedPaths:   []string{"original"},
+					Host:                 "original",
+					Port:                 8080,
+					HttpPort:             80,
+					HttpServerEnabled:    false,
+					MainDomain:           "original",
+					RawDomain:            "original",
+					PagesBranches:        []string{"original"},
+					AllowedCorsDomains:   []string{"original"},
+					BlacklistedPaths:     []string{"original"},
+					PublicIPs:            []string{"original"},
+					DirectoryListing:     false,
+					ProxyProtocol:        false,
+					ProxyProtocolSources: []string{"original"},
+					Headers:              HeadersConfig{Raw: HeaderPolicy{ContentSecurityPolicy: "original"}, Custom: HeaderPolicy{HSTS: "original"}},
+					CacheControl:         CacheControlConfig{Default: "original", Immutable: "original", Errors: "original", Rules: []CacheControlRule{{Path: "*.html", Value: "original"}}},
 				},
 				Gitea: GiteaConfig{
 					Root:               "original",
@@ -153,10 +159,13 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 					FollowSymlinks:     false,
 					DefaultMimeType:    "original",
 					ForbiddenMimeTypes: []string{"original"},
+					OwnerTokens:        map[string]string{"original": "original"},
 				},
 				Database: DatabaseConfig{
-					Type: "original",
-					Conn: "original",
+					Type:              "original",
+					Conn:              "original",
+					EncryptionKey:     "original",
+					EncryptionKeyFile: "original",
 				},
 				ACME: ACMEConfig{
 					Email:             "original",
@@ -167,6 +176,49 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 					EAB_KID:           "original",
 					DNSProvider:       "original",
 					AccountConfigFile: "original",
+					KeyType:           "original",
+					SecondaryKeyType:  "original",
+					Issuers:           []ACMEIssuerConfig{{Name: "original", APIEndpoint: "original"}},
+					Quota: QuotaConfig{
+						Certificates:    1,
+						Period:          "original",
+						Owners:          map[string]int{"original": 1},
+						UnlimitedOwners: []string{"original"},
+					},
+					Verification: VerificationConfig{
+						Enabled: false,
+						Secret:  "original",
+					},
+				},
+				DNS: DNSConfig{
+					Servers:       []string{"original"},
+					Protocol:      "original",
+					Timeout:       "original",
+					RequireDNSSEC: false,
+				},
+				OAuth: OAuthConfig{
+					Enabled:        false,
+					ClientID:       "original",
+					ClientSecret:   "original",
+					Domain:         "original",
+					SessionSecret:  "original",
+					SessionTimeout: "original",
+				},
+				RateLimit: RateLimitConfig{
+					Enabled:        false,
+					ClientRequests: 1,
+					ClientPeriod:   "original",
+					SiteRequests:   1,
+					SitePeriod:     "original",
+					AllowedClients: []string{"original"},
+					AllowedSites:   []string{"original"},
+					TrustedProxies: []string{"original"},
+				},
+				Admin: AdminConfig{
+					Enabled: false,
+					Host:    "original",
+					Port:    9090,
+					Token:   "original",
 				},
 			}
 
@@ -175,15 +227,21 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 			expectedConfig := &Config{
 				LogLevel: "changed",
 				Server: ServerConfig{
-					Host:               "changed",
-					Port:               8443,
-					HttpPort:           443,
-					HttpServerEnabled:  true,
-					MainDomain:         "changed",
-					RawDomain:          "changed",
-					PagesBranches:      []string{"changed"},
-					AllowedCorsDomains: []string{"changed"},
-					BlacklistedPaths:   append([]string{"changed"}, ALWAYS_BLACKLISTED_PATHS...),
+					Host:                 "changed",
+					Port:                 8443,
+					HttpPort:             443,
+					HttpServerEnabled:    true,
+					MainDomain:           "changed",
+					RawDomain:            "changed",
+					PagesBranches:        []string{"changed"},
+					AllowedCorsDomains:   []string{"changed"},
+					BlacklistedPaths:     append([]string{"changed"}, ALWAYS_BLACKLISTED_PATHS...),
+					PublicIPs:            []string{"changed"},
+					DirectoryListing:     true,
+					ProxyProtocol:        true,
+					ProxyProtocolSources: []string{"changed"},
+					Headers:              HeadersConfig{Raw: HeaderPolicy{ContentSecurityPolicy: "changed"}, Custom: HeaderPolicy{HSTS: "changed"}},
+					CacheControl:         CacheControlConfig{Default: "changed", Immutable: "changed", Errors: "changed", Rules: []CacheControlRule{{Path: "*.html", Value: "original"}}},
 				},
 				Gitea: GiteaConfig{
 					Root:               "changed",
@@ -192,10 +250,13 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 					FollowSymlinks:     true,
 					DefaultMimeType:    "changed",
 					ForbiddenMimeTypes: []string{"changed"},
+					OwnerTokens:        map[string]string{"changed": "changed"},
 				},
 				Database: DatabaseConfig{
-					Type: "changed",
-					Conn: "changed",
+					Type:              "changed",
+					Conn:              "changed",
+					EncryptionKey:     "changed",
+					EncryptionKeyFile: "changed",
 				},
 				ACME: ACMEConfig{
 					Email:             "changed",
@@ -206,6 +267,50 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 					EAB_KID:           "changed",
 					DNSProvider:       "changed",
 					AccountConfigFile: "changed",
+					KeyType:           "changed",
+					SecondaryKeyType:  "changed",
+					// issuers can only be configured in the config file
+					Issuers: []ACMEIssuerConfig{{Name: "original", APIEndpoint: "original"}},
+					Quota: QuotaConfig{
+						Certificates:    2,
+						Period:          "changed",
+						Owners:          map[string]int{"changed": 2},
+						UnlimitedOwners: []string{"changed"},
+					},
+					Verification: VerificationConfig{
+						Enabled: true,
+						Secret:  "changed",
+					},
+				},
+				DNS: DNSConfig{
+					Servers:       []string{"changed"},
+					Protocol:      "changed",
+					Timeout:       "changed",
+					RequireDNSSEC: true,
+				},
+				OAuth: OAuthConfig{
+					Enabled:        true,
+					ClientID:       "changed",
+					ClientSecret:   "changed",
+					Domain:         "changed",
+					SessionSecret:  "changed",
+					SessionTimeout: "changed",
+				},
+				RateLimit: RateLimitConfig{
+					Enabled:        true,
+					ClientRequests: 2,
+					ClientPeriod:   "changed",
+					SiteRequests:   2,
+					SitePeriod:     "changed",
+					AllowedClients: []string{"changed"},
+					AllowedSites:   []string{"changed"},
+					TrustedProxies: []string{"changed"},
+				},
+				Admin: AdminConfig{
+					Enabled: true,
+					Host:    "changed",
+					Port:    9091,
+					Token:   "changed",
 				},
 			}
 
@@ -220,11 +325,20 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 			"--raw-domain", "changed",
 			"--allowed-cors-domains", "changed",
 			"--blacklisted-paths", "changed",
+			"--public-ips", "changed",
 			"--pages-branch", "changed",
 			"--host", "changed",
 			"--port", "8443",
 			"--http-port", "443",
 			"--enable-http-server",
+			"--enable-directory-listing",
+			"--enable-proxy-protocol",
+			"--proxy-protocol-sources", "changed",
+			"--custom-domain-hsts", "changed",
+			"--raw-domain-csp", "changed",
+			"--cache-control-default", "changed",
+			"--cache-control-immutable", "changed",
+			"--cache-control-errors", "changed",
 			// Gitea
 			"--gitea-root", "changed",
 			"--gitea-api-token", "changed",
@@ -232,9 +346,12 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 			"--enable-symlink-support",
 			"--default-mime-type", "changed",
 			"--forbidden-mime-types", "changed",
+			"--gitea-owner-token", "Changed=changed",
 			// Database
 			"--db-type", "changed",
 			"--db-conn", "changed",
+			"--db-encryption-key", "changed",
+			"--db-encryption-key-file", "changed",
 			// ACME
 			"--acme-email", "changed",
 			"--acme-api-endpoint", "changed",
@@ -244,6 +361,40 @@ func TestMergeConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T
 			"--acme-eab-kid", "changed",
 			"--dns-provider", "changed",
 			"--acme-account-config", "changed",
+			"--acme-key-type", "changed",
+			"--acme-secondary-key-type", "changed",
+			"--cert-quota", "2",
+			"--cert-quota-period", "changed",
+			"--cert-quota-owner", "changed=2",
+			"--cert-quota-unlimited-owner", "changed",
+			"--domain-verification",
+			"--domain-verification-secret", "changed",
+			// DNS
+			"--dns-servers", "changed",
+			"--dns-protocol", "changed",
+			"--dns-timeout", "changed",
+			"--dns-require-dnssec",
+			// OAuth
+			"--enable-oauth",
+			"--oauth-client-id", "changed",
+			"--oauth-client-secret", "changed",
+			"--oauth-domain", "changed",
+			"--oauth-session-secret", "changed",
+			"--oauth-session-timeout", "changed",
+			// Rate limit
+			"--enable-rate-limit",
+			"--rate-limit-client-requests", "2",
+			"--rate-limit-client-period", "changed",
+			"--rate-limit-site-requests", "2",
+			"--rate-limit-site-period", "changed",
+			"--rate-limit-allowed-clients", "changed",
+			"--rate-limit-allowed-sites", "changed",
+			"--rate-limit-trusted-proxies", "changed",
+			// Admin
+			"--enable-admin-api",
+			"--admin-host", "changed",
+			"--admin-port", "9091",
+			"--admin-token", "changed",
 		},
 	)
 }
@@ -270,27 +421,39 @@ func TestMergeServerConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *tes
 			t,
 			func(ctx *cli.Context) error {
 				cfg := &ServerConfig{
-					Host:               "original",
-					Port:               8080,
-					HttpPort:           80,
-					HttpServerEnabled:  false,
-					MainDomain:         "original",
-					RawDomain:          "original",
-					AllowedCorsDomains: []string{"original"},
-					BlacklistedPaths:   []string{"original"},
+					Host:                 "original",
+					Port:                 8080,
+					HttpPort:             80,
+					HttpServerEnabled:    false,
+					MainDomain:           "original",
+					RawDomain:            "original",
+					AllowedCorsDomains:   []string{"original"},
+					BlacklistedPaths:     []string{"original"},
+					PublicIPs:            []string{"original"},
+					DirectoryListing:     false,
+					ProxyProtocol:        false,
+					ProxyProtocolSources: []string{"original"},
+					Headers:              HeadersConfig{Raw: HeaderPolicy{ContentSecurityPolicy: "original"}, Custom: HeaderPolicy{HSTS: "original"}},
+					CacheControl:         CacheControlConfig{Default: "original", Immutable: "original", Errors: "original", Rules: []CacheControlRule{{Path: "*.html", Value: "original"}}},
 				}
 
 				mergeServerConfig(ctx, cfg)
 
 				expectedConfig := &ServerConfig{
-					Host:               "changed",
-					Port:               8443,
-					HttpPort:           443,
-					HttpServerEnabled:  true,
-					MainDomain:         "changed",
-					RawDomain:          "changed",
-					AllowedCorsDomains: fixArrayFromCtx(ctx, "allowed-cors-domains", []string{"changed"}),
-					BlacklistedPaths:   fixArrayFromCtx(ctx, "blacklisted-paths", append([]string{"changed"}, ALWAYS_BLACKLISTED_PATHS...)),
+					Host:                 "changed",
+					Port:                 8443,
+					HttpPort:             443,
+					HttpServerEnabled:    true,
+					MainDomain:           "changed",
+					RawDomain:            "changed",
+					AllowedCorsDomains:   fixArrayFromCtx(ctx, "allowed-cors-domains", []string{"changed"}),
+					BlacklistedPaths:     fixArrayFromCtx(ctx, "blacklisted-paths", append([]string{"changed"}, ALWAYS_BLACKLISTED_PATHS...)),
+					PublicIPs:            fixArrayFromCtx(ctx, "public-ips", []string{"changed"}),
+					DirectoryListing:     true,
+					ProxyProtocol:        true,
+					ProxyProtocolSources: fixArrayFromCtx(ctx, "proxy-protocol-sources", []string{"changed"}),
+					Headers:              HeadersConfig{Raw: HeaderPolicy{ContentSecurityPolicy: "changed"}, Custom: HeaderPolicy{HSTS: "changed"}},
+					CacheControl:         CacheControlConfig{Default: "changed", Immutable: "changed", Errors: "changed", Rules: []CacheControlRule{{Path: "*.html", Value: "original"}}},
 				}
 
 				assert.Equal(t, expectedConfig, cfg)
@@ -302,10 +465,19 @@ func TestMergeServerConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *tes
 				"--raw-domain", "changed",
 				"--allowed-cors-domains", "changed",
 				"--blacklisted-paths", "changed",
+				"--public-ips", "changed",
 				"--host", "changed",
 				"--port", "8443",
 				"--http-port", "443",
 				"--enable-http-server",
+				"--enable-directory-listing",
+				"--enable-proxy-protocol",
+				"--proxy-protocol-sources", "changed",
+				"--custom-domain-hsts", "changed",
+				"--raw-domain-csp", "changed",
+				"--cache-control-default", "changed",
+				"--cache-control-immutable", "changed",
+				"--cache-control-errors", "changed",
 			},
 		)
 	}
@@ -326,6 +498,15 @@ func TestMergeServerConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgE
 		{args: []string{"--pages-branch", "changed"}, callback: func(sc *ServerConfig) { sc.PagesBranches = []string{"changed"} }},
 		{args: []string{"--allowed-cors-domains", "changed"}, callback: func(sc *ServerConfig) { sc.AllowedCorsDomains = []string{"changed"} }},
 		{args: []string{"--blacklisted-paths", "changed"}, callback: func(sc *ServerConfig) { sc.BlacklistedPaths = []string{"changed"} }},
+		{args: []string{"--public-ips", "changed"}, callback: func(sc *ServerConfig) { sc.PublicIPs = []string{"changed"} }},
+		{args: []string{"--enable-directory-listing"}, callback: func(sc *ServerConfig) { sc.DirectoryListing = true }},
+		{args: []string{"--enable-proxy-protocol"}, callback: func(sc *ServerConfig) { sc.ProxyProtocol = true }},
+		{args: []string{"--proxy-protocol-sources", "changed"}, callback: func(sc *ServerConfig) { sc.ProxyProtocolSources = []string{"changed"} }},
+		{args: []string{"--custom-domain-hsts", "changed"}, callback: func(sc *ServerConfig) { sc.Headers.Custom.HSTS = "changed" }},
+		{args: []string{"--raw-domain-csp", "changed"}, callback: func(sc *ServerConfig) { sc.Headers.Raw.ContentSecurityPolicy = "changed" }},
+		{args: []string{"--cache-control-default", "changed"}, callback: func(sc *ServerConfig) { sc.CacheControl.Default = "changed" }},
+		{args: []string{"--cache-control-immutable", "changed"}, callback: func(sc *ServerConfig) { sc.CacheControl.Immutable = "changed" }},
+		{args: []string{"--cache-control-errors", "changed"}, callback: func(sc *ServerConfig) { sc.CacheControl.Errors = "changed" }},
 	}
 
 	for _, pair := range testValuePairs {
@@ -333,15 +514,21 @@ func TestMergeServerConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgE
 			t,
 			func(ctx *cli.Context) error {
 				cfg := ServerConfig{
-					Host:               "original",
-					Port:               8080,
-					HttpPort:           80,
-					HttpServerEnabled:  false,
-					MainDomain:         "original",
-					RawDomain:          "original",
-					PagesBranches:      []string{"original"},
-					AllowedCorsDomains: []string{"original"},
-					BlacklistedPaths:   []string{"original"},
+					Host:                 "original",
+					Port:                 8080,
+					HttpPort:             80,
+					HttpServerEnabled:    false,
+					MainDomain:           "original",
+					RawDomain:            "original",
+					PagesBranches:        []string{"original"},
+					AllowedCorsDomains:   []string{"original"},
+					BlacklistedPaths:     []string{"original"},
+					PublicIPs:            []string{"original"},
+					DirectoryListing:     false,
+					ProxyProtocol:        false,
+					ProxyProtocolSources: []string{"original"},
+					Headers:              HeadersConfig{Raw: HeaderPolicy{ContentSecurityPolicy: "original"}, Custom: HeaderPolicy{HSTS: "original"}},
+					CacheControl:         CacheControlConfig{Default: "original", Immutable: "original", Errors: "original", Rules: []CacheControlRule{{Path: "*.html", Value: "original"}}},
 				}
 
 				expectedConfig := cfg
@@ -351,6 +538,8 @@ func TestMergeServerConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgE
 				expectedConfig.PagesBranches = fixArrayFromCtx(ctx, "pages-branch", expectedConfig.PagesBranches)
 				expectedConfig.AllowedCorsDomains = fixArrayFromCtx(ctx, "allowed-cors-domains", expectedConfig.AllowedCorsDomains)
 				expectedConfig.BlacklistedPaths = fixArrayFromCtx(ctx, "blacklisted-paths", expectedConfig.BlacklistedPaths)
+				expectedConfig.PublicIPs = fixArrayFromCtx(ctx, "public-ips", expectedConfig.PublicIPs)
+				expectedConfig.ProxyProtocolSources = fixArrayFromCtx(ctx, "proxy-protocol-sources", expectedConfig.ProxyProtocolSources)
 
 				mergeServerConfig(ctx, &cfg)
 
@@ -374,6 +563,7 @@ func TestMergeGiteaConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *test
 				FollowSymlinks:     false,
 				DefaultMimeType:    "original",
 				ForbiddenMimeTypes: []string{"original"},
+				OwnerTokens:        map[string]string{"original": "original"},
 			}
 
 			mergeGiteaConfig(ctx, cfg)
@@ -385,6 +575,7 @@ func TestMergeGiteaConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *test
 				FollowSymlinks:     true,
 				DefaultMimeType:    "changed",
 				ForbiddenMimeTypes: fixArrayFromCtx(ctx, "forbidden-mime-types", []string{"changed"}),
+				OwnerTokens:        map[string]string{"changed": "changed"},
 			}
 
 			assert.Equal(t, expectedConfig, cfg)
@@ -398,6 +589,7 @@ func TestMergeGiteaConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *test
 			"--enable-symlink-support",
 			"--default-mime-type", "changed",
 			"--forbidden-mime-types", "changed",
+			"--gitea-owner-token", "Changed=changed",
 		},
 	)
 }
@@ -414,6 +606,7 @@ func TestMergeGiteaConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgEx
 		{args: []string{"--enable-symlink-support"}, callback: func(gc *GiteaConfig) { gc.FollowSymlinks = true }},
 		{args: []string{"--default-mime-type", "changed"}, callback: func(gc *GiteaConfig) { gc.DefaultMimeType = "changed" }},
 		{args: []string{"--forbidden-mime-types", "changed"}, callback: func(gc *GiteaConfig) { gc.ForbiddenMimeTypes = []string{"changed"} }},
+		{args: []string{"--gitea-owner-token", "changed=changed"}, callback: func(gc *GiteaConfig) { gc.OwnerTokens = map[string]string{"changed": "changed"} }},
 	}
 
 	for _, pair := range testValuePairs {
@@ -427,6 +620,7 @@ func TestMergeGiteaConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgEx
 					FollowSymlinks:     false,
 					DefaultMimeType:    "original",
 					ForbiddenMimeTypes: []string{"original"},
+					OwnerTokens:        map[string]string{"original": "original"},
 				}
 
 				expectedConfig := cfg
@@ -450,15 +644,19 @@ func TestMergeDatabaseConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *t
 		t,
 		func(ctx *cli.Context) error {
 			cfg := &DatabaseConfig{
-				Type: "original",
-				Conn: "original",
+				Type:              "original",
+				Conn:              "original",
+				EncryptionKey:     "original",
+				EncryptionKeyFile: "original",
 			}
 
 			mergeDatabaseConfig(ctx, cfg)
 
 			expectedConfig := &DatabaseConfig{
-				Type: "changed",
-				Conn: "changed",
+				Type:              "changed",
+				Conn:              "changed",
+				EncryptionKey:     "changed",
+				EncryptionKeyFile: "changed",
 			}
 
 			assert.Equal(t, expectedConfig, cfg)
@@ -468,6 +666,8 @@ func TestMergeDatabaseConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *t
 		[]string{
 			"--db-type", "changed",
 			"--db-conn", "changed",
+			"--db-encryption-key", "changed",
+			"--db-encryption-key-file", "changed",
 		},
 	)
 }
@@ -480,6 +680,8 @@ func TestMergeDatabaseConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneAr
 	testValuePairs := []testValuePair{
 		{args: []string{"--db-type", "changed"}, callback: func(gc *DatabaseConfig) { gc.Type = "changed" }},
 		{args: []string{"--db-conn", "changed"}, callback: func(gc *DatabaseConfig) { gc.Conn = "changed" }},
+		{args: []string{"--db-encryption-key", "changed"}, callback: func(gc *DatabaseConfig) { gc.EncryptionKey = "changed" }},
+		{args: []string{"--db-encryption-key-file", "changed"}, callback: func(gc *DatabaseConfig) { gc.EncryptionKeyFile = "changed" }},
 	}
 
 	for _, pair := range testValuePairs {
@@ -487,8 +689,10 @@ func TestMergeDatabaseConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneAr
 			t,
 			func(ctx *cli.Context) error {
 				cfg := DatabaseConfig{
-					Type: "original",
-					Conn: "original",
+					Type:              "original",
+					Conn:              "original",
+					EncryptionKey:     "original",
+					EncryptionKeyFile: "original",
 				}
 
 				expectedConfig := cfg
@@ -518,6 +722,18 @@ func TestMergeACMEConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testi
 				EAB_KID:           "original",
 				DNSProvider:       "original",
 				AccountConfigFile: "original",
+				KeyType:           "original",
+				SecondaryKeyType:  "original",
+				Quota: QuotaConfig{
+					Certificates:    1,
+					Period:          "original",
+					Owners:          map[string]int{"original": 1},
+					UnlimitedOwners: []string{"original"},
+				},
+				Verification: VerificationConfig{
+					Enabled: false,
+					Secret:  "original",
+				},
 			}
 
 			mergeACMEConfig(ctx, cfg)
@@ -531,6 +747,18 @@ func TestMergeACMEConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testi
 				EAB_KID:           "changed",
 				DNSProvider:       "changed",
 				AccountConfigFile: "changed",
+				KeyType:           "changed",
+				SecondaryKeyType:  "changed",
+				Quota: QuotaConfig{
+					Certificates:    2,
+					Period:          "changed",
+					Owners:          map[string]int{"changed": 2},
+					UnlimitedOwners: fixArrayFromCtx(ctx, "cert-quota-unlimited-owner", []string{"changed"}),
+				},
+				Verification: VerificationConfig{
+					Enabled: true,
+					Secret:  "changed",
+				},
 			}
 
 			assert.Equal(t, expectedConfig, cfg)
@@ -546,6 +774,14 @@ func TestMergeACMEConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testi
 			"--acme-eab-kid", "changed",
 			"--dns-provider", "changed",
 			"--acme-account-config", "changed",
+			"--acme-key-type", "changed",
+			"--acme-secondary-key-type", "changed",
+			"--cert-quota", "2",
+			"--cert-quota-period", "changed",
+			"--cert-quota-owner", "changed=2",
+			"--cert-quota-unlimited-owner", "changed",
+			"--domain-verification",
+			"--domain-verification-secret", "changed",
 		},
 	)
 }
@@ -564,6 +800,14 @@ func TestMergeACMEConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExi
 		{args: []string{"--acme-eab-kid", "changed"}, callback: func(gc *ACMEConfig) { gc.EAB_KID = "changed" }},
 		{args: []string{"--dns-provider", "changed"}, callback: func(gc *ACMEConfig) { gc.DNSProvider = "changed" }},
 		{args: []string{"--acme-account-config", "changed"}, callback: func(gc *ACMEConfig) { gc.AccountConfigFile = "changed" }},
+		{args: []string{"--acme-key-type", "changed"}, callback: func(gc *ACMEConfig) { gc.KeyType = "changed" }},
+		{args: []string{"--acme-secondary-key-type", "changed"}, callback: func(gc *ACMEConfig) { gc.SecondaryKeyType = "changed" }},
+		{args: []string{"--cert-quota", "2"}, callback: func(gc *ACMEConfig) { gc.Quota.Certificates = 2 }},
+		{args: []string{"--cert-quota-period", "changed"}, callback: func(gc *ACMEConfig) { gc.Quota.Period = "changed" }},
+		{args: []string{"--cert-quota-owner", "changed=2"}, callback: func(gc *ACMEConfig) { gc.Quota.Owners = map[string]int{"changed": 2} }},
+		{args: []string{"--cert-quota-unlimited-owner", "changed"}, callback: func(gc *ACMEConfig) { gc.Quota.UnlimitedOwners = []string{"changed"} }},
+		{args: []string{"--domain-verification"}, callback: func(gc *ACMEConfig) { gc.Verification.Enabled = true }},
+		{args: []string{"--domain-verification-secret", "changed"}, callback: func(gc *ACMEConfig) { gc.Verification.Secret = "changed" }},
 	}
 
 	for _, pair := range testValuePairs {
@@ -579,10 +823,23 @@ func TestMergeACMEConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExi
 					EAB_KID:           "original",
 					DNSProvider:       "original",
 					AccountConfigFile: "original",
+					KeyType:           "original",
+					SecondaryKeyType:  "original",
+					Quota: QuotaConfig{
+						Certificates:    1,
+						Period:          "original",
+						Owners:          map[string]int{"original": 1},
+						UnlimitedOwners: []string{"original"},
+					},
+					Verification: VerificationConfig{
+						Enabled: false,
+						Secret:  "original",
+					},
 				}
 
 				expectedConfig := cfg
 				pair.callback(&expectedConfig)
+				expectedConfig.Quota.UnlimitedOwners = fixArrayFromCtx(ctx, "cert-quota-unlimited-owner", expectedConfig.Quota.UnlimitedOwners)
 
 				mergeACMEConfig(ctx, &cfg)
 
@@ -594,3 +851,318 @@ func TestMergeACMEConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExi
 		)
 	}
 }
+
+func TestMergeDNSConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T) {
+	runApp(
+		t,
+		func(ctx *cli.Context) error {
+			cfg := &DNSConfig{
+				Servers:       []string{"original"},
+				Protocol:      "original",
+				Timeout:       "original",
+				RequireDNSSEC: false,
+			}
+
+			mergeDNSConfig(ctx, cfg)
+
+			expectedConfig := &DNSConfig{
+				Servers:       fixArrayFromCtx(ctx, "dns-servers", []string{"changed"}),
+				Protocol:      "changed",
+				Timeout:       "changed",
+				RequireDNSSEC: true,
+			}
+
+			assert.Equal(t, expectedConfig, cfg)
+
+			return nil
+		},
+		[]string{
+			"--dns-servers", "changed",
+			"--dns-protocol", "changed",
+			"--dns-timeout", "changed",
+			"--dns-require-dnssec",
+		},
+	)
+}
+
+func TestMergeDNSConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExists(t *testing.T) {
+	type testValuePair struct {
+		args     []string
+		callback func(*DNSConfig)
+	}
+	testValuePairs := []testValuePair{
+		{args: []string{"--dns-servers", "changed"}, callback: func(dc *DNSConfig) { dc.Servers = []string{"changed"} }},
+		{args: []string{"--dns-protocol", "changed"}, callback: func(dc *DNSConfig) { dc.Protocol = "changed" }},
+		{args: []string{"--dns-timeout", "changed"}, callback: func(dc *DNSConfig) { dc.Timeout = "changed" }},
+		{args: []string{"--dns-require-dnssec"}, callback: func(dc *DNSConfig) { dc.RequireDNSSEC = true }},
+	}
+
+	for _, pair := range testValuePairs {
+		runApp(
+			t,
+			func(ctx *cli.Context) error {
+				cfg := DNSConfig{
+					Servers:       []string{"original"},
+					Protocol:      "original",
+					Timeout:       "original",
+					RequireDNSSEC: false,
+				}
+
+				expectedConfig := cfg
+				pair.callback(&expectedConfig)
+				expectedConfig.Servers = fixArrayFromCtx(ctx, "dns-servers", expectedConfig.Servers)
+
+				mergeDNSConfig(ctx, &cfg)
+
+				assert.Equal(t, expectedConfig, cfg)
+
+				return nil
+			},
+			pair.args,
+		)
+	}
+}
+
+func TestMergeOAuthConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T) {
+	runApp(
+		t,
+		func(ctx *cli.Context) error {
+			cfg := &OAuthConfig{
+				Enabled:        false,
+				ClientID:       "original",
+				ClientSecret:   "original",
+				Domain:         "original",
+				SessionSecret:  "original",
+				SessionTimeout: "original",
+			}
+
+			mergeOAuthConfig(ctx, cfg)
+
+			expectedConfig := &OAuthConfig{
+				Enabled:        true,
+				ClientID:       "changed",
+				ClientSecret:   "changed",
+				Domain:         "changed",
+				SessionSecret:  "changed",
+				SessionTimeout: "changed",
+			}
+
+			assert.Equal(t, expectedConfig, cfg)
+
+			return nil
+		},
+		[]string{
+			"--enable-oauth",
+			"--oauth-client-id", "changed",
+			"--oauth-client-secret", "changed",
+			"--oauth-domain", "changed",
+			"--oauth-session-secret", "changed",
+			"--oauth-session-timeout", "changed",
+		},
+	)
+}
+
+func TestMergeOAuthConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExists(t *testing.T) {
+	type testValuePair struct {
+		args     []string
+		callback func(*OAuthConfig)
+	}
+	testValuePairs := []testValuePair{
+		{args: []string{"--enable-oauth"}, callback: func(oc *OAuthConfig) { oc.Enabled = true }},
+		{args: []string{"--oauth-client-id", "changed"}, callback: func(oc *OAuthConfig) { oc.ClientID = "changed" }},
+		{args: []string{"--oauth-client-secret", "changed"}, callback: func(oc *OAuthConfig) { oc.ClientSecret = "changed" }},
+		{args: []string{"--oauth-domain", "changed"}, callback: func(oc *OAuthConfig) { oc.Domain = "changed" }},
+		{args: []string{"--oauth-session-secret", "changed"}, callback: func(oc *OAuthConfig) { oc.SessionSecret = "changed" }},
+		{args: []string{"--oauth-session-timeout", "changed"}, callback: func(oc *OAuthConfig) { oc.SessionTimeout = "changed" }},
+	}
+
+	for _, pair := range testValuePairs {
+		runApp(
+			t,
+			func(ctx *cli.Context) error {
+				cfg := OAuthConfig{
+					Enabled:        false,
+					ClientID:       "original",
+					ClientSecret:   "original",
+					Domain:         "original",
+					SessionSecret:  "original",
+					SessionTimeout: "original",
+				}
+
+				expectedConfig := cfg
+				pair.callback(&expectedConfig)
+
+				mergeOAuthConfig(ctx, &cfg)
+
+				assert.Equal(t, expectedConfig, cfg)
+
+				return nil
+			},
+			pair.args,
+		)
+	}
+}
+
+func TestMergeRateLimitConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T) {
+	runApp(
+		t,
+		func(ctx *cli.Context) error {
+			cfg := &RateLimitConfig{
+				Enabled:        false,
+				ClientRequests: 1,
+				ClientPeriod:   "original",
+				SiteRequests:   1,
+				SitePeriod:     "original",
+				AllowedClients: []string{"original"},
+				AllowedSites:   []string{"original"},
+				TrustedProxies: []string{"original"},
+			}
+
+			mergeRateLimitConfig(ctx, cfg)
+
+			expectedConfig := &RateLimitConfig{
+				Enabled:        true,
+				ClientRequests: 2,
+				ClientPeriod:   "changed",
+				SiteRequests:   2,
+				SitePeriod:     "changed",
+				AllowedClients: fixArrayFromCtx(ctx, "rate-limit-allowed-clients", []string{"changed"}),
+				AllowedSites:   fixArrayFromCtx(ctx, "rate-limit-allowed-sites", []string{"changed"}),
+				TrustedProxies: fixArrayFromCtx(ctx, "rate-limit-trusted-proxies", []string{"changed"}),
+			}
+
+			assert.Equal(t, expectedConfig, cfg)
+
+			return nil
+		},
+		[]string{
+			"--enable-rate-limit",
+			"--rate-limit-client-requests", "2",
+			"--rate-limit-client-period", "changed",
+			"--rate-limit-site-requests", "2",
+			"--rate-limit-site-period", "changed",
+			"--rate-limit-allowed-clients", "changed",
+			"--rate-limit-allowed-sites", "changed",
+			"--rate-limit-trusted-proxies", "changed",
+		},
+	)
+}
+
+func TestMergeRateLimitConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExists(t *testing.T) {
+	type testValuePair struct {
+		args     []string
+		callback func(*RateLimitConfig)
+	}
+	testValuePairs := []testValuePair{
+		{args: []string{"--enable-rate-limit"}, callback: func(rc *RateLimitConfig) { rc.Enabled = true }},
+		{args: []string{"--rate-limit-client-requests", "2"}, callback: func(rc *RateLimitConfig) { rc.ClientRequests = 2 }},
+		{args: []string{"--rate-limit-client-period", "changed"}, callback: func(rc *RateLimitConfig) { rc.ClientPeriod = "changed" }},
+		{args: []string{"--rate-limit-site-requests", "2"}, callback: func(rc *RateLimitConfig) { rc.SiteRequests = 2 }},
+		{args: []string{"--rate-limit-site-period", "changed"}, callback: func(rc *RateLimitConfig) { rc.SitePeriod = "changed" }},
+		{args: []string{"--rate-limit-allowed-clients", "changed"}, callback: func(rc *RateLimitConfig) { rc.AllowedClients = []string{"changed"} }},
+		{args: []string{"--rate-limit-allowed-sites", "changed"}, callback: func(rc *RateLimitConfig) { rc.AllowedSites = []string{"changed"} }},
+		{args: []string{"--rate-limit-trusted-proxies", "changed"}, callback: func(rc *RateLimitConfig) { rc.TrustedProxies = []string{"changed"} }},
+	}
+
+	for _, pair := range testValuePairs {
+		runApp(
+			t,
+			func(ctx *cli.Context) error {
+				cfg := RateLimitConfig{
+					Enabled:        false,
+					ClientRequests: 1,
+					ClientPeriod:   "original",
+					SiteRequests:   1,
+					SitePeriod:     "original",
+					AllowedClients: []string{"original"},
+					AllowedSites:   []string{"original"},
+					TrustedProxies: []string{"original"},
+				}
+
+				expectedConfig := cfg
+				pair.callback(&expectedConfig)
+
+				mergeRateLimitConfig(ctx, &cfg)
+
+				expectedConfig.AllowedClients = fixArrayFromCtx(ctx, "rate-limit-allowed-clients", expectedConfig.AllowedClients)
+				expectedConfig.AllowedSites = fixArrayFromCtx(ctx, "rate-limit-allowed-sites", expectedConfig.AllowedSites)
+				expectedConfig.TrustedProxies = fixArrayFromCtx(ctx, "rate-limit-trusted-proxies", expectedConfig.TrustedProxies)
+
+				assert.Equal(t, expectedConfig, cfg)
+
+				return nil
+			},
+			pair.args,
+		)
+	}
+}
+
+func TestMergeAdminConfigShouldReplaceAllExistingValuesGivenAllArgsExist(t *testing.T) {
+	runApp(
+		t,
+		func(ctx *cli.Context) error {
+			cfg := &AdminConfig{
+				Enabled: false,
+				Host:    "original",
+				Port:    9090,
+				Token:   "original",
+			}
+
+			mergeAdminConfig(ctx, cfg)
+
+			expectedConfig := &AdminConfig{
+				Enabled: true,
+				Host:    "changed",
+				Port:    9091,
+				Token:   "changed",
+			}
+
+			assert.Equal(t, expectedConfig, cfg)
+
+			return nil
+		},
+		[]string{
+			"--enable-admin-api",
+			"--admin-host", "changed",
+			"--admin-port", "9091",
+			"--admin-token", "changed",
+		},
+	)
+}
+
+func TestMergeAdminConfigShouldReplaceOnlyOneValueExistingValueGivenOnlyOneArgExists(t *testing.T) {
+	type testValuePair struct {
+		args     []string
+		callback func(*AdminConfig)
+	}
+	testValuePairs := []testValuePair{
+		{args: []string{"--enable-admin-api"}, callback: func(ac *AdminConfig) { ac.Enabled = true }},
+		{args: []string{"--admin-host", "changed"}, callback: func(ac *AdminConfig) { ac.Host = "changed" }},
+		{args: []string{"--admin-port", "9091"}, callback: func(ac *AdminConfig) { ac.Port = 9091 }},
+		{args: []string{"--admin-token", "changed"}, callback: func(ac *AdminConfig) { ac.Token = "changed" }},
+	}
+
+	for _, pair := range testValuePairs {
+		runApp(
+			t,
+			func(ctx *cli.Context) error {
+				cfg := AdminConfig{
+					Enabled: false,
+					Host:    "original",
+					Port:    9090,
+					Token:   "original",
+				}
+
+				expectedConfig := cfg
+				pair.callback(&expectedConfig)
+
+				mergeAdminConfig(ctx, &cfg)
+
+				assert.Equal(t, expectedConfig, cfg)
+
+				return nil
+			},
+			pair.args,
+		)
+	}
+}
@@ -0,0 +1,128 @@
+package html
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template" // file names are user content, so they are escaped by the template
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+//go:embed templates/directory.html
+var directoryPage string
+
+var directoryTemplate = template.Must(template.New("directory").Funcs(template.FuncMap{
+	"formatSize": formatSize,
+	"formatDate": formatDate,
+}).Parse(directoryPage))
+
+// Sort orders of directory listings
+const (
+	SortByName = "name"
+	SortBySize = "size"
+	SortByDate = "date"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// DirectoryEntry is a file or directory shown in a directory listing.
+type DirectoryEntry struct {
+	Name         string
+	Href         string
+	IsDir        bool
+	Size         int64
+	LastModified time.Time
+}
+
+// DirectoryListing is the context of the directory listing template.
+type DirectoryListing struct {
+	Path string
+	// Parent links to the parent directory, empty for the root directory
+	Parent  string
+	Sort    string
+	Order   string
+	Entries []DirectoryEntry
+}
+
+// NextOrder returns the order a column header links to, it reverses the current order of the sorted column.
+func (l DirectoryListing) NextOrder(column string) string {
+	if l.Sort == column && l.Order == OrderAsc {
+		return OrderDesc
+	}
+	return OrderAsc
+}
+
+// ReturnDirectoryListing sorts the entries as requested by the sort and order query parameters
+// and writes the directory listing to the response body.
+func ReturnDirectoryListing(ctx *context.Context, listing DirectoryListing) {
+	listing.Sort, listing.Order = SortByName, OrderAsc
+	if ctx.Req != nil {
+		switch sortBy := ctx.Req.URL.Query().Get("sort"); sortBy {
+		case SortByName, SortBySize, SortByDate:
+			listing.Sort = sortBy
+		}
+		if ctx.Req.URL.Query().Get("order") == OrderDesc {
+			listing.Order = OrderDesc
+		}
+	}
+	sortDirectoryEntries(listing.Entries, listing.Sort, listing.Order == OrderDesc)
+
+	ctx.RespWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.RespWriter.WriteHeader(http.StatusOK)
+
+	if err := directoryTemplate.Execute(ctx.RespWriter, listing); err != nil {
+		log.Err(err).Str("path", listing.Path).Msg("could not write directory listing")
+	}
+}
+
+// sortDirectoryEntries sorts directories before files, and both by the given column.
+func sortDirectoryEntries(entries []DirectoryEntry, sortBy string, desc bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case SortBySize:
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case SortByDate:
+			if !a.LastModified.Equal(b.LastModified) {
+				return a.LastModified.Before(b.LastModified)
+			}
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
+
+// formatSize formats a file size with binary prefixes, e.g. "1.5 KiB".
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return "-"
+	}
+	return date.UTC().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,61 @@
+package html
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+func TestSortDirectoryEntries(t *testing.T) {
+	older := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	entries := []DirectoryEntry{
+		{Name: "b.txt", Size: 10, LastModified: older},
+		{Name: "docs", IsDir: true},
+		{Name: "A.txt", Size: 20, LastModified: newer},
+	}
+	names := func() []string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		return names
+	}
+
+	sortDirectoryEntries(entries, SortByName, false)
+	assert.EqualValues(t, []string{"docs", "A.txt", "b.txt"}, names())
+	sortDirectoryEntries(entries, SortByName, true)
+	assert.EqualValues(t, []string{"docs", "b.txt", "A.txt"}, names())
+	sortDirectoryEntries(entries, SortBySize, false)
+	assert.EqualValues(t, []string{"docs", "b.txt", "A.txt"}, names())
+	sortDirectoryEntries(entries, SortByDate, true)
+	assert.EqualValues(t, []string{"docs", "A.txt", "b.txt"}, names())
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.EqualValues(t, "0 B", formatSize(0))
+	assert.EqualValues(t, "1023 B", formatSize(1023))
+	assert.EqualValues(t, "1.5 KiB", formatSize(1536))
+	assert.EqualValues(t, "2.0 MiB", formatSize(2*1024*1024))
+}
+
+func TestReturnDirectoryListingEscapesNames(t *testing.T) {
+	resp := httptest.NewRecorder()
+	ctx := context.New(resp, httptest.NewRequest("GET", "/files/?sort=size&order=desc", nil))
+
+	ReturnDirectoryListing(ctx, DirectoryListing{
+		Path:    "/files/",
+		Parent:  "/",
+		Entries: []DirectoryEntry{{Name: "<script>.txt", Href: "/files/%3Cscript%3E.txt", Size: 1}},
+	})
+
+	assert.EqualValues(t, 200, resp.Code)
+	assert.Contains(t, resp.Body.String(), "&lt;script&gt;.txt")
+	assert.NotContains(t, resp.Body.String(), "<script>")
+	// the sorted column links to the reversed order
+	assert.Contains(t, resp.Body.String(), `href="?sort=size&amp;order=asc"`)
+}
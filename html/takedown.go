@@ -0,0 +1,40 @@
+package html
+
+import (
+	_ "embed"
+	"html/template" // the reason is entered by operators, but escaping it doesn't hurt
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+//go:embed templates/takedown.html
+var takedownPage string
+
+var takedownTemplate = template.Must(template.New("takedown").Parse(takedownPage))
+
+// Takedown is the context of the takedown template.
+type Takedown struct {
+	StatusCode int
+	StatusText string
+	Reason     string
+}
+
+// ReturnTakedownPage writes the page served instead of a site that was taken down, statusCode is 451 or 403.
+func ReturnTakedownPage(ctx *context.Context, reason string, statusCode int) {
+	ctx.RespWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the takedown may be lifted, so the page must not be cached
+	ctx.RespWriter.Header().Set("Cache-Control", "no-store")
+	ctx.RespWriter.WriteHeader(statusCode)
+
+	takedown := Takedown{
+		StatusCode: statusCode,
+		StatusText: http.StatusText(statusCode),
+		Reason:     reason,
+	}
+	if err := takedownTemplate.Execute(ctx.RespWriter, takedown); err != nil {
+		log.Err(err).Int("status", statusCode).Msg("could not write takedown page")
+	}
+}
@@ -0,0 +1,22 @@
+package html
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+func TestReturnTakedownPage(t *testing.T) {
+	resp := httptest.NewRecorder()
+	ReturnTakedownPage(context.New(resp, nil), "phishing <script>alert(1)</script>", http.StatusUnavailableForLegalReasons)
+
+	assert.EqualValues(t, http.StatusUnavailableForLegalReasons, resp.Code)
+	assert.EqualValues(t, "no-store", resp.Header().Get("Cache-Control"))
+	assert.Contains(t, resp.Body.String(), "Unavailable For Legal Reasons (451)")
+	assert.Contains(t, resp.Body.String(), "phishing &lt;script&gt;")
+	assert.NotContains(t, resp.Body.String(), "<script>")
+}
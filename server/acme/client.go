@@ -7,19 +7,37 @@ import (
 	"codeberg.org/codeberg/pages/config"
 	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/certificates"
+	"codeberg.org/codeberg/pages/server/database"
 )
 
 var ErrAcmeMissConfig = errors.New("ACME client has wrong config")
 
 func CreateAcmeClient(cfg config.ACMEConfig, enableHTTPServer bool, challengeCache cache.ICache) (*certificates.AcmeClient, error) {
 	// check config
-	if (!cfg.AcceptTerms || cfg.DNSProvider == "") && cfg.APIEndpoint != "https://acme.mock.directory" {
-		return nil, fmt.Errorf("%w: you must set $ACME_ACCEPT_TERMS and $DNS_PROVIDER, unless $ACME_API is set to https://acme.mock.directory", ErrAcmeMissConfig)
+	accountFiles := map[string]string{}
+	for _, issuer := range certificates.IssuerConfigs(cfg) {
+		if (!cfg.AcceptTerms || cfg.DNSProvider == "") && issuer.APIEndpoint != "https://acme.mock.directory" {
+			return nil, fmt.Errorf("%w: you must set $ACME_ACCEPT_TERMS and $DNS_PROVIDER, unless $ACME_API is set to https://acme.mock.directory", ErrAcmeMissConfig)
+		}
+		if issuer.EAB_HMAC != "" && issuer.EAB_KID == "" {
+			return nil, fmt.Errorf("%w: ACME_EAB_HMAC also needs ACME_EAB_KID to be set", ErrAcmeMissConfig)
+		} else if issuer.EAB_HMAC == "" && issuer.EAB_KID != "" {
+			return nil, fmt.Errorf("%w: ACME_EAB_KID also needs ACME_EAB_HMAC to be set", ErrAcmeMissConfig)
+		}
+		// every issuer needs its own account
+		if other, ok := accountFiles[issuer.AccountConfigFile]; ok {
+			return nil, fmt.Errorf("%w: issuers %q and %q use the same account config file", ErrAcmeMissConfig, other, issuer.Name)
+		}
+		accountFiles[issuer.AccountConfigFile] = issuer.Name
 	}
-	if cfg.EAB_HMAC != "" && cfg.EAB_KID == "" {
-		return nil, fmt.Errorf("%w: ACME_EAB_HMAC also needs ACME_EAB_KID to be set", ErrAcmeMissConfig)
-	} else if cfg.EAB_HMAC == "" && cfg.EAB_KID != "" {
-		return nil, fmt.Errorf("%w: ACME_EAB_KID also needs ACME_EAB_HMAC to be set", ErrAcmeMissConfig)
+	if cfg.Verification.Enabled && cfg.Verification.Secret == "" {
+		return nil, fmt.Errorf("%w: $DOMAIN_VERIFICATION needs $DOMAIN_VERIFICATION_SECRET to be set", ErrAcmeMissConfig)
+	}
+	if !database.ValidKeyType(cfg.KeyType) {
+		return nil, fmt.Errorf("%w: unknown ACME_KEY_TYPE %q", ErrAcmeMissConfig, cfg.KeyType)
+	}
+	if cfg.SecondaryKeyType != "" && !database.ValidKeyType(cfg.SecondaryKeyType) {
+		return nil, fmt.Errorf("%w: unknown ACME_SECONDARY_KEY_TYPE %q", ErrAcmeMissConfig, cfg.SecondaryKeyType)
 	}
 
 	return certificates.NewAcmeClient(cfg, enableHTTPServer, challengeCache)
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/certificates"
+	"codeberg.org/codeberg/pages/server/database"
+	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/takedown"
+)
+
+var errNotFound = errors.New("not found")
+
+// API serves the admin endpoints. It is meant to be exposed on a separate, non-public listener.
+type API struct {
+	Config      config.Config
+	CertDB      database.CertDB
+	AcmeClient  *certificates.AcmeClient
+	Quota       *certificates.QuotaPolicy
+	GiteaClient *gitea.Client
+	// Takedowns are reloaded whenever a takedown is changed
+	Takedowns *takedown.List
+
+	KeyCache             cache.ICache
+	DNSLookupCache       cache.ICache
+	CanonicalDomainCache cache.ICache
+	RedirectsCache       cache.ICache
+	SiteConfigCache      cache.ICache
+	QuotaExceededCache   cache.ICache
+}
+
+// Handler returns the http handler of the admin api, all requests have to carry the admin token as bearer token.
+func (a *API) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log := log.With().Str("admin", req.Method+" "+req.URL.Path).Logger()
+
+		if !a.authorized(req) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pages-server admin"`)
+			writeError(w, errors.New("invalid or missing admin token"), http.StatusUnauthorized)
+			return
+		}
+
+		pathElements := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		var err error
+		switch pathElements[0] {
+		case "certs":
+			err = a.handleCerts(w, req, pathElements[1:])
+		case "cache":
+			err = a.handleCache(w, req, pathElements[1:])
+		case "quotas":
+			err = a.handleQuotas(w, req, pathElements[1:])
+		case "domains":
+			err = a.handleDomains(w, req, pathElements[1:])
+		case "tokens":
+			err = a.handleTokens(w, req, pathElements[1:])
+		case "takedowns":
+			err = a.handleTakedowns(w, req, pathElements[1:])
+		case "config":
+			err = a.handleConfig(w, req, pathElements[1:])
+		default:
+			err = errNotFound
+		}
+
+		if err != nil {
+			log.Debug().Err(err).Msg("admin request failed")
+			switch {
+			case errors.Is(err, errNotFound), errors.Is(err, database.ErrNotFound):
+				writeError(w, err, http.StatusNotFound)
+			case errors.Is(err, errMethodNotAllowed):
+				writeError(w, err, http.StatusMethodNotAllowed)
+			case errors.Is(err, errBadRequest):
+				writeError(w, err, http.StatusBadRequest)
+			default:
+				log.Error().Err(err).Msg("admin request failed")
+				writeError(w, err, http.StatusInternalServerError)
+			}
+		}
+	}
+}
+
+func (a *API) authorized(req *http.Request) bool {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found || a.Config.Admin.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.Config.Admin.Token)) == 1
+}
+
+func (a *API) handleConfig(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	if len(pathElements) != 0 {
+		return errNotFound
+	}
+	if req.Method != http.MethodGet {
+		return errMethodNotAllowed
+	}
+	return writeJSON(w, a.Config.Redacted(), http.StatusOK)
+}
+
+func writeJSON(w http.ResponseWriter, v any, status int) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error, status int) {
+	if err := writeJSON(w, map[string]string{"error": err.Error()}, status); err != nil {
+		log.Error().Err(err).Msg("could not write admin response")
+	}
+}
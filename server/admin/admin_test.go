@@ -0,0 +1,226 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/certificates"
+	"codeberg.org/codeberg/pages/server/database"
+	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/takedown"
+)
+
+func newTestAPI(t *testing.T) (*API, *database.MockCertDB) {
+	cfg := config.NewDefaultConfig()
+	cfg.Server.MainDomain = ".codeberg.page"
+	cfg.Gitea.Token = "gitea-secret"
+	cfg.Gitea.OwnerTokens = map[string]string{"example": "owner-secret"}
+	cfg.Admin.Token = "admin-secret"
+	cfg.ACME.Issuers = []config.ACMEIssuerConfig{{Name: "zerossl", EAB_HMAC: "eab-secret"}}
+	cfg.ACME.Verification.Secret = "verification-secret"
+	cfg.OAuth.ClientSecret = "oauth-client-secret"
+	cfg.OAuth.SessionSecret = "oauth-session-secret"
+
+	cfg.ACME.Quota.Owners = map[string]int{"big-org": 100}
+	quota, err := certificates.NewQuotaPolicy(cfg.ACME.Quota)
+	assert.NoError(t, err)
+
+	certDB := database.NewMockCertDB(t)
+	return &API{Config: cfg, CertDB: certDB, Quota: quota, QuotaExceededCache: cache.NewInMemoryCache()}, certDB
+}
+
+func doRequest(api *API, method, target, token string) *http.Response {
+	req := httptest.NewRequest(method, target, http.NoBody)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	api.Handler()(w, req)
+	return w.Result()
+}
+
+func TestAdminAPIRequiresToken(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	assert.EqualValues(t, http.StatusUnauthorized, doRequest(api, http.MethodGet, "/config", "").StatusCode)
+	assert.EqualValues(t, http.StatusUnauthorized, doRequest(api, http.MethodGet, "/config", "wrong").StatusCode)
+	assert.EqualValues(t, http.StatusOK, doRequest(api, http.MethodGet, "/config", "admin-secret").StatusCode)
+
+	// an empty admin token must never grant access
+	api.Config.Admin.Token = ""
+	assert.EqualValues(t, http.StatusUnauthorized, doRequest(api, http.MethodGet, "/config", "").StatusCode)
+}
+
+func TestAdminAPIConfigIsRedacted(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	resp := doRequest(api, http.MethodGet, "/config", "admin-secret")
+	var cfg config.Config
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&cfg))
+	assert.EqualValues(t, "[redacted]", cfg.Gitea.Token)
+	assert.EqualValues(t, map[string]string{"example": "[redacted]"}, cfg.Gitea.OwnerTokens)
+	assert.EqualValues(t, "[redacted]", cfg.Admin.Token)
+	assert.EqualValues(t, "[redacted]", cfg.ACME.Issuers[0].EAB_HMAC)
+	assert.EqualValues(t, "[redacted]", cfg.ACME.Verification.Secret)
+	assert.EqualValues(t, "[redacted]", cfg.OAuth.ClientSecret)
+	assert.EqualValues(t, "[redacted]", cfg.OAuth.SessionSecret)
+	assert.EqualValues(t, ".codeberg.page", cfg.Server.MainDomain)
+	// the running config keeps its secrets
+	assert.EqualValues(t, "eab-secret", api.Config.ACME.Issuers[0].EAB_HMAC)
+	assert.EqualValues(t, "owner-secret", api.Config.Gitea.OwnerTokens["example"])
+}
+
+func TestAdminAPIListCerts(t *testing.T) {
+	api, certDB := newTestAPI(t)
+	certDB.On("Items", 0, 0).Return([]*database.Cert{{Domain: "example.com", ValidTill: 1700000000}}, nil)
+
+	resp := doRequest(api, http.MethodGet, "/certs", "admin-secret")
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+
+	var list []certListEntry
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	if assert.Len(t, list, 1) {
+		assert.EqualValues(t, "example.com", list[0].Domain)
+		assert.EqualValues(t, 1700000000, list[0].ValidTill.Unix())
+	}
+
+	assert.EqualValues(t, http.StatusMethodNotAllowed, doRequest(api, http.MethodPut, "/certs", "admin-secret").StatusCode)
+	assert.EqualValues(t, http.StatusNotFound, doRequest(api, http.MethodGet, "/unknown", "admin-secret").StatusCode)
+}
+
+func TestAdminAPIQuotas(t *testing.T) {
+	api, certDB := newTestAPI(t)
+	now := time.Now()
+	certDB.On("Quotas").Return([]*database.Quota{
+		{Owner: "big-org", Used: 3, PeriodStart: now.Unix()},
+		{Owner: "someone", Used: 10, PeriodStart: now.Add(-48 * time.Hour).Unix()},
+	}, nil)
+
+	resp := doRequest(api, http.MethodGet, "/quotas", "admin-secret")
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	var list []quotaEntry
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	if assert.Len(t, list, 2) {
+		assert.EqualValues(t, 3, list[0].Used)
+		assert.EqualValues(t, 100, list[0].Limit)
+		assert.NotNil(t, list[0].ResetsAt)
+		// the period of the second owner is over
+		assert.EqualValues(t, 0, list[1].Used)
+		assert.EqualValues(t, 10, list[1].Limit)
+		assert.Nil(t, list[1].ResetsAt)
+	}
+
+	certDB.On("GetQuota", "new-owner").Return(nil, database.ErrNotFound)
+	resp = doRequest(api, http.MethodGet, "/quotas/New-Owner", "admin-secret")
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	var entry quotaEntry
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&entry))
+	assert.EqualValues(t, quotaEntry{Owner: "new-owner", Limit: 10}, entry)
+
+	certDB.On("ResetQuota", "someone").Return(nil).Once()
+	assert.EqualValues(t, http.StatusNoContent, doRequest(api, http.MethodDelete, "/quotas/someone", "admin-secret").StatusCode)
+}
+
+func TestAdminAPITokens(t *testing.T) {
+	api, certDB := newTestAPI(t)
+	// the client is only used to purge its cache, so the server doesn't need to be reachable
+	api.GiteaClient, _ = gitea.NewClient(config.GiteaConfig{Root: "http://127.0.0.1:1"}, cache.NewInMemoryCache(), certDB)
+	api.CanonicalDomainCache = cache.NewInMemoryCache()
+	api.RedirectsCache = cache.NewInMemoryCache()
+	api.SiteConfigCache = cache.NewInMemoryCache()
+	assert.NoError(t, api.SiteConfigCache.Set("example/pages/main", "cached", time.Hour))
+
+	certDB.On("DeployTokens").Return([]*database.DeployToken{
+		{Owner: "example", Repo: "pages", Token: []byte("deploy-secret"), Created: 1700000000},
+	}, nil)
+	resp := doRequest(api, http.MethodGet, "/tokens", "admin-secret")
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "deploy-secret")
+	var list []tokenEntry
+	assert.NoError(t, json.Unmarshal(body, &list))
+	if assert.Len(t, list, 1) {
+		assert.EqualValues(t, "pages", list[0].Repo)
+		assert.EqualValues(t, 1700000000, list[0].Created.Unix())
+	}
+
+	putToken := func(target, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		api.Handler()(w, req)
+		return w.Result()
+	}
+	assert.EqualValues(t, http.StatusBadRequest, putToken("/tokens/example/pages", `{"token": " "}`).StatusCode)
+	assert.EqualValues(t, http.StatusBadRequest, putToken("/tokens/example/pages", `token`).StatusCode)
+	assert.EqualValues(t, http.StatusNotFound, putToken("/tokens/example", `{"token": "new"}`).StatusCode)
+
+	certDB.On("PutDeployToken", &database.DeployToken{Owner: "example", Repo: "pages", Token: []byte("new")}).Return(nil).Once()
+	assert.EqualValues(t, http.StatusNoContent, putToken("/tokens/Example/Pages", `{"token": "new"}`).StatusCode)
+	// the site must be read again with the new token
+	_, ok := api.SiteConfigCache.Get("example/pages/main")
+	assert.False(t, ok)
+
+	// the site caches use lowercase owners and repos as well
+	assert.NoError(t, api.SiteConfigCache.Set("example/pages/main", "cached", time.Hour))
+	assert.EqualValues(t, http.StatusOK, doRequest(api, http.MethodPost, "/cache/purge?owner=Example&repo=Pages", "admin-secret").StatusCode)
+	_, ok = api.SiteConfigCache.Get("example/pages/main")
+	assert.False(t, ok)
+
+	certDB.On("DeleteDeployToken", "example", "pages").Return(nil).Once()
+	assert.EqualValues(t, http.StatusNoContent, doRequest(api, http.MethodDelete, "/tokens/example/pages", "admin-secret").StatusCode)
+	assert.EqualValues(t, http.StatusMethodNotAllowed, doRequest(api, http.MethodPost, "/tokens", "admin-secret").StatusCode)
+}
+
+func TestAdminAPITakedowns(t *testing.T) {
+	api, certDB := newTestAPI(t)
+	certDB.On("Takedowns").Return([]*database.Takedown{}, nil).Once()
+	takedowns, err := takedown.New(certDB)
+	assert.NoError(t, err)
+	api.Takedowns = takedowns
+
+	putTakedown := func(target, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		api.Handler()(w, req)
+		return w.Result()
+	}
+	assert.EqualValues(t, http.StatusBadRequest, putTakedown("/takedowns/repo/example", `{}`).StatusCode)
+	assert.EqualValues(t, http.StatusBadRequest, putTakedown("/takedowns/user/example", `{}`).StatusCode)
+	assert.EqualValues(t, http.StatusBadRequest, putTakedown("/takedowns/owner/example", `{"status": 404}`).StatusCode)
+	assert.EqualValues(t, http.StatusNotFound, putTakedown("/takedowns/owner", `{}`).StatusCode)
+
+	phishing := &database.Takedown{Kind: database.TakedownBranch, Target: "example/pages/Phishing", Reason: "phishing", Status: http.StatusUnavailableForLegalReasons}
+	certDB.On("PutTakedown", phishing).Return(nil).Once()
+	// the takedown applies at once
+	certDB.On("Takedowns").Return([]*database.Takedown{phishing}, nil).Once()
+	assert.EqualValues(t, http.StatusNoContent, putTakedown("/takedowns/branch/Example/Pages/Phishing", `{"reason": "phishing"}`).StatusCode)
+	assert.Equal(t, phishing, api.Takedowns.Site("example", "pages", "Phishing"))
+
+	certDB.On("Takedowns").Return([]*database.Takedown{phishing}, nil).Once()
+	resp := doRequest(api, http.MethodGet, "/takedowns", "admin-secret")
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	var list []takedownEntry
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	if assert.Len(t, list, 1) {
+		assert.EqualValues(t, "example/pages/Phishing", list[0].Target)
+		assert.EqualValues(t, "phishing", list[0].Reason)
+	}
+
+	certDB.On("DeleteTakedown", database.TakedownBranch, "example/pages/Phishing").Return(nil).Once()
+	certDB.On("Takedowns").Return([]*database.Takedown{}, nil).Once()
+	assert.EqualValues(t, http.StatusNoContent, doRequest(api, http.MethodDelete, "/takedowns/branch/example/pages/Phishing", "admin-secret").StatusCode)
+	assert.Nil(t, api.Takedowns.Site("example", "pages", "Phishing"))
+	assert.EqualValues(t, http.StatusMethodNotAllowed, doRequest(api, http.MethodPost, "/takedowns", "admin-secret").StatusCode)
+}
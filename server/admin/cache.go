@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"codeberg.org/codeberg/pages/server/cache"
+)
+
+type purgeResult struct {
+	Owner   string `json:"owner"`
+	Repo    string `json:"repo,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	Removed int    `json:"removed"`
+}
+
+// handleCache serves
+//
+//	POST /cache/purge?owner=&repo=&branch=  drop all cached data of an owner, repo or branch
+func (a *API) handleCache(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	if len(pathElements) != 1 || pathElements[0] != "purge" {
+		return errNotFound
+	}
+	if req.Method != http.MethodPost {
+		return errMethodNotAllowed
+	}
+
+	query := req.URL.Query()
+	owner := query.Get("owner")
+	repo := query.Get("repo")
+	branch := query.Get("branch")
+	if owner == "" {
+		return fmt.Errorf("%w: owner is required", errBadRequest)
+	}
+	if repo == "" && branch != "" {
+		return fmt.Errorf("%w: branch requires repo to be set", errBadRequest)
+	}
+
+	return writeJSON(w, purgeResult{
+		Owner:   owner,
+		Repo:    repo,
+		Branch:  branch,
+		Removed: a.purgeCache(owner, repo, branch),
+	}, http.StatusOK)
+}
+
+// purgeCache drops all cached data of an owner, optionally limited to a repo and a branch, and returns the number of removed entries.
+func (a *API) purgeCache(owner, repo, branch string) int {
+	// canonical domains, site configs and redirects are cached per "owner/repo/branch", redirects of publish directories per "owner/repo/branch:dir"
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+	prefix := owner + "/"
+	if repo != "" {
+		prefix += repo + "/"
+		if branch != "" {
+			prefix += branch
+		}
+	}
+
+	removed := a.GiteaClient.PurgeCache(owner, repo, branch)
+	for _, c := range []cache.ICache{a.CanonicalDomainCache, a.RedirectsCache, a.SiteConfigCache} {
+		if branch != "" {
+			if _, ok := c.Get(prefix); ok {
+				removed++
+			}
+			c.Remove(prefix)
+			removed += cache.RemovePrefix(c, prefix+":")
+		} else {
+			removed += cache.RemovePrefix(c, prefix)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+var (
+	errMethodNotAllowed = errors.New("method not allowed")
+	errBadRequest       = errors.New("bad request")
+)
+
+type certListEntry struct {
+	Domain    string    `json:"domain"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ValidTill time.Time `json:"valid_till"`
+	Issuer    string    `json:"issuer,omitempty"`
+	// renewal state, only set while a renewal is scheduled
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+	Attempts    int        `json:"attempts,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// handleCerts serves
+//
+//	GET    /certs                 list all certificates (supports ?page=&page_size=)
+//	GET    /certs/{domain}        inspect a certificate
+//	DELETE /certs/{domain}        delete a certificate
+//	POST   /certs/{domain}/renew  renew a certificate right away
+//
+// Certificates with another than the default key type are selected with ?key_type=, e.g. ?key_type=EC256.
+func (a *API) handleCerts(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	if len(pathElements) > 0 {
+		// wildcard certs are stored and cached by their suffix
+		pathElements[0] = strings.TrimPrefix(strings.ToLower(pathElements[0]), "*")
+
+		if keyType := strings.ToUpper(req.URL.Query().Get("key_type")); keyType != "" {
+			if !database.ValidKeyType(keyType) {
+				return fmt.Errorf("%w: invalid key_type %q", errBadRequest, keyType)
+			}
+			pathElements[0] = database.NameWithKeyType(pathElements[0], keyType)
+		}
+	}
+
+	switch {
+	case len(pathElements) == 0:
+		if req.Method != http.MethodGet {
+			return errMethodNotAllowed
+		}
+		return a.listCerts(w, req)
+
+	case len(pathElements) == 1:
+		switch req.Method {
+		case http.MethodGet:
+			return a.showCert(w, pathElements[0])
+		case http.MethodDelete:
+			return a.deleteCert(w, pathElements[0])
+		default:
+			return errMethodNotAllowed
+		}
+
+	case len(pathElements) == 2 && pathElements[1] == "renew":
+		if req.Method != http.MethodPost {
+			return errMethodNotAllowed
+		}
+		return a.renewCert(w, pathElements[0])
+	}
+
+	return errNotFound
+}
+
+func (a *API) listCerts(w http.ResponseWriter, req *http.Request) error {
+	page, pageSize := 0, 0
+	if v := req.URL.Query().Get("page"); v != "" {
+		var err error
+		if page, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("%w: invalid page %q", errBadRequest, v)
+		}
+	}
+	if v := req.URL.Query().Get("page_size"); v != "" {
+		var err error
+		if pageSize, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("%w: invalid page_size %q", errBadRequest, v)
+		}
+	}
+
+	certs, err := a.CertDB.Items(page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	list := make([]certListEntry, 0, len(certs))
+	for _, cert := range certs {
+		entry := certListEntry{
+			Domain:    cert.Domain,
+			Created:   time.Unix(cert.Created, 0),
+			Updated:   time.Unix(cert.Updated, 0),
+			ValidTill: time.Unix(cert.ValidTill, 0),
+			Issuer:    cert.Issuer,
+			Attempts:  cert.Attempts,
+			LastError: cert.LastError,
+		}
+		if cert.NextAttempt > 0 {
+			nextAttempt := time.Unix(cert.NextAttempt, 0)
+			entry.NextAttempt = &nextAttempt
+		}
+		list = append(list, entry)
+	}
+	return writeJSON(w, list, http.StatusOK)
+}
+
+func (a *API) showCert(w http.ResponseWriter, domain string) error {
+	res, err := a.CertDB.Get(domain)
+	if err != nil {
+		return err
+	}
+	info, err := database.ParseCertInfo(res)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, info, http.StatusOK)
+}
+
+func (a *API) deleteCert(w http.ResponseWriter, domain string) error {
+	if _, err := a.CertDB.Get(domain); err != nil {
+		return err
+	}
+	if err := a.CertDB.Delete(domain); err != nil {
+		return err
+	}
+	a.KeyCache.Remove(domain)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) renewCert(w http.ResponseWriter, domain string) error {
+	if err := a.AcmeClient.RenewCert(domain, a.Config.Server.MainDomain, a.CertDB); err != nil {
+		return err
+	}
+	a.KeyCache.Remove(domain)
+	return a.showCert(w, domain)
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+
+	"codeberg.org/codeberg/pages/server/dns"
+	"codeberg.org/codeberg/pages/server/upstream"
+)
+
+type domainInfo struct {
+	Host            string `json:"host"`
+	Kind            string `json:"kind"`
+	TargetOwner     string `json:"target_owner,omitempty"`
+	TargetRepo      string `json:"target_repo,omitempty"`
+	TargetBranch    string `json:"target_branch,omitempty"`
+	TargetDir       string `json:"target_dir,omitempty"`
+	CanonicalDomain string `json:"canonical_domain,omitempty"`
+	Valid           bool   `json:"valid"`
+	Error           string `json:"error,omitempty"`
+}
+
+// handleDomains serves
+//
+//	GET /domains/{host}  show the resolved target and canonical domain of a host
+func (a *API) handleDomains(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	if len(pathElements) != 1 {
+		return errNotFound
+	}
+	if req.Method != http.MethodGet {
+		return errMethodNotAllowed
+	}
+
+	mainDomainSuffix := a.Config.Server.MainDomain
+	host := strings.ToLower(pathElements[0])
+	info := domainInfo{Host: host}
+
+	switch {
+	case a.Config.Server.RawDomain != "" && strings.EqualFold(host, a.Config.Server.RawDomain):
+		info.Kind = "raw"
+		info.Valid = true
+		return writeJSON(w, info, http.StatusOK)
+	case strings.EqualFold(host, mainDomainSuffix[1:]):
+		info.Kind = "main"
+		info.Valid = true
+		return writeJSON(w, info, http.StatusOK)
+	case strings.HasSuffix(host, mainDomainSuffix):
+		info.Kind = "subdomain"
+		info.TargetOwner = strings.TrimSuffix(host, mainDomainSuffix)
+		info.TargetRepo = "pages"
+	default:
+		info.Kind = "custom"
+		target, err := dns.GetTargetFromDNS(host, mainDomainSuffix, a.Config.Server.PagesBranches[0], a.DNSLookupCache)
+		if err != nil {
+			info.Error = err.Error()
+		}
+		info.TargetOwner, info.TargetRepo, info.TargetBranch, info.TargetDir = target.Owner, target.Repo, target.Branch, target.Dir
+		if info.TargetOwner == "" {
+			return writeJSON(w, info, http.StatusOK)
+		}
+	}
+
+	targetOpt := &upstream.Options{
+		TargetOwner:  info.TargetOwner,
+		TargetRepo:   info.TargetRepo,
+		TargetBranch: info.TargetBranch,
+	}
+	if _, err := targetOpt.GetBranchTimestamp(a.GiteaClient); err == nil {
+		info.TargetBranch = targetOpt.TargetBranch
+	}
+	info.CanonicalDomain, info.Valid = targetOpt.CheckCanonicalDomain(a.GiteaClient, host, mainDomainSuffix, a.CanonicalDomainCache)
+
+	return writeJSON(w, info, http.StatusOK)
+}
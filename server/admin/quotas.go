@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"codeberg.org/codeberg/pages/server/certificates"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+type quotaEntry struct {
+	Owner string `json:"owner"`
+	// Used certificates in the current period
+	Used      int        `json:"used"`
+	Limit     int        `json:"limit"`
+	Unlimited bool       `json:"unlimited,omitempty"`
+	ResetsAt  *time.Time `json:"resets_at,omitempty"`
+}
+
+// handleQuotas serves
+//
+//	GET    /quotas          list the certificate quotas of all owners that obtained certificates
+//	GET    /quotas/{owner}  inspect the certificate quota of an owner
+//	DELETE /quotas/{owner}  reset the certificate quota of an owner
+func (a *API) handleQuotas(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	switch len(pathElements) {
+	case 0:
+		if req.Method != http.MethodGet {
+			return errMethodNotAllowed
+		}
+		return a.listQuotas(w)
+
+	case 1:
+		// owners are stored in lower case
+		owner := strings.ToLower(pathElements[0])
+		switch req.Method {
+		case http.MethodGet:
+			return a.showQuota(w, owner)
+		case http.MethodDelete:
+			return a.resetQuota(w, owner)
+		default:
+			return errMethodNotAllowed
+		}
+	}
+
+	return errNotFound
+}
+
+func (a *API) listQuotas(w http.ResponseWriter) error {
+	quotas, err := a.CertDB.Quotas()
+	if err != nil {
+		return err
+	}
+
+	list := make([]quotaEntry, 0, len(quotas))
+	for _, quota := range quotas {
+		list = append(list, a.quotaEntry(quota))
+	}
+	return writeJSON(w, list, http.StatusOK)
+}
+
+func (a *API) showQuota(w http.ResponseWriter, owner string) error {
+	quota, err := a.CertDB.GetQuota(owner)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		// the owner did not obtain any certificates yet
+		quota = &database.Quota{Owner: owner}
+	}
+	return writeJSON(w, a.quotaEntry(quota), http.StatusOK)
+}
+
+func (a *API) resetQuota(w http.ResponseWriter, owner string) error {
+	if err := a.CertDB.ResetQuota(owner); err != nil {
+		return err
+	}
+	// custom domains of the owner get certificates with the next request
+	certificates.ForgetQuotaExceeded(a.QuotaExceededCache, owner)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) quotaEntry(quota *database.Quota) quotaEntry {
+	entry := quotaEntry{Owner: quota.Owner}
+	entry.Limit, entry.Unlimited = a.Quota.Limit(quota.Owner)
+
+	// quotas of past periods are only reset with the next certificate
+	if resetsAt := quota.ResetsAt(a.Quota.Period()); quota.Used > 0 && time.Now().Before(resetsAt) {
+		entry.Used = quota.Used
+		entry.ResetsAt = &resetsAt
+	}
+	return entry
+}
@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+// maxTakedownRequestSize limits the body of requests adding a takedown
+const maxTakedownRequestSize = 4096
+
+type takedownEntry struct {
+	Kind    string    `json:"kind"`
+	Target  string    `json:"target"`
+	Reason  string    `json:"reason"`
+	Status  int       `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+type takedownRequest struct {
+	Reason string `json:"reason"`
+	// Status defaults to 451 (Unavailable For Legal Reasons)
+	Status int `json:"status"`
+}
+
+// handleTakedowns serves
+//
+//	GET    /takedowns                  list all takedowns
+//	PUT    /takedowns/{kind}/{target}  take down an owner, a repo ({owner}/{repo}), a branch ({owner}/{repo}/{branch}) or a domain,
+//	                                   the body is {"reason": "...", "status": 451}
+//	DELETE /takedowns/{kind}/{target}  lift a takedown
+func (a *API) handleTakedowns(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	if len(pathElements) == 0 {
+		if req.Method != http.MethodGet {
+			return errMethodNotAllowed
+		}
+		return a.listTakedowns(w)
+	}
+	if len(pathElements) < 2 {
+		return errNotFound
+	}
+
+	kind, target := pathElements[0], strings.Join(pathElements[1:], "/")
+	switch req.Method {
+	case http.MethodPut:
+		return a.putTakedown(w, req, kind, target)
+	case http.MethodDelete:
+		return a.deleteTakedown(w, kind, target)
+	default:
+		return errMethodNotAllowed
+	}
+}
+
+func (a *API) listTakedowns(w http.ResponseWriter) error {
+	takedowns, err := a.CertDB.Takedowns()
+	if err != nil {
+		return err
+	}
+
+	list := make([]takedownEntry, 0, len(takedowns))
+	for _, takedown := range takedowns {
+		list = append(list, takedownEntry{
+			Kind:    takedown.Kind,
+			Target:  takedown.Target,
+			Reason:  takedown.Reason,
+			Status:  takedown.Status,
+			Created: time.Unix(takedown.Created, 0).UTC(),
+		})
+	}
+	return writeJSON(w, list, http.StatusOK)
+}
+
+func (a *API) putTakedown(w http.ResponseWriter, req *http.Request, kind, target string) error {
+	var body takedownRequest
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxTakedownRequestSize)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: invalid body: %v", errBadRequest, err)
+	}
+	takedown, err := database.NewTakedown(kind, target, body.Reason, body.Status)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	if err := a.CertDB.PutTakedown(takedown); err != nil {
+		return err
+	}
+	if err := a.reloadTakedowns(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) deleteTakedown(w http.ResponseWriter, kind, target string) error {
+	target, err := database.NormalizeTakedownTarget(kind, target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	if err := a.CertDB.DeleteTakedown(kind, target); err != nil {
+		return err
+	}
+	if err := a.reloadTakedowns(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// reloadTakedowns applies a changed takedown immediately instead of with the next periodic reload
+func (a *API) reloadTakedowns() error {
+	if a.Takedowns == nil {
+		return nil
+	}
+	return a.Takedowns.Reload()
+}
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+// maxTokenRequestSize limits the body of requests registering a deploy token
+const maxTokenRequestSize = 4096
+
+// tokenEntry describes a deploy token without revealing it
+type tokenEntry struct {
+	Owner   string    `json:"owner"`
+	Repo    string    `json:"repo"`
+	Created time.Time `json:"created"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+// handleTokens serves
+//
+//	GET    /tokens                 list the repositories with a deploy token
+//	PUT    /tokens/{owner}/{repo}  register the deploy token of a repository, the body is {"token": "..."}
+//	DELETE /tokens/{owner}/{repo}  remove the deploy token of a repository
+func (a *API) handleTokens(w http.ResponseWriter, req *http.Request, pathElements []string) error {
+	switch len(pathElements) {
+	case 0:
+		if req.Method != http.MethodGet {
+			return errMethodNotAllowed
+		}
+		return a.listTokens(w)
+
+	case 2:
+		// repositories are stored in lower case
+		owner, repo := strings.ToLower(pathElements[0]), strings.ToLower(pathElements[1])
+		if owner == "" || repo == "" {
+			return errNotFound
+		}
+		switch req.Method {
+		case http.MethodPut:
+			return a.putToken(w, req, owner, repo)
+		case http.MethodDelete:
+			return a.deleteToken(w, owner, repo)
+		default:
+			return errMethodNotAllowed
+		}
+	}
+
+	return errNotFound
+}
+
+func (a *API) listTokens(w http.ResponseWriter) error {
+	tokens, err := a.CertDB.DeployTokens()
+	if err != nil {
+		return err
+	}
+
+	list := make([]tokenEntry, 0, len(tokens))
+	for _, token := range tokens {
+		list = append(list, tokenEntry{
+			Owner:   token.Owner,
+			Repo:    token.Repo,
+			Created: time.Unix(token.Created, 0).UTC(),
+		})
+	}
+	return writeJSON(w, list, http.StatusOK)
+}
+
+func (a *API) putToken(w http.ResponseWriter, req *http.Request, owner, repo string) error {
+	var body tokenRequest
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxTokenRequestSize)).Decode(&body); err != nil {
+		return fmt.Errorf("%w: invalid body: %v", errBadRequest, err)
+	}
+	body.Token = strings.TrimSpace(body.Token)
+	if body.Token == "" {
+		return fmt.Errorf("%w: token is required", errBadRequest)
+	}
+
+	if err := a.CertDB.PutDeployToken(&database.DeployToken{Owner: owner, Repo: repo, Token: []byte(body.Token)}); err != nil {
+		return err
+	}
+	// content fetched with the previous token must not be served anymore
+	a.purgeCache(owner, repo, "")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) deleteToken(w http.ResponseWriter, owner, repo string) error {
+	if err := a.CertDB.DeleteDeployToken(owner, repo); err != nil {
+		return err
+	}
+	a.purgeCache(owner, repo, "")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -8,3 +8,18 @@ type ICache interface {
 	Get(key string) (interface{}, bool)
 	Remove(key string)
 }
+
+// IPurgeableCache is a cache that can drop all entries sharing a key prefix.
+type IPurgeableCache interface {
+	ICache
+	RemovePrefix(prefix string) int
+}
+
+// RemovePrefix removes all entries starting with prefix from c, if the cache supports it.
+// It returns the number of removed entries.
+func RemovePrefix(c ICache, prefix string) int {
+	if p, ok := c.(IPurgeableCache); ok {
+		return p.RemovePrefix(prefix)
+	}
+	return 0
+}
@@ -1,7 +1,69 @@
 package cache
 
-import "github.com/OrlovEvgeny/go-mcache"
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache"
+)
+
+// pruneEvery specifies after how many writes expired keys are dropped from the key index.
+const pruneEvery = 1024
+
+// inMemoryCache wraps mcache and keeps an index of its keys, so entries can be purged by prefix.
+type inMemoryCache struct {
+	*mcache.CacheDriver
+
+	keys   sync.Map // key -> expiry time.Time
+	writes int64
+	mu     sync.Mutex
+}
 
 func NewInMemoryCache() ICache {
-	return mcache.New()
+	return &inMemoryCache{CacheDriver: mcache.New()}
+}
+
+func (c *inMemoryCache) Set(key string, value interface{}, ttl time.Duration) error {
+	c.keys.Store(key, time.Now().Add(ttl))
+
+	c.mu.Lock()
+	c.writes++
+	prune := c.writes%pruneEvery == 0
+	c.mu.Unlock()
+	if prune {
+		c.pruneKeys()
+	}
+
+	return c.CacheDriver.Set(key, value, ttl)
+}
+
+func (c *inMemoryCache) Remove(key string) {
+	c.keys.Delete(key)
+	c.CacheDriver.Remove(key)
+}
+
+// RemovePrefix removes all entries whose key starts with prefix and returns how many were removed.
+func (c *inMemoryCache) RemovePrefix(prefix string) int {
+	removed := 0
+	c.keys.Range(func(k, _ any) bool {
+		key := k.(string)
+		if strings.HasPrefix(key, prefix) {
+			c.Remove(key)
+			removed++
+		}
+		return true
+	})
+	return removed
+}
+
+// pruneKeys drops expired keys from the index, the entries themselves are removed by mcache.
+func (c *inMemoryCache) pruneKeys() {
+	now := time.Now()
+	c.keys.Range(func(k, v any) bool {
+		if v.(time.Time).Before(now) {
+			c.keys.Delete(k)
+		}
+		return true
+	})
 }
@@ -1,7 +1,10 @@
 package certificates
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,29 +18,127 @@ import (
 )
 
 type AcmeClient struct {
-	legoClient              *lego.Client
-	dnsChallengerLegoClient *lego.Client
+	// issuers are tried in order until one of them issues a certificate
+	issuers []*acmeIssuer
 
 	obtainLocks sync.Map
 
-	acmeUseRateLimits bool
+	// keyTypes of the certificates to obtain, the first one is served to all clients that support it
+	keyTypes []string
+
+	// quota limits the certificates of every owner
+	quota *QuotaPolicy
+
+	// verification of custom domains, nil if disabled
+	verification *DomainVerification
+}
+
+// acmeIssuer is an ACME CA with its own account and rate limits
+type acmeIssuer struct {
+	name                    string
+	legoClient              *lego.Client
+	dnsChallengerLegoClient *lego.Client
+
+	useRateLimits bool
 
 	// limiter
-	acmeClientOrderLimit              *equalizer.TokenBucket
-	acmeClientRequestLimit            *equalizer.TokenBucket
-	acmeClientFailLimit               *equalizer.TokenBucket
-	acmeClientCertificateLimitPerUser map[string]*equalizer.TokenBucket
+	orderLimit   *equalizer.TokenBucket
+	requestLimit *equalizer.TokenBucket
+	failLimit    *equalizer.TokenBucket
 }
 
 func NewAcmeClient(cfg config.ACMEConfig, enableHTTPServer bool, challengeCache cache.ICache) (*AcmeClient, error) {
-	acmeConfig, err := setupAcmeConfig(cfg)
+	quota, err := NewQuotaPolicy(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+	verification, err := NewDomainVerification(cfg.Verification)
 	if err != nil {
 		return nil, err
 	}
 
+	var issuers []*acmeIssuer
+	for _, issuerCfg := range IssuerConfigs(cfg) {
+		issuer, err := newAcmeIssuer(cfg, issuerCfg, enableHTTPServer, challengeCache)
+		if err != nil {
+			return nil, fmt.Errorf("ACME issuer %q: %w", issuerCfg.Name, err)
+		}
+		issuers = append(issuers, issuer)
+	}
+	// unreachable issuers are skipped, but without any issuer only mock certs could be served
+	if !slices.ContainsFunc(issuers, func(issuer *acmeIssuer) bool { return issuer.legoClient != nil }) {
+		return nil, errors.New("none of the ACME issuers can be used")
+	}
+
+	return &AcmeClient{
+		issuers: issuers,
+
+		keyTypes: keyTypes(cfg),
+
+		obtainLocks: sync.Map{},
+
+		quota:        quota,
+		verification: verification,
+	}, nil
+}
+
+// IssuerConfigs returns the issuers to obtain certificates from in order of preference.
+// Without explicitly configured issuers, the ACME config itself describes the only issuer.
+func IssuerConfigs(cfg config.ACMEConfig) []config.ACMEIssuerConfig {
+	issuers := cfg.Issuers
+	if len(issuers) == 0 {
+		issuers = []config.ACMEIssuerConfig{{
+			APIEndpoint:       cfg.APIEndpoint,
+			UseRateLimits:     cfg.UseRateLimits,
+			EAB_HMAC:          cfg.EAB_HMAC,
+			EAB_KID:           cfg.EAB_KID,
+			AccountConfigFile: cfg.AccountConfigFile,
+		}}
+	}
+
+	named := make([]config.ACMEIssuerConfig, 0, len(issuers))
+	for _, issuer := range issuers {
+		if issuer.Name == "" {
+			// the host of the API is good enough to tell issuers apart
+			if u, err := url.Parse(issuer.APIEndpoint); err == nil && u.Host != "" {
+				issuer.Name = u.Host
+			} else {
+				issuer.Name = issuer.APIEndpoint
+			}
+		}
+		named = append(named, issuer)
+	}
+	return named
+}
+
+// newAcmeIssuer sets up the lego clients of an issuer. If the issuer can't be reached, its clients stay nil
+// and certificates are obtained from the other issuers.
+func newAcmeIssuer(cfg config.ACMEConfig, issuerCfg config.ACMEIssuerConfig, enableHTTPServer bool, challengeCache cache.ICache) (*acmeIssuer, error) {
+	issuer := &acmeIssuer{
+		name: issuerCfg.Name,
+
+		useRateLimits: issuerCfg.UseRateLimits,
+
+		// limiter
+
+		// rate limit is 300 / 3 hours, we want 200 / 2 hours but to refill more often, so that's 25 new domains every 15 minutes
+		// TODO: when this is used a lot, we probably have to think of a somewhat better solution?
+		orderLimit: equalizer.NewTokenBucket(25, 15*time.Minute),
+		// rate limit is 20 / second, we want 5 / second (especially as one cert takes at least two requests)
+		requestLimit: equalizer.NewTokenBucket(5, 1*time.Second),
+		// rate limit is 5 / hour https://letsencrypt.org/docs/failed-validation-limit/
+		failLimit: equalizer.NewTokenBucket(5, 1*time.Hour),
+	}
+
+	acmeConfig, err := setupAcmeConfig(cfg, issuerCfg)
+	if err != nil {
+		log.Error().Err(err).Msgf("Can't set up ACME issuer %s, continuing without it", issuerCfg.Name)
+		return issuer, nil
+	}
+
 	acmeClient, err := lego.NewClient(acmeConfig)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Can't create ACME client, continuing with mock certs only")
+		log.Error().Err(err).Msgf("Can't create ACME client for %s, continuing without it", issuerCfg.Name)
 	} else {
 		err = acmeClient.Challenge.SetTLSALPN01Provider(AcmeTLSChallengeProvider{challengeCache})
 		if err != nil {
@@ -49,11 +150,12 @@ func NewAcmeClient(cfg config.ACMEConfig, enableHTTPServer bool, challengeCache
 				log.Error().Err(err).Msg("Can't create HTTP-01 provider")
 			}
 		}
+		issuer.legoClient = acmeClient
 	}
 
 	mainDomainAcmeClient, err := lego.NewClient(acmeConfig)
 	if err != nil {
-		log.Error().Err(err).Msg("Can't create ACME client, continuing with mock certs only")
+		log.Error().Err(err).Msgf("Can't create ACME client for %s, continuing without it", issuerCfg.Name)
 	} else {
 		if cfg.DNSProvider == "" {
 			// using mock server, don't use wildcard certs
@@ -71,26 +173,47 @@ func NewAcmeClient(cfg config.ACMEConfig, enableHTTPServer bool, challengeCache
 				return nil, fmt.Errorf("can not create DNS-01 provider: %w", err)
 			}
 		}
+		issuer.dnsChallengerLegoClient = mainDomainAcmeClient
 	}
 
-	return &AcmeClient{
-		legoClient:              acmeClient,
-		dnsChallengerLegoClient: mainDomainAcmeClient,
+	return issuer, nil
+}
 
-		acmeUseRateLimits: cfg.UseRateLimits,
+// client returns the lego client of the issuer for the given challenge type, which is nil if it could not be created
+func (i *acmeIssuer) client(useDnsProvider bool) *lego.Client {
+	if useDnsProvider {
+		return i.dnsChallengerLegoClient
+	}
+	return i.legoClient
+}
 
-		obtainLocks: sync.Map{},
+// take consumes a token of limit. It only waits for a token if wait is set,
+// otherwise it reports whether a token was available, so that another issuer can be tried instead.
+func (i *acmeIssuer) take(limit *equalizer.TokenBucket, wait bool) bool {
+	if !i.useRateLimits {
+		return true
+	}
+	if wait {
+		limit.Take()
+		return true
+	}
+	return limit.Ask()
+}
 
-		// limiter
+func keyTypes(cfg config.ACMEConfig) []string {
+	keyTypes := []string{cfg.KeyType}
+	if cfg.SecondaryKeyType != "" && cfg.SecondaryKeyType != cfg.KeyType {
+		keyTypes = append(keyTypes, cfg.SecondaryKeyType)
+	}
+	return keyTypes
+}
 
-		// rate limit is 300 / 3 hours, we want 200 / 2 hours but to refill more often, so that's 25 new domains every 15 minutes
-		// TODO: when this is used a lot, we probably have to think of a somewhat better solution?
-		acmeClientOrderLimit: equalizer.NewTokenBucket(25, 15*time.Minute),
-		// rate limit is 20 / second, we want 5 / second (especially as one cert takes at least two requests)
-		acmeClientRequestLimit: equalizer.NewTokenBucket(5, 1*time.Second),
-		// rate limit is 5 / hour https://letsencrypt.org/docs/failed-validation-limit/
-		acmeClientFailLimit: equalizer.NewTokenBucket(5, 1*time.Hour),
-		// checkUserLimit() use this to rate also per user
-		acmeClientCertificateLimitPerUser: map[string]*equalizer.TokenBucket{},
-	}, nil
+// Quota returns the policy limiting the certificates of every owner.
+func (c *AcmeClient) Quota() *QuotaPolicy {
+	return c.quota
+}
+
+// usesKeyType reports whether certificates of keyType are obtained and served.
+func (c *AcmeClient) usesKeyType(keyType string) bool {
+	return slices.Contains(c.keyTypes, keyType)
 }
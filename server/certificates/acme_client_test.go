@@ -0,0 +1,209 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/reugn/equalizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestIssuerConfigs(t *testing.T) {
+	cfg := config.ACMEConfig{
+		APIEndpoint:       "https://acme-v02.api.letsencrypt.org/directory",
+		UseRateLimits:     true,
+		AccountConfigFile: "acme-account.json",
+	}
+	assert.EqualValues(t, []config.ACMEIssuerConfig{{
+		Name:              "acme-v02.api.letsencrypt.org",
+		APIEndpoint:       "https://acme-v02.api.letsencrypt.org/directory",
+		UseRateLimits:     true,
+		AccountConfigFile: "acme-account.json",
+	}}, IssuerConfigs(cfg))
+
+	cfg.Issuers = []config.ACMEIssuerConfig{
+		{Name: "letsencrypt", APIEndpoint: "https://acme-v02.api.letsencrypt.org/directory", AccountConfigFile: "letsencrypt.json"},
+		{APIEndpoint: "https://acme.zerossl.com/v2/DV90", AccountConfigFile: "zerossl.json"},
+	}
+	issuers := IssuerConfigs(cfg)
+	if assert.Len(t, issuers, 2) {
+		assert.EqualValues(t, "letsencrypt", issuers[0].Name)
+		assert.EqualValues(t, "acme.zerossl.com", issuers[1].Name)
+		assert.EqualValues(t, "zerossl.json", issuers[1].AccountConfigFile)
+	}
+	// the config itself is not modified
+	assert.Empty(t, cfg.Issuers[1].Name)
+}
+
+func TestAcmeIssuerTakeFallsThrough(t *testing.T) {
+	issuer := &acmeIssuer{useRateLimits: true}
+	limit := equalizer.NewTokenBucket(1, time.Hour)
+
+	assert.True(t, issuer.take(limit, false))
+	// without waiting the next issuer is tried right away
+	assert.False(t, issuer.take(limit, false))
+
+	issuer.useRateLimits = false
+	assert.True(t, issuer.take(limit, false))
+}
+
+// newACMEServer starts a minimal ACME CA that issues certificates for "example.com" without any challenges
+func newACMEServer(t *testing.T) *httptest.Server {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	assert.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	var server *httptest.Server
+	var certPEM []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   server.URL + "/nonce",
+			"newAccount": server.URL + "/account",
+			"newOrder":   server.URL + "/order",
+		})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", server.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":         "pending",
+			"identifiers":    []map[string]string{{"type": "dns", "value": "example.com"}},
+			"authorizations": []string{server.URL + "/authz/1"},
+			"finalize":       server.URL + "/finalize/1",
+		})
+	})
+	mux.HandleFunc("/authz/1", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":     "valid",
+			"identifier": map[string]string{"type": "dns", "value": "example.com"},
+		})
+	})
+	mux.HandleFunc("/finalize/1", func(w http.ResponseWriter, r *http.Request) {
+		// the JWS signature is not checked, only the CSR in its payload is of interest
+		var jws struct{ Payload string }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&jws))
+		payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+		assert.NoError(t, err)
+		var finalize struct{ CSR string }
+		assert.NoError(t, json.Unmarshal(payload, &finalize))
+		csrDER, err := base64.RawURLEncoding.DecodeString(finalize.CSR)
+		assert.NoError(t, err)
+		csr, err := x509.ParseCertificateRequest(csrDER)
+		assert.NoError(t, err)
+
+		leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}, ca, csr.PublicKey, caKey)
+		assert.NoError(t, err)
+		certPEM = append(certcrypto.PEMEncode(certcrypto.DERCertificateBytes(leafDER)), certcrypto.PEMEncode(certcrypto.DERCertificateBytes(caDER))...)
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":      "valid",
+			"finalize":    server.URL + "/finalize/1",
+			"certificate": server.URL + "/cert/1",
+		})
+	})
+	mux.HandleFunc("/cert/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_, _ = w.Write(certPEM)
+	})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", base64.RawURLEncoding.EncodeToString(big.NewInt(time.Now().UnixNano()).Bytes()))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeAcmeAccount writes an already registered account, so no registration is necessary
+func writeAcmeAccount(t *testing.T, file string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	account, err := json.Marshal(AcmeAccount{
+		Email:        "test@example.com",
+		Registration: &registration.Resource{URI: "https://acme.example/account/1"},
+		KeyPEM:       string(certcrypto.PEMEncode(key)),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(file, account, 0o600))
+}
+
+func TestAcmeClientSkipsUnreachableIssuers(t *testing.T) {
+	dir := t.TempDir()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	up := newACMEServer(t)
+	for _, name := range []string{"down", "up"} {
+		writeAcmeAccount(t, filepath.Join(dir, name+".json"))
+	}
+
+	cfg := config.ACMEConfig{
+		KeyType: database.KeyTypeEC256,
+		Quota:   config.QuotaConfig{Certificates: 10, Period: "24h"},
+		Issuers: []config.ACMEIssuerConfig{
+			{Name: "down", APIEndpoint: down.URL + "/directory", AccountConfigFile: filepath.Join(dir, "down.json")},
+			{Name: "up", APIEndpoint: up.URL + "/directory", AccountConfigFile: filepath.Join(dir, "up.json")},
+		},
+	}
+	acmeClient, err := NewAcmeClient(cfg, false, cache.NewInMemoryCache())
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, acmeClient.issuers, 2) {
+		assert.Nil(t, acmeClient.issuers[0].legoClient)
+		assert.NotNil(t, acmeClient.issuers[1].legoClient)
+	}
+
+	db := database.NewMockCertDB(t)
+	db.Mock.On("Put", "example.com#EC256", "up", mock.Anything).Return(nil).Once()
+	cert, err := acmeClient.obtainCert([]string{"example.com"}, nil, "", false, ".codeberg.page", database.KeyTypeEC256, db)
+	assert.NoError(t, err)
+	if assert.NotNil(t, cert) {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.NoError(t, err)
+		assert.EqualValues(t, []string{"example.com"}, leaf.DNSNames)
+		assert.EqualValues(t, "Test CA", leaf.Issuer.CommonName)
+	}
+
+	// without any reachable issuer the server can't start
+	cfg.Issuers = cfg.Issuers[:1]
+	_, err = NewAcmeClient(cfg, false, cache.NewInMemoryCache())
+	assert.Error(t, err)
+}
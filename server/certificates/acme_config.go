@@ -1,6 +1,7 @@
 package certificates
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -9,6 +10,7 @@ import (
 	"os"
 
 	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/database"
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
@@ -17,16 +19,34 @@ import (
 
 const challengePath = "/.well-known/acme-challenge/"
 
-func setupAcmeConfig(cfg config.ACMEConfig) (*lego.Config, error) {
+// legoKeyTypes maps the configurable key types to the ones of lego
+var legoKeyTypes = map[string]certcrypto.KeyType{
+	database.KeyTypeEC256:   certcrypto.EC256,
+	database.KeyTypeEC384:   certcrypto.EC384,
+	database.KeyTypeRSA2048: certcrypto.RSA2048,
+	database.KeyTypeRSA4096: certcrypto.RSA4096,
+}
+
+// generatePrivateKey creates a new certificate key of the given key type
+func generatePrivateKey(keyType string) (crypto.PrivateKey, error) {
+	legoKeyType, ok := legoKeyTypes[keyType]
+	if !ok {
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+	return certcrypto.GeneratePrivateKey(legoKeyType)
+}
+
+// setupAcmeConfig loads the account of an issuer, or registers a new one if there is no account file yet.
+func setupAcmeConfig(cfg config.ACMEConfig, issuer config.ACMEIssuerConfig) (*lego.Config, error) {
 	var myAcmeAccount AcmeAccount
 	var myAcmeConfig *lego.Config
 
-	if cfg.AccountConfigFile == "" {
-		return nil, fmt.Errorf("invalid acme config file: '%s'", cfg.AccountConfigFile)
+	if issuer.AccountConfigFile == "" {
+		return nil, fmt.Errorf("invalid acme config file: '%s'", issuer.AccountConfigFile)
 	}
 
-	if account, err := os.ReadFile(cfg.AccountConfigFile); err == nil {
-		log.Info().Msgf("found existing acme account config file '%s'", cfg.AccountConfigFile)
+	if account, err := os.ReadFile(issuer.AccountConfigFile); err == nil {
+		log.Info().Msgf("found existing acme account config file '%s'", issuer.AccountConfigFile)
 		if err := json.Unmarshal(account, &myAcmeAccount); err != nil {
 			return nil, err
 		}
@@ -37,8 +57,8 @@ func setupAcmeConfig(cfg config.ACMEConfig) (*lego.Config, error) {
 		}
 
 		myAcmeConfig = lego.NewConfig(&myAcmeAccount)
-		myAcmeConfig.CADirURL = cfg.APIEndpoint
-		myAcmeConfig.Certificate.KeyType = certcrypto.RSA2048
+		myAcmeConfig.CADirURL = issuer.APIEndpoint
+		myAcmeConfig.Certificate.KeyType = legoKeyTypes[cfg.KeyType]
 
 		// Validate Config
 		_, err := lego.NewClient(myAcmeConfig)
@@ -64,14 +84,14 @@ func setupAcmeConfig(cfg config.ACMEConfig) (*lego.Config, error) {
 		KeyPEM: string(certcrypto.PEMEncode(privateKey)),
 	}
 	myAcmeConfig = lego.NewConfig(&myAcmeAccount)
-	myAcmeConfig.CADirURL = cfg.APIEndpoint
-	myAcmeConfig.Certificate.KeyType = certcrypto.RSA2048
+	myAcmeConfig.CADirURL = issuer.APIEndpoint
+	myAcmeConfig.Certificate.KeyType = legoKeyTypes[cfg.KeyType]
 	tempClient, err := lego.NewClient(myAcmeConfig)
 	if err != nil {
 		log.Error().Err(err).Msg("Can't create ACME client, continuing with mock certs only")
 	} else {
 		// accept terms & log in to EAB
-		if cfg.EAB_KID == "" || cfg.EAB_HMAC == "" {
+		if issuer.EAB_KID == "" || issuer.EAB_HMAC == "" {
 			reg, err := tempClient.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: cfg.AcceptTerms})
 			if err != nil {
 				log.Error().Err(err).Msg("Can't register ACME account, continuing with mock certs only")
@@ -81,8 +101,8 @@ func setupAcmeConfig(cfg config.ACMEConfig) (*lego.Config, error) {
 		} else {
 			reg, err := tempClient.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
 				TermsOfServiceAgreed: cfg.AcceptTerms,
-				Kid:                  cfg.EAB_KID,
-				HmacEncoded:          cfg.EAB_HMAC,
+				Kid:                  issuer.EAB_KID,
+				HmacEncoded:          issuer.EAB_HMAC,
 			})
 			if err != nil {
 				log.Error().Err(err).Msg("Can't register ACME account, continuing with mock certs only")
@@ -97,8 +117,8 @@ func setupAcmeConfig(cfg config.ACMEConfig) (*lego.Config, error) {
 				log.Error().Err(err).Msg("json.Marshalfailed, waiting for manual restart to avoid rate limits")
 				select {}
 			}
-			log.Info().Msgf("new acme account created. write to config file '%s'", cfg.AccountConfigFile)
-			err = os.WriteFile(cfg.AccountConfigFile, acmeAccountJSON, 0o600)
+			log.Info().Msgf("new acme account created. write to config file '%s'", issuer.AccountConfigFile)
+			err = os.WriteFile(issuer.AccountConfigFile, acmeAccountJSON, 0o600)
 			if err != nil {
 				log.Error().Err(err).Msg("os.WriteFile failed, waiting for manual restart to avoid rate limits")
 				select {}
@@ -6,34 +6,103 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
 	"github.com/go-acme/lego/v4/lego"
-	"github.com/reugn/equalizer"
 	"github.com/rs/zerolog/log"
 
 	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/database"
 	dnsutils "codeberg.org/codeberg/pages/server/dns"
 	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/takedown"
 	"codeberg.org/codeberg/pages/server/upstream"
 )
 
-var ErrUserRateLimitExceeded = errors.New("rate limit exceeded: 10 certificates per user per 24 hours")
+// errIssuerRateLimited is returned instead of waiting for the rate limits of an issuer, if another issuer can be tried
+var errIssuerRateLimited = errors.New("rate limits of the issuer are exhausted")
+
+// keyCacheTTL is how long parsed certificates are kept in the key cache
+const keyCacheTTL = 15 * time.Minute
+
+// keyTypeRetryInterval is how long a certificate of an additional key type is not tried again after it couldn't be
+// obtained, the certificate of the main key type is served meanwhile
+const keyTypeRetryInterval = time.Hour
 
 // TLSConfig returns the configuration for generating, serving and cleaning up Let's Encrypt certificates.
 func TLSConfig(mainDomainSuffix string,
 	giteaClient *gitea.Client,
 	acmeClient *AcmeClient,
 	firstDefaultBranch string,
-	keyCache, challengeCache, dnsLookupCache, canonicalDomainCache cache.ICache,
+	takedowns *takedown.List,
+	keyCache, challengeCache, dnsLookupCache, canonicalDomainCache, quotaExceededCache cache.ICache,
 	certDB database.CertDB,
 ) *tls.Config {
+	stapler := newOCSPStapler(certDB, keyCache)
+	// keyTypeFailures contains the names of certificates of additional key types that couldn't be obtained recently
+	keyTypeFailures := cache.NewInMemoryCache()
+
+	// quotaExceededCert returns a self-signed certificate for domain, which is served until the quota cache entry expires.
+	// The handler explains the missing certificate once the visitor accepted it.
+	quotaExceededCert := func(domain, keyType, targetOwner, msg string) (*tls.Certificate, error) {
+		tlsCertificate, err := quotaCert(domain, msg, keyType)
+		if err != nil {
+			return nil, err
+		}
+		if err := quotaExceededCache.Set(quotaExceededKey(targetOwner, domain), &quotaExceeded{message: msg, cert: tlsCertificate}, keyCacheTTL); err != nil {
+			log.Error().Err(err).Msg("Couldn't cache quota error")
+		}
+		return tlsCertificate, nil
+	}
+
+	// getCertificate returns the certificate of a domain with the given key type, if necessary by obtaining a new one
+	getCertificate := func(domain, keyType, targetOwner string, mayObtainCert bool) (*tls.Certificate, error) {
+		name := database.NameWithKeyType(domain, keyType)
+		if tlsCertificate, ok := keyCache.Get(name); ok {
+			// we can use an existing certificate object
+			return tlsCertificate.(*tls.Certificate), nil
+		}
+
+		tlsCertificate, err := acmeClient.retrieveCertFromDB(name, mainDomainSuffix, certDB)
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				return nil, err
+			}
+			// we could not find a cert in db, request a new certificate
+
+			// first check if we are allowed to obtain a cert for this domain
+			if strings.EqualFold(domain, mainDomainSuffix) {
+				return nil, errors.New("won't request certificate for main domain, something really bad has happened")
+			}
+			if !mayObtainCert {
+				return nil, fmt.Errorf("won't request certificate for %q", domain)
+			}
+			if targetOwner != "" {
+				// the first certificate of a custom domain needs proof that the owner controls it
+				if err := acmeClient.verification.verify(domain, targetOwner, dnsLookupCache); err != nil {
+					return nil, err
+				}
+			}
+
+			tlsCertificate, err = acmeClient.obtainCert([]string{domain}, nil, targetOwner, false, mainDomainSuffix, keyType, certDB)
+			if errors.Is(err, ErrQuotaExceeded) {
+				log.Info().Err(err).Msgf("Not obtaining certificate for %q", domain)
+				return quotaExceededCert(domain, keyType, targetOwner, err.Error())
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := stapler.stapleAndCache(name, tlsCertificate); err != nil {
+			return nil, err
+		}
+		return tlsCertificate, nil
+	}
+
 	return &tls.Config{
 		// check DNS name & get certificate from Let's Encrypt
 		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
@@ -68,16 +137,19 @@ func TLSConfig(mainDomainSuffix string,
 				// deliver default certificate for the main domain (*.codeberg.page)
 				domain = mainDomainSuffix
 			} else {
-				var targetRepo, targetBranch string
-				targetOwner, targetRepo, targetBranch = dnsutils.GetTargetFromDNS(domain, mainDomainSuffix, firstDefaultBranch, dnsLookupCache)
+				target, err := dnsutils.GetTargetFromDNS(domain, mainDomainSuffix, firstDefaultBranch, dnsLookupCache)
+				if err != nil {
+					log.Debug().Err(err).Msgf("Can't serve custom domain %q", domain)
+				}
+				targetOwner = target.Owner
 				if targetOwner == "" {
 					// DNS not set up, return main certificate to redirect to the docs
 					domain = mainDomainSuffix
 				} else {
 					targetOpt := &upstream.Options{
-						TargetOwner:  targetOwner,
-						TargetRepo:   targetRepo,
-						TargetBranch: targetBranch,
+						TargetOwner:  target.Owner,
+						TargetRepo:   target.Repo,
+						TargetBranch: target.Branch,
 					}
 					_, valid := targetOpt.CheckCanonicalDomain(giteaClient, domain, mainDomainSuffix, canonicalDomainCache)
 					if !valid {
@@ -85,40 +157,52 @@ func TLSConfig(mainDomainSuffix string,
 						// repository has specified this domain in the `.domains` file.
 						mayObtainCert = false
 					}
+					if takedowns.Domain(domain) != nil || takedowns.Site(target.Owner, target.Repo, target.Branch) != nil {
+						// a stored certificate is still served to show the takedown page, but no new one is obtained
+						mayObtainCert = false
+					}
 				}
 			}
 
-			if tlsCertificate, ok := keyCache.Get(domain); ok {
-				// we can use an existing certificate object
-				return tlsCertificate.(*tls.Certificate), nil
+			if targetOwner != "" {
+				// don't try to obtain certificates of any key type until the quota cache entry expires
+				if blocked, ok := quotaExceededCache.Get(quotaExceededKey(targetOwner, domain)); ok {
+					return blocked.(*quotaExceeded).cert, nil
+				}
 			}
 
-			var tlsCertificate *tls.Certificate
-			var err error
-			if tlsCertificate, err = acmeClient.retrieveCertFromDB(domain, mainDomainSuffix, false, certDB); err != nil {
-				if !errors.Is(err, database.ErrNotFound) {
+			// serve the first key type the client supports, falling back to the main key type
+			var fallback *tls.Certificate
+			for _, keyType := range acmeClient.keyTypes {
+				name := database.NameWithKeyType(domain, keyType)
+				if _, failed := keyTypeFailures.Get(name); failed && fallback != nil {
+					break
+				}
+				tlsCertificate, err := getCertificate(domain, keyType, targetOwner, mayObtainCert)
+				if err != nil {
+					if fallback != nil {
+						log.Error().Err(err).Msgf("Couldn't get %s certificate for %q, trying again in %s", keyType, domain, keyTypeRetryInterval)
+						if err := keyTypeFailures.Set(name, err, keyTypeRetryInterval); err != nil {
+							log.Error().Err(err).Msg("Couldn't cache key type failure")
+						}
+						break
+					}
 					return nil, err
 				}
-				// we could not find a cert in db, request a new certificate
-
-				// first check if we are allowed to obtain a cert for this domain
-				if strings.EqualFold(domain, mainDomainSuffix) {
-					return nil, errors.New("won't request certificate for main domain, something really bad has happened")
+				if targetOwner != "" {
+					// the quota blocks certificates of all key types
+					if _, blocked := QuotaExceededMessage(quotaExceededCache, targetOwner, domain); blocked {
+						return tlsCertificate, nil
+					}
 				}
-				if !mayObtainCert {
-					return nil, fmt.Errorf("won't request certificate for %q", domain)
+				if fallback == nil {
+					fallback = tlsCertificate
 				}
-
-				tlsCertificate, err = acmeClient.obtainCert(acmeClient.legoClient, []string{domain}, nil, targetOwner, false, mainDomainSuffix, certDB)
-				if err != nil {
-					return nil, err
+				if info.SupportsCertificate(tlsCertificate) == nil {
+					return tlsCertificate, nil
 				}
 			}
-
-			if err := keyCache.Set(domain, tlsCertificate, 15*time.Minute); err != nil {
-				return nil, err
-			}
-			return tlsCertificate, nil
+			return fallback, nil
 		},
 		NextProtos: []string{
 			"h2",
@@ -140,20 +224,8 @@ func TLSConfig(mainDomainSuffix string,
 	}
 }
 
-func (c *AcmeClient) checkUserLimit(user string) error {
-	userLimit, ok := c.acmeClientCertificateLimitPerUser[user]
-	if !ok {
-		// Each user can only add 10 new domains per day.
-		userLimit = equalizer.NewTokenBucket(10, time.Hour*24)
-		c.acmeClientCertificateLimitPerUser[user] = userLimit
-	}
-	if !userLimit.Ask() {
-		return fmt.Errorf("user '%s' error: %w", user, ErrUserRateLimitExceeded)
-	}
-	return nil
-}
-
-func (c *AcmeClient) retrieveCertFromDB(sni, mainDomainSuffix string, useDnsProvider bool, certDB database.CertDB) (*tls.Certificate, error) {
+// retrieveCertFromDB loads a certificate from the database, renewals are handled by the RenewalQueue.
+func (c *AcmeClient) retrieveCertFromDB(sni, mainDomainSuffix string, certDB database.CertDB) (*tls.Certificate, error) {
 	// parse certificate from database
 	res, err := certDB.Get(sni)
 	if err != nil {
@@ -167,129 +239,90 @@ func (c *AcmeClient) retrieveCertFromDB(sni, mainDomainSuffix string, useDnsProv
 		return nil, err
 	}
 
-	// TODO: document & put into own function
 	if !strings.EqualFold(sni, mainDomainSuffix) {
 		tlsCertificate.Leaf, err = x509.ParseCertificate(tlsCertificate.Certificate[0])
 		if err != nil {
 			return nil, fmt.Errorf("error parsing leaf tlsCert: %w", err)
 		}
-
-		// renew certificates 7 days before they expire
-		if tlsCertificate.Leaf.NotAfter.Before(time.Now().Add(7 * 24 * time.Hour)) {
-			// TODO: use ValidTill of custom cert struct
-			if res.CSR != nil && len(res.CSR) > 0 {
-				// CSR stores the time when the renewal shall be tried again
-				nextTryUnix, err := strconv.ParseInt(string(res.CSR), 10, 64)
-				if err == nil && time.Now().Before(time.Unix(nextTryUnix, 0)) {
-					return &tlsCertificate, nil
-				}
-			}
-			// TODO: make a queue ?
-			go (func() {
-				res.CSR = nil // acme client doesn't like CSR to be set
-				if _, err := c.obtainCert(c.legoClient, []string{sni}, res, "", useDnsProvider, mainDomainSuffix, certDB); err != nil {
-					log.Error().Msgf("Couldn't renew certificate for %s: %v", sni, err)
-				}
-			})()
-		}
 	}
 
 	return &tlsCertificate, nil
 }
 
-func (c *AcmeClient) obtainCert(acmeClient *lego.Client, domains []string, renew *certificate.Resource, user string, useDnsProvider bool, mainDomainSuffix string, keyDatabase database.CertDB) (*tls.Certificate, error) {
-	name := strings.TrimPrefix(domains[0], "*")
+// obtainCert renews or requests a certificate, trying the issuers in order until one of them succeeds.
+func (c *AcmeClient) obtainCert(domains []string, renew *certificate.Resource, user string, useDnsProvider bool, mainDomainSuffix, keyType string, keyDatabase database.CertDB) (*tls.Certificate, error) {
+	name := database.NameWithKeyType(strings.TrimPrefix(domains[0], "*"), keyType)
 	if useDnsProvider && domains[0] != "" && domains[0][0] == '*' {
 		domains = domains[1:]
 	}
 
-	// lock to avoid simultaneous requests
-	_, working := c.obtainLocks.LoadOrStore(name, struct{}{})
-	if working {
-		for working {
-			time.Sleep(100 * time.Millisecond)
-			_, working = c.obtainLocks.Load(name)
-		}
-		cert, err := c.retrieveCertFromDB(name, mainDomainSuffix, useDnsProvider, keyDatabase)
+	// lock to avoid simultaneous requests, the channel is closed when the running request is done
+	done := make(chan struct{})
+	if running, working := c.obtainLocks.LoadOrStore(name, done); working {
+		<-running.(chan struct{})
+		cert, err := c.retrieveCertFromDB(name, mainDomainSuffix, keyDatabase)
 		if err != nil {
 			return nil, fmt.Errorf("certificate failed in synchronous request: %w", err)
 		}
 		return cert, nil
 	}
-	defer c.obtainLocks.Delete(name)
-
-	if acmeClient == nil {
-		return mockCert(domains[0], "ACME client uninitialized. This is a server error, please report!", mainDomainSuffix, keyDatabase)
+	defer func() {
+		c.obtainLocks.Delete(name)
+		close(done)
+	}()
+
+	// a certificate counts against the quota of the owner only once, regardless of the key types
+	if user != "" && len(c.keyTypes) > 0 && keyType == c.keyTypes[0] {
+		if err := c.quota.take(user, keyDatabase); err != nil {
+			return nil, err
+		}
 	}
 
 	// request actual cert
 	var res *certificate.Resource
-	var err error
-	if renew != nil && renew.CertURL != "" {
-		if c.acmeUseRateLimits {
-			c.acmeClientRequestLimit.Take()
+	var issuerName string
+	var errs []error
+	// only the last usable issuer waits for its rate limits, all others fall through to the next issuer
+	last := -1
+	for i, issuer := range c.issuers {
+		if issuer.client(useDnsProvider) != nil {
+			last = i
 		}
-		log.Debug().Msgf("Renewing certificate for: %v", domains)
-		res, err = acmeClient.Certificate.Renew(*renew, true, false, "")
-		if err != nil {
-			log.Error().Err(err).Msgf("Couldn't renew certificate for %v, trying to request a new one", domains)
-			if c.acmeUseRateLimits {
-				c.acmeClientFailLimit.Take()
-			}
-			res = nil
+	}
+	for i, issuer := range c.issuers {
+		legoClient := issuer.client(useDnsProvider)
+		if legoClient == nil {
+			continue
+		}
+
+		var err error
+		if res, err = issuer.obtain(legoClient, domains, renew, keyType, i == last); err == nil {
+			issuerName = issuer.name
+			break
 		}
+		log.Warn().Err(err).Msgf("Couldn't obtain a certificate for %v from %s", domains, issuer.name)
+		errs = append(errs, fmt.Errorf("%s: %w", issuer.name, err))
 	}
 	if res == nil {
-		if user != "" {
-			if err := c.checkUserLimit(user); err != nil {
-				return nil, err
+		if len(errs) == 0 {
+			if renew != nil {
+				// keep the existing certificate instead of replacing it with a mock
+				return nil, errors.New("ACME client uninitialized")
 			}
+			return mockCert(domains[0], "ACME client uninitialized. This is a server error, please report!", mainDomainSuffix, keyType, keyDatabase)
 		}
 
-		if c.acmeUseRateLimits {
-			c.acmeClientOrderLimit.Take()
-			c.acmeClientRequestLimit.Take()
-		}
-		log.Debug().Msgf("Re-requesting new certificate for %v", domains)
-		res, err = acmeClient.Certificate.Obtain(certificate.ObtainRequest{
-			Domains:    domains,
-			Bundle:     true,
-			MustStaple: false,
-		})
-		if c.acmeUseRateLimits && err != nil {
-			c.acmeClientFailLimit.Take()
-		}
-	}
-	if err != nil {
+		err := errors.Join(errs...)
 		log.Error().Err(err).Msgf("Couldn't obtain again a certificate or %v", domains)
-		if renew != nil && renew.CertURL != "" {
-			tlsCertificate, err := tls.X509KeyPair(renew.Certificate, renew.PrivateKey)
-			if err != nil {
-				mockC, err2 := mockCert(domains[0], err.Error(), mainDomainSuffix, keyDatabase)
-				if err2 != nil {
-					return nil, errors.Join(err, err2)
-				}
-				return mockC, err
-			}
-			leaf, err := leaf(&tlsCertificate)
-			if err == nil && leaf.NotAfter.After(time.Now()) {
-				// avoid sending a mock cert instead of a still valid cert, instead abuse CSR field to store time to try again at
-				renew.CSR = []byte(strconv.FormatInt(time.Now().Add(6*time.Hour).Unix(), 10))
-				if err := keyDatabase.Put(name, renew); err != nil {
-					mockC, err2 := mockCert(domains[0], err.Error(), mainDomainSuffix, keyDatabase)
-					if err2 != nil {
-						return nil, errors.Join(err, err2)
-					}
-					return mockC, err
-				}
-				return &tlsCertificate, nil
-			}
+		if renew != nil {
+			// the existing certificate stays in the database, the renewal queue tries again later
+			return nil, err
 		}
-		return mockCert(domains[0], err.Error(), mainDomainSuffix, keyDatabase)
+		return mockCert(domains[0], err.Error(), mainDomainSuffix, keyType, keyDatabase)
 	}
-	log.Debug().Msgf("Obtained certificate for %v", domains)
+	log.Debug().Msgf("Obtained certificate for %v from %s", domains, issuerName)
 
-	if err := keyDatabase.Put(name, res); err != nil {
+	if err := keyDatabase.Put(name, issuerName, res); err != nil {
 		return nil, err
 	}
 	tlsCertificate, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
@@ -299,66 +332,138 @@ func (c *AcmeClient) obtainCert(acmeClient *lego.Client, domains []string, renew
 	return &tlsCertificate, nil
 }
 
-func SetupMainDomainCertificates(mainDomainSuffix string, acmeClient *AcmeClient, certDB database.CertDB) error {
-	// getting main cert before ACME account so that we can fail here without hitting rate limits
-	mainCertBytes, err := certDB.Get(mainDomainSuffix)
+// obtain renews or requests a certificate at this issuer. If wait is not set,
+// it fails right away instead of waiting for its rate limits.
+func (i *acmeIssuer) obtain(legoClient *lego.Client, domains []string, renew *certificate.Resource, keyType string, wait bool) (*certificate.Resource, error) {
+	if renew != nil && renew.CertURL != "" {
+		if !i.take(i.requestLimit, wait) {
+			return nil, errIssuerRateLimited
+		}
+		log.Debug().Msgf("Renewing certificate for %v at %s", domains, i.name)
+		res, err := legoClient.Certificate.Renew(*renew, true, false, "")
+		if err == nil {
+			return res, nil
+		}
+		log.Error().Err(err).Msgf("Couldn't renew certificate for %v at %s, trying to request a new one", domains, i.name)
+		i.take(i.failLimit, wait)
+	}
+
+	if !i.take(i.orderLimit, wait) || !i.take(i.requestLimit, wait) {
+		return nil, errIssuerRateLimited
+	}
+	log.Debug().Msgf("Re-requesting new %s certificate for %v at %s", keyType, domains, i.name)
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	res, err := legoClient.Certificate.Obtain(certificate.ObtainRequest{
+		Domains:    domains,
+		Bundle:     true,
+		PrivateKey: privateKey,
+		MustStaple: false,
+	})
+	if err != nil {
+		i.take(i.failLimit, wait)
+		return nil, err
+	}
+	return res, nil
+}
+
+// RenewCert requests a new certificate for domain right away, regardless of the expiry date of the stored one.
+func (c *AcmeClient) RenewCert(domain, mainDomainSuffix string, certDB database.CertDB) error {
+	res, err := certDB.Get(domain)
 	if err != nil && !errors.Is(err, database.ErrNotFound) {
-		return fmt.Errorf("cert database is not working: %w", err)
+		return err
 	}
+	return c.renewCert(domain, res, mainDomainSuffix, certDB)
+}
 
-	if mainCertBytes == nil {
-		_, err = acmeClient.obtainCert(acmeClient.dnsChallengerLegoClient, []string{"*" + mainDomainSuffix, mainDomainSuffix[1:]}, nil, "", true, mainDomainSuffix, certDB)
-		if err != nil {
-			log.Error().Err(err).Msg("Couldn't renew main domain certificate, continuing with mock certs only")
+// renewCert replaces res, which may be nil, with a newly obtained certificate.
+// The name may contain a key type as created by database.NameWithKeyType.
+func (c *AcmeClient) renewCert(name string, res *certificate.Resource, mainDomainSuffix string, certDB database.CertDB) error {
+	domain, keyType := database.SplitKeyType(name)
+	var err error
+	if isMainDomain(domain, mainDomainSuffix) {
+		_, err = c.obtainCert([]string{"*" + mainDomainSuffix, mainDomainSuffix[1:]}, res, "", true, mainDomainSuffix, keyType, certDB)
+	} else {
+		_, err = c.obtainCert([]string{domain}, res, "", false, mainDomainSuffix, keyType, certDB)
+	}
+	return err
+}
+
+// isMainDomain reports whether domain is the key or the domain of the main domain certificate.
+func isMainDomain(domain, mainDomainSuffix string) bool {
+	return strings.EqualFold(strings.TrimPrefix(domain, "*"), mainDomainSuffix) || strings.EqualFold(domain, mainDomainSuffix[1:])
+}
+
+func SetupMainDomainCertificates(mainDomainSuffix string, acmeClient *AcmeClient, certDB database.CertDB) error {
+	for _, keyType := range acmeClient.keyTypes {
+		// getting main cert before ACME account so that we can fail here without hitting rate limits
+		mainCertBytes, err := certDB.Get(database.NameWithKeyType(mainDomainSuffix, keyType))
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("cert database is not working: %w", err)
+		}
+
+		if mainCertBytes == nil {
+			_, err = acmeClient.obtainCert([]string{"*" + mainDomainSuffix, mainDomainSuffix[1:]}, nil, "", true, mainDomainSuffix, keyType, certDB)
+			if err != nil {
+				log.Error().Err(err).Msgf("Couldn't renew %s main domain certificate, continuing with mock certs only", keyType)
+			}
 		}
 	}
 
 	return nil
 }
 
-func MaintainCertDB(ctx context.Context, interval time.Duration, acmeClient *AcmeClient, mainDomainSuffix string, certDB database.CertDB) {
+// MaintainCertDB regularly removes expired certificates from the database
+// and schedules renewals for certificates that expire soon.
+func MaintainCertDB(ctx context.Context, interval time.Duration, renewalQueue *RenewalQueue, mainDomainSuffix string, certDB database.CertDB) {
 	for {
 		// delete expired certs that will be invalid until next clean up
 		threshold := time.Now().Add(interval)
 		expiredCertCount := 0
+		scheduledCount := 0
+		foundMainCert := false
 
 		certs, err := certDB.Items(0, 0)
 		if err != nil {
 			log.Error().Err(err).Msg("could not get certs from list")
 		} else {
 			for _, cert := range certs {
-				if !strings.EqualFold(cert.Domain, strings.TrimPrefix(mainDomainSuffix, ".")) {
-					if time.Unix(cert.ValidTill, 0).Before(threshold) {
-						err := certDB.Delete(cert.Domain)
-						if err != nil {
-							log.Error().Err(err).Msgf("Deleting expired certificate for %q failed", cert.Domain)
-						} else {
-							expiredCertCount++
-						}
+				domain, keyType := database.SplitKeyType(cert.Domain)
+				// certs of key types that are not served anymore are kept until they expire
+				renew := renewalQueue.acmeClient.usesKeyType(keyType)
+				validTill := time.Unix(cert.ValidTill, 0)
+				renewBefore := renewalWindow
+				if renew && isMainDomain(domain, mainDomainSuffix) {
+					foundMainCert = true
+					renewBefore = mainDomainRenewalWindow
+				} else if validTill.Before(threshold) {
+					err := certDB.Delete(cert.Domain)
+					if err != nil {
+						log.Error().Err(err).Msgf("Deleting expired certificate for %q failed", cert.Domain)
+					} else {
+						expiredCertCount++
 					}
+					continue
+				}
+
+				// schedule the renewal, failed renewals are already scheduled with a backoff
+				if renew && cert.NextAttempt == 0 && validTill.Before(time.Now().Add(renewBefore)) {
+					cert.NextAttempt = time.Now().Unix()
+					if err := certDB.UpdateRenewal(cert); err != nil {
+						log.Error().Err(err).Msgf("Scheduling renewal for %q failed", cert.Domain)
+						continue
+					}
+					renewalQueue.Enqueue(cert)
+					scheduledCount++
 				}
 			}
 			log.Debug().Msgf("Removed %d expired certificates from the database", expiredCertCount)
-		}
+			log.Debug().Msgf("Scheduled %d certificates for renewal", scheduledCount)
 
-		// update main cert
-		res, err := certDB.Get(mainDomainSuffix)
-		if err != nil {
-			log.Error().Msgf("Couldn't get cert for domain %q", mainDomainSuffix)
-		} else if res == nil {
-			log.Error().Msgf("Couldn't renew certificate for main domain %q expected main domain cert to exist, but it's missing - seems like the database is corrupted", mainDomainSuffix)
-		} else {
-			tlsCertificates, err := certcrypto.ParsePEMBundle(res.Certificate)
-			if err != nil {
-				log.Error().Err(fmt.Errorf("could not parse cert for mainDomainSuffix: %w", err))
-			} else if tlsCertificates[0].NotAfter.Before(time.Now().Add(30 * 24 * time.Hour)) {
-				// renew main certificate 30 days before it expires
-				go (func() {
-					_, err = acmeClient.obtainCert(acmeClient.dnsChallengerLegoClient, []string{"*" + mainDomainSuffix, mainDomainSuffix[1:]}, res, "", true, mainDomainSuffix, certDB)
-					if err != nil {
-						log.Error().Err(err).Msg("Couldn't renew certificate for main domain")
-					}
-				})()
+			if !foundMainCert {
+				log.Error().Msgf("Expected main domain cert for %q to exist, but it's missing - seems like the database is corrupted", mainDomainSuffix)
 			}
 		}
 
@@ -369,12 +474,3 @@ func MaintainCertDB(ctx context.Context, interval time.Duration, acmeClient *Acm
 		}
 	}
 }
-
-// leaf returns the parsed leaf certificate, either from c.leaf or by parsing
-// the corresponding c.Certificate[0].
-func leaf(c *tls.Certificate) (*x509.Certificate, error) {
-	if c.Leaf != nil {
-		return c.Leaf, nil
-	}
-	return x509.ParseCertificate(c.Certificate[0])
-}
@@ -0,0 +1,38 @@
+package certificates
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestGetCertificateRemembersFailedKeyTypes(t *testing.T) {
+	db := database.NewMockCertDB(t)
+	keyCache := cache.NewInMemoryCache()
+	acmeClient := &AcmeClient{keyTypes: []string{database.KeyTypeEC256, database.KeyTypeRSA2048}}
+	tlsConfig := TLSConfig(".codeberg.page", nil, acmeClient, "pages", nil,
+		keyCache, cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache(), db)
+
+	ecCert, err := quotaCert("*.codeberg.page", "", database.KeyTypeEC256)
+	assert.NoError(t, err)
+	assert.NoError(t, keyCache.Set(database.NameWithKeyType(".codeberg.page", database.KeyTypeEC256), ecCert, keyCacheTTL))
+	// the RSA certificate is missing, it is looked up only once until the retry interval passed
+	db.Mock.On("Get", database.NameWithKeyType(".codeberg.page", database.KeyTypeRSA2048)).Return(nil, database.ErrNotFound).Once()
+
+	// the client supports RSA certificates only, so the ECDSA certificate is the fallback
+	hello := &tls.ClientHelloInfo{
+		ServerName:        "codeberg.page",
+		SupportedVersions: []uint16{tls.VersionTLS12},
+		CipherSuites:      []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		SignatureSchemes:  []tls.SignatureScheme{tls.PSSWithSHA256},
+	}
+	for i := 0; i < 3; i++ {
+		cert, err := tlsConfig.GetCertificate(hello)
+		assert.NoError(t, err)
+		assert.Same(t, ecCert, cert)
+	}
+}
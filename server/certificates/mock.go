@@ -2,8 +2,8 @@ package certificates
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -18,27 +18,87 @@ import (
 	"codeberg.org/codeberg/pages/server/database"
 )
 
-func mockCert(domain, msg, mainDomainSuffix string, keyDatabase database.CertDB) (*tls.Certificate, error) {
-	key, err := certcrypto.GeneratePrivateKey(certcrypto.RSA2048)
+// mockCertRetryDelay is how long a mock certificate is served before obtaining a real one is tried again,
+// to avoid hitting rate limits for the domain
+const mockCertRetryDelay = 6 * time.Hour
+
+func mockCert(domain, msg, mainDomainSuffix, keyType string, keyDatabase database.CertDB) (*tls.Certificate, error) {
+	subject := pkix.Name{
+		CommonName:   domain,
+		Organization: []string{"Codeberg Pages Error Certificate (couldn't obtain ACME certificate)"},
+		OrganizationalUnit: []string{
+			"Will not try again for 6 hours to avoid hitting rate limits for your domain.",
+			"Check https://docs.codeberg.org/codeberg-pages/troubleshooting/ for troubleshooting tips, and feel " +
+				"free to create an issue at https://codeberg.org/Codeberg/pages-server if you can't solve it.\n",
+			"Error message: " + msg,
+		},
+	}
+	// the renewal queue replaces the cert after mockCertRetryDelay, it stays valid a bit longer than that
+	res, err := selfSignedCert(domain, keyType, subject, time.Now().Add(renewalWindow+mockCertRetryDelay))
+	if err != nil {
+		return nil, err
+	}
+
+	databaseName := domain
+	if domain == "*"+mainDomainSuffix || domain == mainDomainSuffix[1:] {
+		databaseName = mainDomainSuffix
+	}
+	databaseName = database.NameWithKeyType(databaseName, keyType)
+	if err := keyDatabase.Put(databaseName, "", res); err != nil {
+		log.Error().Err(err)
+	} else {
+		// storing the cert has reset its renewal state, so schedule the next attempt in the renewal queue
+		retry := &database.Cert{
+			Domain:      database.NameWithKeyType(res.Domain, keyType),
+			NextAttempt: time.Now().Add(mockCertRetryDelay).Unix(),
+			Attempts:    1,
+			LastError:   msg,
+		}
+		if err := keyDatabase.UpdateRenewal(retry); err != nil {
+			log.Error().Err(err).Msgf("Couldn't schedule renewal of mock certificate for %q", domain)
+		}
+	}
+
+	tlsCertificate, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsCertificate, nil
+}
+
+// quotaCert returns a self-signed certificate explaining that the certificate quota of the owner is exceeded.
+// Unlike mock certificates it is not stored, so a real certificate is obtained as soon as the quota allows it.
+func quotaCert(domain, msg, keyType string) (*tls.Certificate, error) {
+	res, err := selfSignedCert(domain, keyType, pkix.Name{
+		CommonName:   domain,
+		Organization: []string{"Codeberg Pages Error Certificate (certificate quota exceeded)"},
+		OrganizationalUnit: []string{
+			"Error message: " + msg,
+		},
+	}, time.Now().Add(keyCacheTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCertificate, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsCertificate, nil
+}
+
+// selfSignedCert creates a certificate for domain that is signed by its own key.
+func selfSignedCert(domain, keyType string, subject pkix.Name, notAfter time.Time) (*certificate.Resource, error) {
+	key, err := generatePrivateKey(keyType)
 	if err != nil {
 		return nil, err
 	}
 
 	template := x509.Certificate{
 		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName:   domain,
-			Organization: []string{"Codeberg Pages Error Certificate (couldn't obtain ACME certificate)"},
-			OrganizationalUnit: []string{
-				"Will not try again for 6 hours to avoid hitting rate limits for your domain.",
-				"Check https://docs.codeberg.org/codeberg-pages/troubleshooting/ for troubleshooting tips, and feel " +
-					"free to create an issue at https://codeberg.org/Codeberg/pages-server if you can't solve it.\n",
-				"Error message: " + msg,
-			},
-		},
+		Subject:      subject,
 
-		// certificates younger than 7 days are renewed, so this enforces the cert to not be renewed for a 6 hours
-		NotAfter:  time.Now().Add(time.Hour*24*7 + time.Hour*6),
+		NotAfter:  notAfter,
 		NotBefore: time.Now(),
 
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
@@ -49,7 +109,7 @@ func mockCert(domain, msg, mainDomainSuffix string, keyDatabase database.CertDB)
 		rand.Reader,
 		&template,
 		&template,
-		&key.(*rsa.PrivateKey).PublicKey,
+		key.(crypto.Signer).Public(),
 		key,
 	)
 	if err != nil {
@@ -65,23 +125,10 @@ func mockCert(domain, msg, mainDomainSuffix string, keyDatabase database.CertDB)
 		return nil, err
 	}
 	outBytes := out.Bytes()
-	res := &certificate.Resource{
+	return &certificate.Resource{
 		PrivateKey:        certcrypto.PEMEncode(key),
 		Certificate:       outBytes,
 		IssuerCertificate: outBytes,
 		Domain:            domain,
-	}
-	databaseName := domain
-	if domain == "*"+mainDomainSuffix || domain == mainDomainSuffix[1:] {
-		databaseName = mainDomainSuffix
-	}
-	if err := keyDatabase.Put(databaseName, res); err != nil {
-		log.Error().Err(err)
-	}
-
-	tlsCertificate, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
-	if err != nil {
-		return nil, err
-	}
-	return &tlsCertificate, nil
+	}, nil
 }
@@ -2,6 +2,7 @@ package certificates
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -11,9 +12,15 @@ import (
 
 func TestMockCert(t *testing.T) {
 	db := database.NewMockCertDB(t)
-	db.Mock.On("Put", mock.Anything, mock.Anything).Return(nil)
+	db.Mock.On("Put", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	before := time.Now()
+	db.Mock.On("UpdateRenewal", mock.MatchedBy(func(cert *database.Cert) bool {
+		return cert.Domain == "example.com" &&
+			cert.LastError == "some error msg" &&
+			!time.Unix(cert.NextAttempt, 0).Before(before.Add(mockCertRetryDelay).Truncate(time.Second))
+	})).Return(nil).Once()
 
-	cert, err := mockCert("example.com", "some error msg", "codeberg.page", db)
+	cert, err := mockCert("example.com", "some error msg", "codeberg.page", database.DefaultKeyType, db)
 	assert.NoError(t, err)
 	if assert.NotEmpty(t, cert) {
 		assert.NotEmpty(t, cert.Certificate)
@@ -0,0 +1,182 @@
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ocsp"
+
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+const (
+	ocspFetchTimeout = 5 * time.Second
+	// ocspMaxResponseSize limits how much of a responder answer is read
+	ocspMaxResponseSize = 1 << 20
+)
+
+var errNoOCSPServer = errors.New("certificate has no OCSP server")
+
+// ocspStapler attaches OCSP responses to certificates. Responses are stored in the CertDB
+// and refreshed in the background once half of their validity has passed.
+type ocspStapler struct {
+	httpClient *http.Client
+	certDB     database.CertDB
+	keyCache   cache.ICache
+
+	// refreshing contains the domains with a background refresh in progress
+	refreshing sync.Map
+}
+
+func newOCSPStapler(certDB database.CertDB, keyCache cache.ICache) *ocspStapler {
+	return &ocspStapler{
+		httpClient: &http.Client{Timeout: ocspFetchTimeout},
+		certDB:     certDB,
+		keyCache:   keyCache,
+	}
+}
+
+// stapleAndCache sets the OCSPStaple of tlsCertificate to the stored response and puts it into the key cache.
+// Responses that are missing or due for a refresh are fetched in the background, so a slow responder never stalls
+// the handshake. The certificate is served without staple until the refresh replaces it in the key cache, so it is
+// cached before the refresh starts and can't overwrite the stapled copy.
+func (s *ocspStapler) stapleAndCache(domain string, tlsCertificate *tls.Certificate) error {
+	leaf, issuer, refresh := s.staple(domain, tlsCertificate)
+	if err := s.keyCache.Set(domain, tlsCertificate, keyCacheTTL); err != nil {
+		return err
+	}
+	if refresh {
+		go s.refreshInBackground(domain, tlsCertificate, leaf, issuer)
+	}
+	return nil
+}
+
+// staple sets the OCSPStaple of tlsCertificate to the stored response, it reports whether a new response has to be fetched.
+func (s *ocspStapler) staple(domain string, tlsCertificate *tls.Certificate) (leaf, issuer *x509.Certificate, refresh bool) {
+	leaf, issuer, err := leafAndIssuer(tlsCertificate)
+	if err != nil || len(leaf.OCSPServer) == 0 {
+		// e.g. mock certs don't have an OCSP server
+		return nil, nil, false
+	}
+
+	stored, err := s.certDB.GetOCSP(domain)
+	if err != nil {
+		log.Debug().Err(err).Msgf("Couldn't get stored OCSP response for %q", domain)
+	}
+	if len(stored) > 0 {
+		if resp, err := ocsp.ParseResponseForCert(stored, leaf, issuer); err == nil && time.Now().Before(resp.NextUpdate) {
+			tlsCertificate.OCSPStaple = stored
+			return leaf, issuer, needsOCSPRefresh(resp, time.Now())
+		}
+	}
+	return leaf, issuer, true
+}
+
+func (s *ocspStapler) refreshInBackground(domain string, tlsCertificate *tls.Certificate, leaf, issuer *x509.Certificate) {
+	if _, running := s.refreshing.LoadOrStore(domain, struct{}{}); running {
+		return
+	}
+	defer s.refreshing.Delete(domain)
+
+	raw, err := s.fetchAndStore(domain, leaf, issuer)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Couldn't refresh OCSP response for %q", domain)
+		return
+	}
+
+	// the cached certificate may be in use by running handshakes, so cache a copy with the new response
+	refreshed := *tlsCertificate
+	refreshed.OCSPStaple = raw
+	if err := s.keyCache.Set(domain, &refreshed, keyCacheTTL); err != nil {
+		log.Error().Err(err).Msgf("Couldn't cache certificate for %q", domain)
+	}
+}
+
+// fetchAndStore requests a new OCSP response and stores it in the database. Revoked certificates
+// are logged and their response is still stapled, so clients learn about the revocation.
+func (s *ocspStapler) fetchAndStore(domain string, leaf, issuer *x509.Certificate) ([]byte, error) {
+	raw, resp, err := s.fetch(leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == ocsp.Revoked {
+		log.Error().Msgf("Certificate for %q was revoked at %s", domain, resp.RevokedAt.Format(time.RFC3339))
+	}
+	if err := s.certDB.PutOCSP(domain, raw); err != nil {
+		log.Error().Err(err).Msgf("Couldn't store OCSP response for %q", domain)
+	}
+	return raw, nil
+}
+
+func (s *ocspStapler) fetch(leaf, issuer *x509.Certificate) ([]byte, *ocsp.Response, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil, errNoOCSPServer
+	}
+	reqBody, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ocspFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	httpResp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder returned status %d", httpResp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, ocspMaxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	if resp.NextUpdate.IsZero() || !time.Now().Before(resp.NextUpdate) {
+		return nil, nil, errors.New("OCSP response is already outdated")
+	}
+	return raw, resp, nil
+}
+
+// needsOCSPRefresh reports whether half of the validity of the response has passed.
+func needsOCSPRefresh(resp *ocsp.Response, now time.Time) bool {
+	refreshAt := resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+	return !now.Before(refreshAt)
+}
+
+func leafAndIssuer(tlsCertificate *tls.Certificate) (leaf, issuer *x509.Certificate, err error) {
+	if len(tlsCertificate.Certificate) < 2 {
+		return nil, nil, errors.New("certificate chain has no issuer")
+	}
+	leaf = tlsCertificate.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(tlsCertificate.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if issuer, err = x509.ParseCertificate(tlsCertificate.Certificate[1]); err != nil {
+		return nil, nil, err
+	}
+	return leaf, issuer, nil
+}
@@ -0,0 +1,200 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/ocsp"
+
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+// newOCSPResponder starts a stand-in for the OCSP responder of a CA and returns a certificate issued by it
+func newOCSPResponder(t *testing.T, validFor time.Duration) (*tls.Certificate, *int32) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	assert.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		assert.NoError(t, err)
+
+		now := time.Now().Truncate(time.Second)
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(validFor),
+		}, caKey)
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{server.URL},
+	}, ca, key.Public(), caKey)
+	assert.NoError(t, err)
+
+	return &tls.Certificate{
+		Certificate: [][]byte{leafDER, caDER},
+		PrivateKey:  crypto.Signer(key),
+	}, &requests
+}
+
+func TestOCSPStaplerFetchesAndStoresResponse(t *testing.T) {
+	tlsCertificate, requests := newOCSPResponder(t, 24*time.Hour)
+	db := database.NewMockCertDB(t)
+	keyCache := cache.NewInMemoryCache()
+	stapler := newOCSPStapler(db, keyCache)
+
+	stored := make(chan []byte, 1)
+	db.Mock.On("GetOCSP", "example.com").Return(nil, nil).Once()
+	db.Mock.On("PutOCSP", "example.com", mock.Anything).Run(func(args mock.Arguments) {
+		stored <- args.Get(1).([]byte)
+	}).Return(nil).Once()
+
+	// the handshake doesn't wait for the responder, the response is fetched in the background
+	assert.NoError(t, stapler.stapleAndCache("example.com", tlsCertificate))
+	assert.Empty(t, tlsCertificate.OCSPStaple)
+
+	var raw []byte
+	select {
+	case raw = <-stored:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OCSP response was not fetched")
+	}
+	assert.NotEmpty(t, raw)
+	assert.Eventually(t, func() bool {
+		cached, ok := keyCache.Get("example.com")
+		return ok && bytes.Equal(raw, cached.(*tls.Certificate).OCSPStaple)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt32(requests))
+
+	// the stapled copy stays cached
+	time.Sleep(50 * time.Millisecond)
+	cached, _ := keyCache.Get("example.com")
+	assert.EqualValues(t, raw, cached.(*tls.Certificate).OCSPStaple)
+
+	// a stored response that is still fresh is used without asking the responder
+	db.Mock.On("GetOCSP", "example.com").Return(raw, nil).Once()
+	assert.NoError(t, stapler.stapleAndCache("example.com", tlsCertificate))
+	assert.EqualValues(t, raw, tlsCertificate.OCSPStaple)
+	assert.EqualValues(t, 1, atomic.LoadInt32(requests))
+}
+
+func TestOCSPStaplerDoesNotWaitForSlowResponder(t *testing.T) {
+	tlsCertificate, _ := newOCSPResponder(t, 24*time.Hour)
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(unblock) })
+	leaf, err := x509.ParseCertificate(tlsCertificate.Certificate[0])
+	assert.NoError(t, err)
+	leaf.OCSPServer = []string{slow.URL}
+	tlsCertificate.Leaf = leaf
+
+	db := database.NewMockCertDB(t)
+	db.Mock.On("GetOCSP", "example.com").Return(nil, nil).Once()
+
+	start := time.Now()
+	assert.NoError(t, newOCSPStapler(db, cache.NewInMemoryCache()).stapleAndCache("example.com", tlsCertificate))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Empty(t, tlsCertificate.OCSPStaple)
+}
+
+func TestOCSPStaplerRefreshesInBackground(t *testing.T) {
+	// responses valid for two minutes are due for a refresh right away, as they start a minute in the past
+	tlsCertificate, requests := newOCSPResponder(t, time.Minute)
+	db := database.NewMockCertDB(t)
+	keyCache := cache.NewInMemoryCache()
+	stapler := newOCSPStapler(db, keyCache)
+
+	leaf, issuer, err := leafAndIssuer(tlsCertificate)
+	assert.NoError(t, err)
+	stale, _, err := stapler.fetch(leaf, issuer)
+	assert.NoError(t, err)
+
+	refreshed := make(chan struct{})
+	db.Mock.On("GetOCSP", "example.com").Return(stale, nil).Once()
+	db.Mock.On("PutOCSP", "example.com", mock.Anything).Run(func(mock.Arguments) {
+		close(refreshed)
+	}).Return(nil).Once()
+
+	assert.NoError(t, stapler.stapleAndCache("example.com", tlsCertificate))
+	// the old response is served while the new one is fetched
+	assert.EqualValues(t, stale, tlsCertificate.OCSPStaple)
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OCSP response was not refreshed")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok := keyCache.Get("example.com")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt32(requests))
+}
+
+func TestOCSPStaplerSkipsCertsWithoutResponder(t *testing.T) {
+	db := database.NewMockCertDB(t)
+	cert, err := mockCert("example.com", "some error msg", "codeberg.page", database.DefaultKeyType, newPutOnlyDB(t))
+	assert.NoError(t, err)
+
+	keyCache := cache.NewInMemoryCache()
+	assert.NoError(t, newOCSPStapler(db, keyCache).stapleAndCache("example.com", cert))
+	assert.Empty(t, cert.OCSPStaple)
+	cached, ok := keyCache.Get("example.com")
+	assert.True(t, ok)
+	assert.Equal(t, cert, cached)
+}
+
+func newPutOnlyDB(t *testing.T) database.CertDB {
+	db := database.NewMockCertDB(t)
+	db.Mock.On("Put", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	db.Mock.On("UpdateRenewal", mock.Anything).Return(nil)
+	return db
+}
@@ -0,0 +1,112 @@
+package certificates
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+// ErrQuotaExceeded is returned if an owner obtained all certificates the quota allows in the current period
+var ErrQuotaExceeded = errors.New("certificate quota exceeded")
+
+// QuotaPolicy decides how many certificates an owner may obtain for custom domains, the usage is counted in the CertDB.
+type QuotaPolicy struct {
+	certificates int
+	period       time.Duration
+	owners       map[string]int
+	unlimited    map[string]bool
+}
+
+func NewQuotaPolicy(cfg config.QuotaConfig) (*QuotaPolicy, error) {
+	period, err := time.ParseDuration(cfg.Period)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate quota period %q: %w", cfg.Period, err)
+	}
+	if period <= 0 {
+		return nil, fmt.Errorf("certificate quota period must be positive, got %q", cfg.Period)
+	}
+
+	policy := &QuotaPolicy{
+		certificates: cfg.Certificates,
+		period:       period,
+		owners:       make(map[string]int, len(cfg.Owners)),
+		unlimited:    make(map[string]bool, len(cfg.UnlimitedOwners)),
+	}
+	// owners are case-insensitive like in Gitea
+	for owner, certificates := range cfg.Owners {
+		policy.owners[strings.ToLower(owner)] = certificates
+	}
+	for _, owner := range cfg.UnlimitedOwners {
+		policy.unlimited[strings.ToLower(owner)] = true
+	}
+	return policy, nil
+}
+
+// Period returns how long certificates are counted against the quota.
+func (p *QuotaPolicy) Period() time.Duration {
+	return p.period
+}
+
+// Limit returns how many certificates owner may obtain per period, unlimited is set if there is no quota for owner.
+func (p *QuotaPolicy) Limit(owner string) (limit int, unlimited bool) {
+	owner = strings.ToLower(owner)
+	if p.unlimited[owner] {
+		return 0, true
+	}
+	limit, ok := p.owners[owner]
+	if !ok {
+		limit = p.certificates
+	}
+	return limit, limit < 0
+}
+
+// take counts a new certificate of owner, it returns an ErrQuotaExceeded if the quota doesn't allow it.
+func (p *QuotaPolicy) take(owner string, certDB database.CertDB) error {
+	if p == nil {
+		return nil
+	}
+	limit, unlimited := p.Limit(owner)
+	if unlimited {
+		return nil
+	}
+
+	quota, ok, err := certDB.TakeQuota(strings.ToLower(owner), limit, p.period)
+	if err != nil {
+		return fmt.Errorf("could not check certificate quota of %q: %w", owner, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q already obtained %d of %d certificates for custom domains, more can be obtained from %s on",
+			ErrQuotaExceeded, owner, quota.Used, limit, quota.ResetsAt(p.period).UTC().Format(time.RFC1123))
+	}
+	return nil
+}
+
+// quotaExceeded is cached for a custom domain while the quota of its owner prevents obtaining a certificate
+type quotaExceeded struct {
+	message string
+	// cert is a self-signed certificate served instead
+	cert *tls.Certificate
+}
+
+func quotaExceededKey(owner, domain string) string {
+	return strings.ToLower(owner) + "/" + domain
+}
+
+// QuotaExceededMessage returns why no certificate was obtained for domain, if the quota of owner prevented it.
+func QuotaExceededMessage(quotaExceededCache cache.ICache, owner, domain string) (string, bool) {
+	if v, ok := quotaExceededCache.Get(quotaExceededKey(owner, domain)); ok {
+		return v.(*quotaExceeded).message, true
+	}
+	return "", false
+}
+
+// ForgetQuotaExceeded drops the cached quota errors of owner, so that certificates are obtained again right away.
+func ForgetQuotaExceeded(quotaExceededCache cache.ICache, owner string) int {
+	return cache.RemovePrefix(quotaExceededCache, quotaExceededKey(owner, ""))
+}
@@ -0,0 +1,67 @@
+package certificates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestQuotaPolicyLimit(t *testing.T) {
+	policy, err := NewQuotaPolicy(config.QuotaConfig{
+		Certificates:    10,
+		Period:          "24h",
+		Owners:          map[string]int{"Big-Org": 100, "blocked": 0},
+		UnlimitedOwners: []string{"Codeberg"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 24*time.Hour, policy.Period())
+
+	for owner, expected := range map[string]int{"someone": 10, "big-org": 100, "blocked": 0} {
+		limit, unlimited := policy.Limit(owner)
+		assert.EqualValues(t, expected, limit, owner)
+		assert.False(t, unlimited, owner)
+	}
+	_, unlimited := policy.Limit("codeberg")
+	assert.True(t, unlimited)
+
+	policy, err = NewQuotaPolicy(config.QuotaConfig{Certificates: -1, Period: "1h"})
+	assert.NoError(t, err)
+	_, unlimited = policy.Limit("someone")
+	assert.True(t, unlimited)
+
+	_, err = NewQuotaPolicy(config.QuotaConfig{Certificates: 10, Period: "daily"})
+	assert.Error(t, err)
+}
+
+func TestObtainCertRespectsQuota(t *testing.T) {
+	policy, err := NewQuotaPolicy(config.QuotaConfig{Certificates: 10, Period: "24h"})
+	assert.NoError(t, err)
+	acmeClient := &AcmeClient{keyTypes: []string{database.KeyTypeEC256, database.KeyTypeRSA2048}, quota: policy}
+
+	db := database.NewMockCertDB(t)
+	db.Mock.On("TakeQuota", "someone", 10, 24*time.Hour).Return(&database.Quota{Owner: "someone", Used: 10, PeriodStart: time.Now().Unix()}, false, nil).Once()
+
+	_, err = acmeClient.obtainCert([]string{"example.com"}, nil, "Someone", false, ".codeberg.page", database.KeyTypeEC256, db)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestQuotaExceededCache(t *testing.T) {
+	quotaExceededCache := cache.NewInMemoryCache()
+	assert.NoError(t, quotaExceededCache.Set(quotaExceededKey("Someone", "example.com"), &quotaExceeded{message: "quota exceeded"}, time.Minute))
+	assert.NoError(t, quotaExceededCache.Set(quotaExceededKey("someone-else", "example.org"), &quotaExceeded{message: "quota exceeded"}, time.Minute))
+
+	msg, blocked := QuotaExceededMessage(quotaExceededCache, "someone", "example.com")
+	assert.True(t, blocked)
+	assert.EqualValues(t, "quota exceeded", msg)
+
+	assert.EqualValues(t, 1, ForgetQuotaExceeded(quotaExceededCache, "someone"))
+	_, blocked = QuotaExceededMessage(quotaExceededCache, "someone", "example.com")
+	assert.False(t, blocked)
+	_, blocked = QuotaExceededMessage(quotaExceededCache, "someone-else", "example.org")
+	assert.True(t, blocked)
+}
@@ -0,0 +1,138 @@
+package certificates
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+const (
+	// renewalWindow is how long before expiry certificates are renewed
+	renewalWindow = 7 * 24 * time.Hour
+	// mainDomainRenewalWindow is larger, as all pages depend on the main domain certificate
+	mainDomainRenewalWindow = 30 * 24 * time.Hour
+
+	// renewalPollInterval is how often the database is checked for due renewals
+	renewalPollInterval = time.Minute
+	// the delay before retrying a failed renewal doubles with every attempt, up to renewalBackoffMax
+	renewalBackoffBase = 30 * time.Minute
+	renewalBackoffMax  = 24 * time.Hour
+
+	renewalQueueSize = 256
+)
+
+// RenewalQueue renews certificates in the background with a fixed number of workers.
+// The queue itself is persisted in the database through the NextAttempt of every cert,
+// so scheduled and failed renewals survive restarts.
+type RenewalQueue struct {
+	acmeClient       *AcmeClient
+	certDB           database.CertDB
+	mainDomainSuffix string
+	workers          int
+
+	pending chan *database.Cert
+	// queued contains the domains that are pending or being renewed right now
+	queued sync.Map
+}
+
+func NewRenewalQueue(acmeClient *AcmeClient, mainDomainSuffix string, certDB database.CertDB, workers int) *RenewalQueue {
+	return &RenewalQueue{
+		acmeClient:       acmeClient,
+		certDB:           certDB,
+		mainDomainSuffix: mainDomainSuffix,
+		workers:          max(workers, 1),
+		pending:          make(chan *database.Cert, renewalQueueSize),
+	}
+}
+
+// Run starts the workers and polls the database for due renewals until ctx is done.
+func (q *RenewalQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case cert := <-q.pending:
+					q.renew(cert)
+					q.queued.Delete(cert.Domain)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(renewalPollInterval)
+	defer ticker.Stop()
+	for {
+		q.poll()
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue hands a cert to the workers, unless it is already queued. It never blocks.
+func (q *RenewalQueue) Enqueue(cert *database.Cert) bool {
+	if _, queued := q.queued.LoadOrStore(cert.Domain, struct{}{}); queued {
+		return false
+	}
+	select {
+	case q.pending <- cert:
+		return true
+	default:
+		// the queue is full, the next poll picks the cert up again
+		q.queued.Delete(cert.Domain)
+		return false
+	}
+}
+
+func (q *RenewalQueue) poll() {
+	certs, err := q.certDB.PendingRenewals(time.Now().Unix())
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't get pending certificate renewals")
+		return
+	}
+	for _, cert := range certs {
+		q.Enqueue(cert)
+	}
+}
+
+func (q *RenewalQueue) renew(cert *database.Cert) {
+	log.Debug().Msgf("Renewing certificate for %q, attempt %d", cert.Domain, cert.Attempts+1)
+
+	err := q.acmeClient.renewCert(cert.Domain, cert.Raw(), q.mainDomainSuffix, q.certDB)
+	if err == nil {
+		// storing the new certificate has reset the renewal state
+		log.Info().Msgf("Renewed certificate for %q", cert.Domain)
+		return
+	}
+
+	cert.Attempts++
+	cert.LastError = err.Error()
+	cert.NextAttempt = time.Now().Add(renewalBackoff(cert.Attempts)).Unix()
+	log.Error().Err(err).Msgf("Couldn't renew certificate for %q, trying again at %s", cert.Domain, time.Unix(cert.NextAttempt, 0).Format(time.RFC3339))
+
+	if err := q.certDB.UpdateRenewal(cert); err != nil {
+		log.Error().Err(err).Msgf("Couldn't store renewal state of %q", cert.Domain)
+	}
+}
+
+// renewalBackoff returns the delay before the next renewal after the given number of failed attempts.
+func renewalBackoff(attempts int) time.Duration {
+	backoff := renewalBackoffBase
+	for i := 1; i < attempts && backoff < renewalBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, renewalBackoffMax)
+}
@@ -0,0 +1,79 @@
+package certificates
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestRenewalBackoff(t *testing.T) {
+	assert.EqualValues(t, 30*time.Minute, renewalBackoff(1))
+	assert.EqualValues(t, time.Hour, renewalBackoff(2))
+	assert.EqualValues(t, 8*time.Hour, renewalBackoff(5))
+	assert.EqualValues(t, 16*time.Hour, renewalBackoff(6))
+	assert.EqualValues(t, renewalBackoffMax, renewalBackoff(7))
+	assert.EqualValues(t, renewalBackoffMax, renewalBackoff(100))
+}
+
+func TestRenewalQueueBacksOffOnFailure(t *testing.T) {
+	db := database.NewMockCertDB(t)
+	// without a lego client every renewal fails, but must not replace the cert with a mock
+	queue := NewRenewalQueue(&AcmeClient{keyTypes: []string{database.KeyTypeRSA2048}}, ".codeberg.page", db, 1)
+
+	before := time.Now()
+	db.Mock.On("UpdateRenewal", mock.MatchedBy(func(cert *database.Cert) bool {
+		return cert.Domain == "example.com" &&
+			cert.Attempts == 2 &&
+			cert.LastError != "" &&
+			!time.Unix(cert.NextAttempt, 0).Before(before.Add(time.Hour).Truncate(time.Second))
+	})).Return(nil).Once()
+
+	queue.renew(&database.Cert{Domain: "example.com", Attempts: 1, NextAttempt: before.Unix()})
+}
+
+func TestRenewalQueueEnqueueDeduplicates(t *testing.T) {
+	queue := NewRenewalQueue(&AcmeClient{keyTypes: []string{database.KeyTypeRSA2048}}, ".codeberg.page", database.NewMockCertDB(t), 1)
+
+	assert.True(t, queue.Enqueue(&database.Cert{Domain: "example.com"}))
+	assert.False(t, queue.Enqueue(&database.Cert{Domain: "example.com"}))
+	assert.True(t, queue.Enqueue(&database.Cert{Domain: "example.org"}))
+	assert.Len(t, queue.pending, 2)
+}
+
+func TestMaintainCertDBSchedulesRenewals(t *testing.T) {
+	db := database.NewMockCertDB(t)
+	queue := NewRenewalQueue(&AcmeClient{keyTypes: []string{database.KeyTypeEC256, database.KeyTypeRSA2048}}, ".codeberg.page", db, 1)
+	now := time.Now()
+
+	db.Mock.On("Items", 0, 0).Return([]*database.Cert{
+		{Domain: "*.codeberg.page", ValidTill: now.Add(20 * 24 * time.Hour).Unix()},
+		{Domain: "expired.example", ValidTill: now.Add(time.Hour).Unix()},
+		{Domain: "soon.example", ValidTill: now.Add(3 * 24 * time.Hour).Unix()},
+		{Domain: "soon.example#EC256", ValidTill: now.Add(3 * 24 * time.Hour).Unix()},
+		// certs of key types that are not served anymore are not renewed
+		{Domain: "soon.example#RSA4096", ValidTill: now.Add(3 * 24 * time.Hour).Unix()},
+		{Domain: "expired.example#RSA4096", ValidTill: now.Add(time.Hour).Unix()},
+		{Domain: "retry.example", ValidTill: now.Add(3 * 24 * time.Hour).Unix(), NextAttempt: now.Add(time.Hour).Unix(), Attempts: 1},
+		{Domain: "later.example", ValidTill: now.Add(60 * 24 * time.Hour).Unix()},
+	}, nil)
+	db.Mock.On("Delete", "expired.example").Return(nil).Once()
+	db.Mock.On("Delete", "expired.example#RSA4096").Return(nil).Once()
+	db.Mock.On("UpdateRenewal", mock.MatchedBy(func(cert *database.Cert) bool {
+		return cert.Domain == "*.codeberg.page" || cert.Domain == "soon.example" || cert.Domain == "soon.example#EC256"
+	})).Return(nil).Times(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	MaintainCertDB(ctx, 12*time.Hour, queue, ".codeberg.page", db)
+
+	var queued []string
+	for len(queue.pending) > 0 {
+		queued = append(queued, (<-queue.pending).Domain)
+	}
+	assert.ElementsMatch(t, []string{"*.codeberg.page", "soon.example", "soon.example#EC256"}, queued)
+}
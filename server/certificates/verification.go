@@ -0,0 +1,41 @@
+package certificates
+
+import (
+	"errors"
+	"fmt"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	dnsutils "codeberg.org/codeberg/pages/server/dns"
+)
+
+// ErrDomainNotVerified is returned if a custom domain lacks the verification TXT record of its owner
+var ErrDomainNotVerified = errors.New("custom domain is not verified")
+
+// DomainVerification requires owners to publish their token in a TXT record before certificates for custom domains are obtained.
+// A nil *DomainVerification accepts all domains.
+type DomainVerification struct {
+	secret string
+}
+
+func NewDomainVerification(cfg config.VerificationConfig) (*DomainVerification, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("domain verification needs a secret")
+	}
+	return &DomainVerification{secret: cfg.Secret}, nil
+}
+
+// verify returns an ErrDomainNotVerified unless the verification record of domain contains the token of owner.
+func (v *DomainVerification) verify(domain, owner string, dnsLookupCache cache.ICache) error {
+	if v == nil {
+		return nil
+	}
+	token := dnsutils.VerificationToken(v.secret, owner)
+	if !dnsutils.IsVerified(domain, token, dnsLookupCache) {
+		return fmt.Errorf("%w: %q needs a TXT record %s with the token of %q", ErrDomainNotVerified, domain, dnsutils.VerificationRecord(domain), owner)
+	}
+	return nil
+}
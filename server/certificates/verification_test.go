@@ -0,0 +1,31 @@
+package certificates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	dnsutils "codeberg.org/codeberg/pages/server/dns"
+)
+
+func TestDomainVerification(t *testing.T) {
+	verification, err := NewDomainVerification(config.VerificationConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, verification)
+	// disabled verification accepts everything
+	assert.NoError(t, verification.verify("example.org", "owner", cache.NewInMemoryCache()))
+
+	_, err = NewDomainVerification(config.VerificationConfig{Enabled: true})
+	assert.Error(t, err)
+
+	verification, err = NewDomainVerification(config.VerificationConfig{Enabled: true, Secret: "secret"})
+	assert.NoError(t, err)
+
+	dnsLookupCache := cache.NewInMemoryCache()
+	_ = dnsLookupCache.Set("txt/_pages-verify.example.org", []string{dnsutils.VerificationToken("secret", "owner")}, time.Minute)
+	assert.NoError(t, verification.verify("example.org", "owner", dnsLookupCache))
+	assert.ErrorIs(t, verification.verify("example.org", "someone-else", dnsLookupCache), ErrDomainNotVerified)
+}
@@ -0,0 +1,17 @@
+package database
+
+import "strings"
+
+// DeployToken is a Gitea access token a repository is read with instead of the global token
+type DeployToken struct {
+	Owner string `xorm:"pk NOT NULL 'owner'" json:"owner"`
+	Repo  string `xorm:"pk NOT NULL 'repo'" json:"repo"`
+	// Token is encrypted like private keys when stored, the CertDB returns it as plaintext
+	Token   []byte `xorm:"'token'" json:"token"`
+	Created int64  `xorm:"NOT NULL DEFAULT 0 'created'" json:"created"`
+}
+
+// deployTokenKey is the name the token is encrypted for, it can't collide with a domain as those contain no "/"
+func deployTokenKey(owner, repo string) string {
+	return "deploy-token:" + strings.ToLower(owner) + "/" + strings.ToLower(repo)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDeployTokenDB runs the same checks against every CertDB implementation
+func testDeployTokenDB(t *testing.T, certDB CertDB) {
+	_, err := certDB.GetDeployToken("example", "pages")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, certDB.PutDeployToken(&DeployToken{Owner: "Example", Repo: "Pages", Token: []byte("first")}))
+	assert.NoError(t, certDB.PutDeployToken(&DeployToken{Owner: "other", Repo: "pages", Token: []byte("other")}))
+
+	// owner and repo names are case insensitive
+	token, err := certDB.GetDeployToken("example", "PAGES")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "first", token.Token)
+	assert.NotZero(t, token.Created)
+
+	// a new token replaces the old one
+	assert.NoError(t, certDB.PutDeployToken(&DeployToken{Owner: "example", Repo: "pages", Token: []byte("second")}))
+	tokens, err := certDB.DeployTokens()
+	assert.NoError(t, err)
+	if assert.Len(t, tokens, 2) {
+		assert.EqualValues(t, "example", tokens[0].Owner)
+		assert.EqualValues(t, "second", tokens[0].Token)
+		assert.EqualValues(t, "other", tokens[1].Owner)
+	}
+
+	assert.NoError(t, certDB.DeleteDeployToken("example", "pages"))
+	_, err = certDB.GetDeployToken("example", "pages")
+	assert.ErrorIs(t, err, ErrNotFound)
+	// deleting a missing token is not an error
+	assert.NoError(t, certDB.DeleteDeployToken("example", "pages"))
+}
+
+func TestXormDBDeployTokens(t *testing.T) {
+	testDeployTokenDB(t, newTestDB(t))
+}
+
+func TestFileDBDeployTokens(t *testing.T) {
+	certDB := newTestFileDB(t)
+	testDeployTokenDB(t, certDB)
+
+	// the deploy token file must not show up as a cert
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestDeployTokensAreEncrypted(t *testing.T) {
+	encryption, err := NewKeyEncryption(testKey)
+	assert.NoError(t, err)
+
+	xormDB := newTestDB(t)
+	xormDB.encryption = encryption
+	assert.NoError(t, xormDB.PutDeployToken(&DeployToken{Owner: "example", Repo: "pages", Token: []byte("secret")}))
+	stored := new(DeployToken)
+	_, err = xormDB.engine.Where("owner = ? AND repo = ?", "example", "pages").Get(stored)
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(stored.Token))
+
+	fileDB := newTestFileDB(t)
+	fileDB.encryption = encryption
+	assert.NoError(t, fileDB.PutDeployToken(&DeployToken{Owner: "example", Repo: "pages", Token: []byte("secret")}))
+	content, err := os.ReadFile(filepath.Join(fileDB.dir, deployTokenFileName))
+	assert.NoError(t, err)
+	var list []*DeployToken
+	assert.NoError(t, json.Unmarshal(content, &list))
+	if assert.Len(t, list, 1) {
+		assert.True(t, IsEncrypted(list[0].Token))
+	}
+
+	for _, certDB := range []CertDB{xormDB, fileDB} {
+		token, err := certDB.GetDeployToken("example", "pages")
+		assert.NoError(t, err)
+		assert.EqualValues(t, "secret", token.Token)
+	}
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks private keys encrypted by KeyEncryption.
+// The full format is "enc:v1:<key id>:<base64 of nonce and ciphertext>".
+const encryptedPrefix = "enc:v1:"
+
+// EncryptionKeySize is the size of the AES-256 keys used to encrypt private keys.
+const EncryptionKeySize = 32
+
+var ErrUnknownEncryptionKey = errors.New("private key is encrypted with an unknown key")
+
+// KeyEncryption encrypts private keys with AES-GCM before they are stored in the database.
+// Values are always encrypted with the current key, while older keys are only used to decrypt
+// values that were not re-encrypted yet. Plaintext values are passed through on decryption,
+// so existing databases keep working until they are rotated.
+type KeyEncryption struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// NewKeyEncryption creates a KeyEncryption that encrypts with key and additionally can decrypt with oldKeys.
+func NewKeyEncryption(key []byte, oldKeys ...[]byte) (*KeyEncryption, error) {
+	e := &KeyEncryption{aeads: make(map[string]cipher.AEAD, len(oldKeys)+1)}
+
+	for i, k := range append([][]byte{key}, oldKeys...) {
+		id, aead, err := newAEAD(k)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			e.currentID = id
+		}
+		e.aeads[id] = aead
+	}
+
+	return e, nil
+}
+
+func newAEAD(key []byte) (string, cipher.AEAD, error) {
+	if len(key) != EncryptionKeySize {
+		return "", nil, fmt.Errorf("encryption key has to be %d bytes long, got %d", EncryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", nil, err
+	}
+	// the key id only has to tell keys apart, it must not reveal the key
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4]), aead, nil
+}
+
+// Encrypt encrypts plaintext with the current key. The domain is authenticated as well,
+// so an encrypted key can not be copied to another row.
+func (e *KeyEncryption) Encrypt(domain string, plaintext []byte) ([]byte, error) {
+	if e == nil || len(plaintext) == 0 || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	aead := e.aeads[e.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(domain))
+
+	return []byte(encryptedPrefix + e.currentID + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt returns the plaintext of a value created by Encrypt. Plaintext values are returned as is.
+func (e *KeyEncryption) Decrypt(domain string, value []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if e == nil {
+		return nil, fmt.Errorf("%w: no encryption key configured", ErrUnknownEncryptionKey)
+	}
+
+	id, encoded, ok := strings.Cut(string(value[len(encryptedPrefix):]), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed encrypted private key")
+	}
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: key id '%s'", ErrUnknownEncryptionKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted private key: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted private key")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(domain))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt private key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether value was created by KeyEncryption.Encrypt.
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(encryptedPrefix))
+}
+
+// ParseEncryptionKey decodes a base64 encoded key, as generated by "openssl rand -base64 32".
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key has to be %d bytes long, got %d", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadEncryptionKey returns the key given directly or, if that is empty, the key read from keyFile.
+// If neither is set, no key and no error is returned.
+func ReadEncryptionKey(key, keyFile string) ([]byte, error) {
+	if key == "" && keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read encryption key file: %w", err)
+		}
+		key = string(content)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return ParseEncryptionKey(key)
+}
@@ -0,0 +1,826 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/rs/zerolog/log"
+)
+
+// DriverFile selects the file based CertDB, the connection is the directory to store the certs in
+const DriverFile = "file"
+
+const (
+	fileExtCert   = ".crt"
+	fileExtIssuer = ".issuer.crt"
+	fileExtKey    = ".key"
+	fileExtMeta   = ".json"
+	fileExtOCSP   = ".ocsp"
+	lockFileName  = ".lock"
+	// quotaFileName contains the quotas of all owners, it has no .json extension to not be taken for a cert
+	quotaFileName = ".quotas"
+	// deployTokenFileName contains the deploy tokens of all repositories
+	deployTokenFileName = ".deploy-tokens"
+	// takedownFileName contains all takedowns
+	takedownFileName = ".takedowns"
+)
+
+var _ CertDB = fileDB{}
+
+// fileDB stores every cert as PEM files plus JSON metadata in a directory,
+// using the same layout as the "certificates" folder of lego.
+type fileDB struct {
+	dir string
+	// encryption encrypts private keys, if nil they are stored as plaintext
+	encryption *KeyEncryption
+}
+
+// fileMeta is stored in the .json file, its first fields match the resource metadata lego writes
+type fileMeta struct {
+	Domain        string `json:"domain"`
+	CertURL       string `json:"certUrl"`
+	CertStableURL string `json:"certStableUrl"`
+	Created       int64  `json:"created"`
+	Updated       int64  `json:"updated"`
+	ValidTill     int64  `json:"validTill"`
+	Issuer        string `json:"issuer,omitempty"`
+	NextAttempt   int64  `json:"nextAttempt,omitempty"`
+	Attempts      int    `json:"attempts,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+}
+
+// NewCertDB opens the CertDB selected by dbType
+func NewCertDB(dbType, dbConn string, encryption *KeyEncryption) (CertDB, error) {
+	if dbType == DriverFile {
+		return NewFileDB(dbConn, encryption)
+	}
+	return NewXormDB(dbType, dbConn, encryption)
+}
+
+func NewFileDB(dir string, encryption *KeyEncryption) (CertDB, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no certificate directory provided")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create certificate directory: %w", err)
+	}
+
+	return fileDB{
+		dir:        dir,
+		encryption: encryption,
+	}, nil
+}
+
+func (f fileDB) Close() error {
+	return nil
+}
+
+func (f fileDB) Put(domain, issuer string, cert *certificate.Resource) error {
+	log.Trace().Str("domain", cert.Domain).Msg("writing cert to directory")
+
+	domain = integrationTestReplacements(domain)
+	c, err := toCert(domain, issuer, cert)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now().Unix()
+	c.Created, c.Updated = now, now
+	if existing, err := f.read(c.Domain); err == nil {
+		c.Created = existing.Created
+	}
+	return f.write(c)
+}
+
+func (f fileDB) PutCert(cert *Cert) error {
+	log.Trace().Str("domain", cert.Domain).Msg("writing raw cert to directory")
+
+	c := *cert
+	now := time.Now().Unix()
+	if c.Created == 0 {
+		c.Created = now
+	}
+	if c.Updated == 0 {
+		c.Updated = now
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return f.write(&c)
+}
+
+func (f fileDB) Get(domain string) (*certificate.Resource, error) {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	log.Trace().Str("domain", domain).Msg("get cert from directory")
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cert, err := f.read(domain)
+	if err != nil {
+		return nil, err
+	}
+	return cert.Raw(), nil
+}
+
+func (f fileDB) Delete(domain string) error {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	log.Trace().Str("domain", domain).Msg("delete cert from directory")
+	base, err := f.basePath(domain)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// remove the metadata first, so a partly deleted cert is not listed anymore
+	for _, ext := range []string{fileExtMeta, fileExtCert, fileExtIssuer, fileExtKey, fileExtOCSP} {
+		if err := os.Remove(base + ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Items return al certs from the directory sorted by domain, if pageSize is 0 it does not use limit
+func (f fileDB) Items(page, pageSize int) ([]*Cert, error) {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var domains []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExtMeta) {
+			continue
+		}
+		domains = append(domains, unsanitizeDomain(strings.TrimSuffix(name, fileExtMeta)))
+	}
+	sort.Strings(domains)
+
+	// paginated return
+	if pageSize > 0 {
+		if page <= 0 {
+			page = 1
+		}
+		start := (page - 1) * pageSize
+		if start >= len(domains) {
+			return []*Cert{}, nil
+		}
+		domains = domains[start:min(start+pageSize, len(domains))]
+	}
+
+	certs := make([]*Cert, 0, len(domains))
+	for _, domain := range domains {
+		// a single unreadable cert, e.g. one encrypted with a key that is not configured anymore,
+		// must not break the listing of all other certs
+		cert, err := f.read(domain)
+		if err != nil {
+			log.Error().Err(err).Msg("Skipping cert that can't be read")
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func (f fileDB) UpdateRenewal(cert *Cert) error {
+	log.Trace().Str("domain", cert.Domain).Int64("next_attempt", cert.NextAttempt).Msg("update renewal state in directory")
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := f.read(cert.Domain)
+	if err != nil {
+		return err
+	}
+	stored.NextAttempt, stored.Attempts, stored.LastError = cert.NextAttempt, cert.Attempts, cert.LastError
+	return f.write(stored)
+}
+
+func (f fileDB) PendingRenewals(until int64) ([]*Cert, error) {
+	certs, err := f.Items(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*Cert, 0, 8)
+	for _, cert := range certs {
+		if cert.NextAttempt > 0 && cert.NextAttempt <= until {
+			pending = append(pending, cert)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].NextAttempt < pending[j].NextAttempt })
+	return pending, nil
+}
+
+func (f fileDB) GetOCSP(domain string) ([]byte, error) {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cert, err := f.read(domain)
+	if err != nil {
+		return nil, err
+	}
+	return cert.OCSP, nil
+}
+
+func (f fileDB) PutOCSP(domain string, response []byte) error {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	log.Trace().Str("domain", domain).Msg("update ocsp response in directory")
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cert, err := f.read(domain)
+	if err != nil {
+		return err
+	}
+	cert.OCSP = response
+	return f.write(cert)
+}
+
+// read loads a cert from its files and decrypts its private key, the caller has to hold the lock
+func (f fileDB) read(domain string) (*Cert, error) {
+	base, err := f.basePath(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	metaJSON, err := os.ReadFile(base + fileExtMeta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: name='%s'", ErrNotFound, domain)
+	} else if err != nil {
+		return nil, err
+	}
+	var meta fileMeta
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return nil, fmt.Errorf("could not parse metadata of cert '%s': %w", domain, err)
+	}
+
+	cert := &Cert{
+		Domain:        meta.Domain,
+		Created:       meta.Created,
+		Updated:       meta.Updated,
+		ValidTill:     meta.ValidTill,
+		Issuer:        meta.Issuer,
+		CertURL:       meta.CertURL,
+		CertStableURL: meta.CertStableURL,
+		NextAttempt:   meta.NextAttempt,
+		Attempts:      meta.Attempts,
+		LastError:     meta.LastError,
+	}
+	for ext, content := range map[string]*[]byte{
+		fileExtCert:   &cert.Certificate,
+		fileExtIssuer: &cert.IssuerCertificate,
+		fileExtKey:    &cert.PrivateKey,
+		fileExtOCSP:   &cert.OCSP,
+	} {
+		if *content, err = os.ReadFile(base + ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if cert.PrivateKey, err = f.encryption.Decrypt(cert.Domain, cert.PrivateKey); err != nil {
+		return nil, fmt.Errorf("cert '%s': %w", cert.Domain, err)
+	}
+	return cert, nil
+}
+
+// write stores all files of a cert, the caller has to hold the lock
+func (f fileDB) write(c *Cert) error {
+	base, err := f.basePath(c.Domain)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := f.encryption.Encrypt(c.Domain, c.PrivateKey)
+	if err != nil {
+		return err
+	}
+	metaJSON, err := json.MarshalIndent(fileMeta{
+		Domain:        c.Domain,
+		CertURL:       c.CertURL,
+		CertStableURL: c.CertStableURL,
+		Created:       c.Created,
+		Updated:       c.Updated,
+		ValidTill:     c.ValidTill,
+		Issuer:        c.Issuer,
+		NextAttempt:   c.NextAttempt,
+		Attempts:      c.Attempts,
+		LastError:     c.LastError,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// the metadata is written last, as it marks the cert as existing
+	files := []struct {
+		ext     string
+		content []byte
+		perm    os.FileMode
+	}{
+		{fileExtKey, privateKey, 0o600},
+		{fileExtCert, c.Certificate, 0o644},
+		{fileExtIssuer, c.IssuerCertificate, 0o644},
+		{fileExtMeta, metaJSON, 0o600},
+	}
+	for _, file := range files {
+		if err := writeFileAtomic(base+file.ext, file.content, file.perm); err != nil {
+			return err
+		}
+	}
+
+	if len(c.OCSP) == 0 {
+		if err := os.Remove(base + fileExtOCSP); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return writeFileAtomic(base+fileExtOCSP, c.OCSP, 0o644)
+}
+
+func (f fileDB) TakeQuota(owner string, limit int, period time.Duration) (*Quota, bool, error) {
+	log.Trace().Str("owner", owner).Msg("take certificate quota in directory")
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	quotas, err := f.readQuotas()
+	if err != nil {
+		return nil, false, err
+	}
+	quota, ok := quotas[owner]
+	if !ok {
+		quota = &Quota{Owner: owner}
+		quotas[owner] = quota
+	}
+	if !quota.take(limit, period, time.Now()) {
+		return quota, false, nil
+	}
+	return quota, true, f.writeQuotas(quotas)
+}
+
+func (f fileDB) GetQuota(owner string) (*Quota, error) {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	quotas, err := f.readQuotas()
+	if err != nil {
+		return nil, err
+	}
+	quota, ok := quotas[owner]
+	if !ok {
+		return nil, fmt.Errorf("%w: owner='%s'", ErrNotFound, owner)
+	}
+	return quota, nil
+}
+
+func (f fileDB) Quotas() ([]*Quota, error) {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	quotas, err := f.readQuotas()
+	if err != nil {
+		return nil, err
+	}
+	return sortedQuotas(quotas), nil
+}
+
+func (f fileDB) PutQuota(quota *Quota) error {
+	log.Trace().Str("owner", quota.Owner).Msg("put certificate quota in directory")
+
+	q := *quota
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	quotas, err := f.readQuotas()
+	if err != nil {
+		return err
+	}
+	quotas[q.Owner] = &q
+	return f.writeQuotas(quotas)
+}
+
+func (f fileDB) ResetQuota(owner string) error {
+	log.Trace().Str("owner", owner).Msg("reset certificate quota in directory")
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	quotas, err := f.readQuotas()
+	if err != nil {
+		return err
+	}
+	if _, ok := quotas[owner]; !ok {
+		return nil
+	}
+	delete(quotas, owner)
+	return f.writeQuotas(quotas)
+}
+
+// readQuotas loads the quotas of all owners, the caller has to hold the lock
+func (f fileDB) readQuotas() (map[string]*Quota, error) {
+	quotas := map[string]*Quota{}
+	content, err := os.ReadFile(filepath.Join(f.dir, quotaFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return quotas, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []*Quota
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("could not parse quotas: %w", err)
+	}
+	for _, quota := range list {
+		quotas[quota.Owner] = quota
+	}
+	return quotas, nil
+}
+
+// writeQuotas stores the quotas of all owners, the caller has to hold the exclusive lock
+func (f fileDB) writeQuotas(quotas map[string]*Quota) error {
+	content, err := json.MarshalIndent(sortedQuotas(quotas), "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.dir, quotaFileName), content, 0o600)
+}
+
+func sortedQuotas(quotas map[string]*Quota) []*Quota {
+	list := make([]*Quota, 0, len(quotas))
+	for _, quota := range quotas {
+		list = append(list, quota)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Owner < list[j].Owner })
+	return list
+}
+
+func (f fileDB) PutDeployToken(token *DeployToken) error {
+	log.Trace().Str("owner", token.Owner).Str("repo", token.Repo).Msg("put deploy token in directory")
+
+	t := *token
+	t.Owner, t.Repo = strings.ToLower(t.Owner), strings.ToLower(t.Repo)
+	if t.Created == 0 {
+		t.Created = time.Now().Unix()
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tokens, err := f.readDeployTokens()
+	if err != nil {
+		return err
+	}
+	tokens[t.Owner+"/"+t.Repo] = &t
+	return f.writeDeployTokens(tokens)
+}
+
+func (f fileDB) GetDeployToken(owner, repo string) (*DeployToken, error) {
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tokens, err := f.readDeployTokens()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[owner+"/"+repo]
+	if !ok {
+		return nil, fmt.Errorf("%w: repo='%s/%s'", ErrNotFound, owner, repo)
+	}
+	return token, nil
+}
+
+func (f fileDB) DeployTokens() ([]*DeployToken, error) {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tokens, err := f.readDeployTokens()
+	if err != nil {
+		return nil, err
+	}
+	return sortedDeployTokens(tokens), nil
+}
+
+func (f fileDB) DeleteDeployToken(owner, repo string) error {
+	log.Trace().Str("owner", owner).Str("repo", repo).Msg("delete deploy token in directory")
+	key := strings.ToLower(owner) + "/" + strings.ToLower(repo)
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tokens, err := f.readDeployTokens()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[key]; !ok {
+		return nil
+	}
+	delete(tokens, key)
+	return f.writeDeployTokens(tokens)
+}
+
+// readDeployTokens loads and decrypts the deploy tokens of all repositories, the caller has to hold the lock
+func (f fileDB) readDeployTokens() (map[string]*DeployToken, error) {
+	tokens := map[string]*DeployToken{}
+	content, err := os.ReadFile(filepath.Join(f.dir, deployTokenFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []*DeployToken
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("could not parse deploy tokens: %w", err)
+	}
+	for _, token := range list {
+		if token.Token, err = f.encryption.Decrypt(deployTokenKey(token.Owner, token.Repo), token.Token); err != nil {
+			return nil, fmt.Errorf("deploy token of '%s/%s': %w", token.Owner, token.Repo, err)
+		}
+		tokens[token.Owner+"/"+token.Repo] = token
+	}
+	return tokens, nil
+}
+
+// writeDeployTokens encrypts and stores the deploy tokens of all repositories, the caller has to hold the exclusive lock
+func (f fileDB) writeDeployTokens(tokens map[string]*DeployToken) error {
+	list := sortedDeployTokens(tokens)
+	encrypted := make([]DeployToken, 0, len(list))
+	for _, token := range list {
+		t := *token
+		var err error
+		if t.Token, err = f.encryption.Encrypt(deployTokenKey(t.Owner, t.Repo), t.Token); err != nil {
+			return err
+		}
+		encrypted = append(encrypted, t)
+	}
+	content, err := json.MarshalIndent(encrypted, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.dir, deployTokenFileName), content, 0o600)
+}
+
+func sortedDeployTokens(tokens map[string]*DeployToken) []*DeployToken {
+	list := make([]*DeployToken, 0, len(tokens))
+	for _, token := range tokens {
+		list = append(list, token)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Owner != list[j].Owner {
+			return list[i].Owner < list[j].Owner
+		}
+		return list[i].Repo < list[j].Repo
+	})
+	return list
+}
+
+func (f fileDB) PutTakedown(takedown *Takedown) error {
+	log.Trace().Str("kind", takedown.Kind).Str("target", takedown.Target).Msg("put takedown in directory")
+
+	t := *takedown
+	if t.Created == 0 {
+		t.Created = time.Now().Unix()
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	takedowns, err := f.readTakedowns()
+	if err != nil {
+		return err
+	}
+	takedowns[t.Kind+":"+t.Target] = &t
+	return f.writeTakedowns(takedowns)
+}
+
+func (f fileDB) Takedowns() ([]*Takedown, error) {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	takedowns, err := f.readTakedowns()
+	if err != nil {
+		return nil, err
+	}
+	return sortedTakedowns(takedowns), nil
+}
+
+func (f fileDB) DeleteTakedown(kind, target string) error {
+	log.Trace().Str("kind", kind).Str("target", target).Msg("delete takedown in directory")
+	key := kind + ":" + target
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	takedowns, err := f.readTakedowns()
+	if err != nil {
+		return err
+	}
+	if _, ok := takedowns[key]; !ok {
+		return nil
+	}
+	delete(takedowns, key)
+	return f.writeTakedowns(takedowns)
+}
+
+// readTakedowns loads all takedowns, the caller has to hold the lock
+func (f fileDB) readTakedowns() (map[string]*Takedown, error) {
+	takedowns := map[string]*Takedown{}
+	content, err := os.ReadFile(filepath.Join(f.dir, takedownFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return takedowns, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []*Takedown
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("could not parse takedowns: %w", err)
+	}
+	for _, takedown := range list {
+		takedowns[takedown.Kind+":"+takedown.Target] = takedown
+	}
+	return takedowns, nil
+}
+
+// writeTakedowns stores all takedowns, the caller has to hold the exclusive lock
+func (f fileDB) writeTakedowns(takedowns map[string]*Takedown) error {
+	content, err := json.MarshalIndent(sortedTakedowns(takedowns), "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.dir, takedownFileName), content, 0o600)
+}
+
+func sortedTakedowns(takedowns map[string]*Takedown) []*Takedown {
+	list := make([]*Takedown, 0, len(takedowns))
+	for _, takedown := range takedowns {
+		list = append(list, takedown)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].Target < list[j].Target
+	})
+	return list
+}
+
+// basePath returns the path of the cert files without extension, named like lego does
+func (f fileDB) basePath(domain string) (string, error) {
+	if domain == "" || strings.ContainsAny(domain, "/\\\x00") {
+		return "", fmt.Errorf("invalid domain '%s'", domain)
+	}
+	return filepath.Join(f.dir, strings.ReplaceAll(domain, "*", "_")), nil
+}
+
+func unsanitizeDomain(name string) string {
+	// only the wildcard label is replaced, "_" is not allowed in other host names
+	if strings.HasPrefix(name, "_.") {
+		return "*" + name[1:]
+	}
+	return name
+}
+
+// writeFileAtomic writes content to a temporary file and renames it,
+// so readers never see a partly written file.
+func writeFileAtomic(name string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// lock locks the directory against other processes, exclusive for writing and shared for reading
+func (f fileDB) lock(exclusive bool) (unlock func(), err error) {
+	file, err := os.OpenFile(filepath.Join(f.dir, lockFileName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not lock certificate directory: %w", err)
+	}
+	return func() {
+		if err := unlockFile(file); err != nil {
+			log.Error().Err(err).Msg("could not unlock certificate directory")
+		}
+		file.Close()
+	}, nil
+}
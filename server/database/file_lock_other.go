@@ -0,0 +1,14 @@
+//go:build !unix
+
+package database
+
+import "os"
+
+// lockFile does nothing on platforms without flock, only a single process may use the directory there
+func lockFile(_ *os.File, _ bool) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
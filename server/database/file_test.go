@@ -0,0 +1,163 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFileDB(t *testing.T) fileDB {
+	certDB, err := NewFileDB(t.TempDir(), nil)
+	assert.NoError(t, err)
+	return certDB.(fileDB)
+}
+
+func TestFileDBWildcardCerts(t *testing.T) {
+	certDB := newTestFileDB(t)
+
+	_, err := certDB.Get(".not.found")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.NoError(t, certDB.Put(".wildcard.de", "", &certificate.Resource{
+		Domain:      "*.wildcard.de",
+		CertURL:     "https://acme.example/cert/1",
+		PrivateKey:  []byte("key"),
+		Certificate: localhost_mock_directory_certificate,
+	}))
+
+	c1, err := certDB.Get(".wildcard.de")
+	assert.NoError(t, err)
+	c2, err := certDB.Get("*.wildcard.de")
+	assert.NoError(t, err)
+	assert.EqualValues(t, c1, c2)
+	assert.EqualValues(t, "https://acme.example/cert/1", c1.CertURL)
+	assert.EqualValues(t, "key", c1.PrivateKey)
+
+	// the files are named like lego names them
+	for _, name := range []string{"_.wildcard.de.crt", "_.wildcard.de.issuer.crt", "_.wildcard.de.key", "_.wildcard.de.json"} {
+		assert.FileExists(t, filepath.Join(certDB.dir, name))
+	}
+
+	assert.NoError(t, certDB.Delete(".wildcard.de"))
+	_, err = certDB.Get(".wildcard.de")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	entries, err := os.ReadDir(certDB.dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "only the lock file should be left")
+}
+
+func TestFileDBItemsPagination(t *testing.T) {
+	certDB := newTestFileDB(t)
+	for _, domain := range []string{"c.example", "*.a.example", "b.example"} {
+		assert.NoError(t, certDB.PutCert(&Cert{Domain: domain, Created: 1000, Updated: 2000}))
+	}
+
+	all, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	page1, err := certDB.Items(1, 2)
+	assert.NoError(t, err)
+	page2, err := certDB.Items(2, 2)
+	assert.NoError(t, err)
+	page3, err := certDB.Items(3, 2)
+	assert.NoError(t, err)
+
+	if assert.Len(t, page1, 2) && assert.Len(t, page2, 1) {
+		assert.EqualValues(t, "*.a.example", page1[0].Domain)
+		assert.EqualValues(t, "b.example", page1[1].Domain)
+		assert.EqualValues(t, "c.example", page2[0].Domain)
+		assert.EqualValues(t, 1000, page2[0].Created)
+		assert.EqualValues(t, 2000, page2[0].Updated)
+	}
+	assert.Empty(t, page3)
+}
+
+func TestFileDBEncryptsPrivateKeys(t *testing.T) {
+	certDB := newTestFileDB(t)
+	encryption, err := NewKeyEncryption(testKey)
+	assert.NoError(t, err)
+	certDB.encryption = encryption
+
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "example.com", PrivateKey: []byte("secret")}))
+
+	stored, err := os.ReadFile(filepath.Join(certDB.dir, "example.com.key"))
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(stored))
+
+	res, err := certDB.Get("example.com")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "secret", res.PrivateKey)
+}
+
+func TestFileDBItemsSkipCertsThatCantBeRead(t *testing.T) {
+	certDB := newTestFileDB(t)
+	old, err := NewKeyEncryption(testOldKey)
+	assert.NoError(t, err)
+	certDB.encryption = old
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "b.example", PrivateKey: []byte("old secret")}))
+
+	certDB.encryption, err = NewKeyEncryption(testKey)
+	assert.NoError(t, err)
+	for _, domain := range []string{"a.example", "c.example"} {
+		assert.NoError(t, certDB.PutCert(&Cert{Domain: domain, PrivateKey: []byte("secret")}))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(certDB.dir, "d.example.json"), []byte("{"), 0o600))
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.EqualValues(t, "a.example", items[0].Domain)
+		assert.EqualValues(t, "c.example", items[1].Domain)
+	}
+
+	_, err = certDB.Get("b.example")
+	assert.ErrorIs(t, err, ErrUnknownEncryptionKey)
+}
+
+func TestFileDBRejectsPaths(t *testing.T) {
+	certDB := newTestFileDB(t)
+
+	_, err := certDB.Get("../etc/passwd")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.Error(t, certDB.PutCert(&Cert{Domain: "a/b"}))
+}
+
+func TestFileDBRenewalState(t *testing.T) {
+	certDB := newTestFileDB(t)
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "a.example", Updated: 2000, PrivateKey: []byte("key")}))
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "b.example"}))
+
+	assert.NoError(t, certDB.UpdateRenewal(&Cert{Domain: "a.example", NextAttempt: 200, Attempts: 1, LastError: "failed"}))
+	assert.NoError(t, certDB.UpdateRenewal(&Cert{Domain: "b.example", NextAttempt: 300}))
+	assert.True(t, errors.Is(certDB.UpdateRenewal(&Cert{Domain: "missing.example", NextAttempt: 100}), ErrNotFound))
+
+	pending, err := certDB.PendingRenewals(250)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.EqualValues(t, "a.example", pending[0].Domain)
+		assert.EqualValues(t, 1, pending[0].Attempts)
+		assert.EqualValues(t, "failed", pending[0].LastError)
+		assert.EqualValues(t, 2000, pending[0].Updated)
+		assert.EqualValues(t, "key", pending[0].PrivateKey)
+	}
+}
+
+func TestFileDBCertIssuer(t *testing.T) {
+	certDB := newTestFileDB(t)
+	assert.NoError(t, certDB.Put("example.com", "zerossl", &certificate.Resource{
+		Domain:      "example.com",
+		Certificate: localhost_mock_directory_certificate,
+	}))
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.EqualValues(t, "zerossl", items[0].Issuer)
+	}
+}
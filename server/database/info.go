@@ -0,0 +1,63 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+// CertInfo holds the details of a stored certificate operators are interested in.
+type CertInfo struct {
+	Domain    string    `json:"domain"`
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans"`
+	Serial    string    `json:"serial"`
+	KeyType   string    `json:"key_type"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	CertURL   string    `json:"cert_url,omitempty"`
+}
+
+// ParseCertInfo parses the leaf certificate of res.
+func ParseCertInfo(res *certificate.Resource) (*CertInfo, error) {
+	tlsCertificates, err := certcrypto.ParsePEMBundle(res.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsCertificates) == 0 || tlsCertificates[0] == nil {
+		return nil, fmt.Errorf("parsed cert resource has no cert")
+	}
+	leaf := tlsCertificates[0]
+
+	return &CertInfo{
+		Domain:    res.Domain,
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		SANs:      leaf.DNSNames,
+		Serial:    leaf.SerialNumber.Text(16),
+		KeyType:   publicKeyType(leaf),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		CertURL:   res.CertURL,
+	}, nil
+}
+
+func publicKeyType(leaf *x509.Certificate) string {
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("EC%d", key.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return leaf.PublicKeyAlgorithm.String()
+	}
+}
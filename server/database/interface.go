@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
@@ -13,13 +14,53 @@ import (
 
 type CertDB interface {
 	Close() error
-	Put(name string, cert *certificate.Resource) error
+	// Put stores a newly obtained cert together with the name of the ACME issuer it was obtained from.
+	Put(name, issuer string, cert *certificate.Resource) error
+	// PutCert stores a cert row as is, keeping its timestamps. Zero timestamps are set to the current time.
+	PutCert(cert *Cert) error
 	Get(name string) (*certificate.Resource, error)
 	Delete(key string) error
 	Items(page, pageSize int) ([]*Cert, error)
+	// UpdateRenewal stores only the renewal state (NextAttempt, Attempts and LastError) of a cert.
+	UpdateRenewal(cert *Cert) error
+	// PendingRenewals returns all certs with a renewal scheduled until the given unix time, the most overdue first.
+	PendingRenewals(until int64) ([]*Cert, error)
+	// GetOCSP returns the stored OCSP response of a cert, which is empty if none was stored yet.
+	GetOCSP(name string) ([]byte, error)
+	// PutOCSP stores the OCSP response of a cert. It is removed whenever a new cert is stored.
+	PutOCSP(name string, response []byte) error
+
+	// TakeQuota counts a new certificate of owner, unless the owner already obtained limit certificates
+	// in the current period. It returns the updated quota and whether the certificate was counted.
+	TakeQuota(owner string, limit int, period time.Duration) (*Quota, bool, error)
+	// GetQuota returns the quota of owner, ErrNotFound if the owner never obtained a certificate.
+	GetQuota(owner string) (*Quota, error)
+	// Quotas returns the quotas of all owners.
+	Quotas() ([]*Quota, error)
+	// PutQuota stores a quota as is, replacing the one of the same owner.
+	PutQuota(quota *Quota) error
+	// ResetQuota forgets all certificates counted for owner.
+	ResetQuota(owner string) error
+
+	// PutDeployToken stores the deploy token of a repository, replacing an existing one.
+	PutDeployToken(token *DeployToken) error
+	// GetDeployToken returns the deploy token of a repository, ErrNotFound if it has none.
+	GetDeployToken(owner, repo string) (*DeployToken, error)
+	// DeployTokens returns the deploy tokens of all repositories.
+	DeployTokens() ([]*DeployToken, error)
+	// DeleteDeployToken removes the deploy token of a repository.
+	DeleteDeployToken(owner, repo string) error
+
+	// PutTakedown stores a takedown, replacing an existing one of the same target.
+	PutTakedown(takedown *Takedown) error
+	// Takedowns returns all takedowns.
+	Takedowns() ([]*Takedown, error)
+	// DeleteTakedown lifts the takedown of a target.
+	DeleteTakedown(kind, target string) error
 }
 
 type Cert struct {
+	// Domain is the name of the cert, which includes the key type for certs without the default key type
 	Domain    string `xorm:"pk      NOT NULL UNIQUE    'domain'"`
 	Created   int64  `xorm:"created NOT NULL DEFAULT 0 'created'"`
 	Updated   int64  `xorm:"updated NOT NULL DEFAULT 0 'updated'"`
@@ -30,11 +71,20 @@ type Cert struct {
 	PrivateKey        []byte `xorm:"'private_key'"`
 	Certificate       []byte `xorm:"'certificate'"`
 	IssuerCertificate []byte `xorm:"'issuer_certificate'"`
+	// Issuer is the name of the ACME issuer the cert was obtained from, empty for mock and imported certs
+	Issuer string `xorm:"'issuer'"`
+	// OCSP is the DER encoded OCSP response stapled to the certificate
+	OCSP []byte `xorm:"'ocsp'"`
+	// renewal state, reset whenever a new certificate is stored
+	NextAttempt int64  `xorm:"NOT NULL DEFAULT 0 'next_attempt'"` // unix time of the next renewal attempt, 0 if none is scheduled
+	Attempts    int    `xorm:"NOT NULL DEFAULT 0 'attempts'"`     // failed renewal attempts in a row
+	LastError   string `xorm:"TEXT 'last_error'"`
 }
 
 func (c Cert) Raw() *certificate.Resource {
+	domain, _ := SplitKeyType(c.Domain)
 	return &certificate.Resource{
-		Domain:            c.Domain,
+		Domain:            domain,
 		CertURL:           c.CertURL,
 		CertStableURL:     c.CertStableURL,
 		PrivateKey:        c.PrivateKey,
@@ -43,7 +93,7 @@ func (c Cert) Raw() *certificate.Resource {
 	}
 }
 
-func toCert(name string, c *certificate.Resource) (*Cert, error) {
+func toCert(name, issuer string, c *certificate.Resource) (*Cert, error) {
 	tlsCertificates, err := certcrypto.ParsePEMBundle(c.Certificate)
 	if err != nil {
 		return nil, err
@@ -55,6 +105,7 @@ func toCert(name string, c *certificate.Resource) (*Cert, error) {
 	}
 	validTill := tlsCertificates[0].NotAfter.Unix()
 
+	name, keyType := SplitKeyType(name)
 	// handle wildcard certs
 	if name[:1] == "." {
 		name = "*" + name
@@ -66,7 +117,7 @@ func toCert(name string, c *certificate.Resource) (*Cert, error) {
 	}
 
 	return &Cert{
-		Domain:    c.Domain,
+		Domain:    NameWithKeyType(c.Domain, keyType),
 		ValidTill: validTill,
 
 		CertURL:           c.CertURL,
@@ -74,5 +125,6 @@ func toCert(name string, c *certificate.Resource) (*Cert, error) {
 		PrivateKey:        c.PrivateKey,
 		Certificate:       c.Certificate,
 		IssuerCertificate: c.IssuerCertificate,
+		Issuer:            issuer,
 	}, nil
 }
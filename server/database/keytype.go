@@ -0,0 +1,45 @@
+package database
+
+import "strings"
+
+// Supported key types of certificates, named like ParseCertInfo reports them
+const (
+	KeyTypeEC256   = "EC256"
+	KeyTypeEC384   = "EC384"
+	KeyTypeRSA2048 = "RSA2048"
+	KeyTypeRSA4096 = "RSA4096"
+)
+
+// DefaultKeyType is the key type of certs stored without a key type in their name,
+// which is the one all certs had before the key type became configurable.
+const DefaultKeyType = KeyTypeRSA2048
+
+// keyTypeSeparator separates the domain from the key type in the name of a cert
+const keyTypeSeparator = "#"
+
+// ValidKeyType reports whether keyType is one of the supported key types.
+func ValidKeyType(keyType string) bool {
+	switch keyType {
+	case KeyTypeEC256, KeyTypeEC384, KeyTypeRSA2048, KeyTypeRSA4096:
+		return true
+	default:
+		return false
+	}
+}
+
+// NameWithKeyType returns the name a cert for domain with the given key type is stored under,
+// e.g. "example.com#EC256". Certs with the default or an unknown key type are stored under the domain itself.
+func NameWithKeyType(domain, keyType string) string {
+	if keyType == DefaultKeyType || !ValidKeyType(keyType) {
+		return domain
+	}
+	return domain + keyTypeSeparator + keyType
+}
+
+// SplitKeyType splits a name created by NameWithKeyType into the domain and the key type.
+func SplitKeyType(name string) (domain, keyType string) {
+	if domain, keyType, found := strings.Cut(name, keyTypeSeparator); found {
+		return domain, keyType
+	}
+	return name, DefaultKeyType
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameWithKeyType(t *testing.T) {
+	assert.EqualValues(t, "example.com", NameWithKeyType("example.com", KeyTypeRSA2048))
+	assert.EqualValues(t, "example.com#EC256", NameWithKeyType("example.com", KeyTypeEC256))
+	assert.EqualValues(t, ".codeberg.page#EC384", NameWithKeyType(".codeberg.page", KeyTypeEC384))
+	assert.EqualValues(t, "example.com", NameWithKeyType("example.com", "Ed25519"))
+}
+
+func TestSplitKeyType(t *testing.T) {
+	for _, keyType := range []string{KeyTypeEC256, KeyTypeEC384, KeyTypeRSA2048, KeyTypeRSA4096} {
+		domain, splitKeyType := SplitKeyType(NameWithKeyType("*.codeberg.page", keyType))
+		assert.EqualValues(t, "*.codeberg.page", domain)
+		assert.EqualValues(t, keyType, splitKeyType)
+	}
+}
@@ -5,6 +5,8 @@ package database
 import (
 	certificate "github.com/go-acme/lego/v4/certificate"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // MockCertDB is an autogenerated mock type for the CertDB type
@@ -40,6 +42,60 @@ func (_m *MockCertDB) Delete(key string) error {
 	return r0
 }
 
+// DeleteDeployToken provides a mock function with given fields: owner, repo
+func (_m *MockCertDB) DeleteDeployToken(owner string, repo string) error {
+	ret := _m.Called(owner, repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(owner, repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteTakedown provides a mock function with given fields: kind, target
+func (_m *MockCertDB) DeleteTakedown(kind string, target string) error {
+	ret := _m.Called(kind, target)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(kind, target)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeployTokens provides a mock function with given fields:
+func (_m *MockCertDB) DeployTokens() ([]*DeployToken, error) {
+	ret := _m.Called()
+
+	var r0 []*DeployToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]*DeployToken, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []*DeployToken); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*DeployToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Get provides a mock function with given fields: name
 func (_m *MockCertDB) Get(name string) (*certificate.Resource, error) {
 	ret := _m.Called(name)
@@ -66,6 +122,84 @@ func (_m *MockCertDB) Get(name string) (*certificate.Resource, error) {
 	return r0, r1
 }
 
+// GetDeployToken provides a mock function with given fields: owner, repo
+func (_m *MockCertDB) GetDeployToken(owner string, repo string) (*DeployToken, error) {
+	ret := _m.Called(owner, repo)
+
+	var r0 *DeployToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (*DeployToken, error)); ok {
+		return rf(owner, repo)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) *DeployToken); ok {
+		r0 = rf(owner, repo)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*DeployToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(owner, repo)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOCSP provides a mock function with given fields: name
+func (_m *MockCertDB) GetOCSP(name string) ([]byte, error) {
+	ret := _m.Called(name)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]byte, error)); ok {
+		return rf(name)
+	}
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetQuota provides a mock function with given fields: owner
+func (_m *MockCertDB) GetQuota(owner string) (*Quota, error) {
+	ret := _m.Called(owner)
+
+	var r0 *Quota
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*Quota, error)); ok {
+		return rf(owner)
+	}
+	if rf, ok := ret.Get(0).(func(string) *Quota); ok {
+		r0 = rf(owner)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Quota)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(owner)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Items provides a mock function with given fields: page, pageSize
 func (_m *MockCertDB) Items(page int, pageSize int) ([]*Cert, error) {
 	ret := _m.Called(page, pageSize)
@@ -92,13 +226,222 @@ func (_m *MockCertDB) Items(page int, pageSize int) ([]*Cert, error) {
 	return r0, r1
 }
 
-// Put provides a mock function with given fields: name, cert
-func (_m *MockCertDB) Put(name string, cert *certificate.Resource) error {
-	ret := _m.Called(name, cert)
+// PendingRenewals provides a mock function with given fields: until
+func (_m *MockCertDB) PendingRenewals(until int64) ([]*Cert, error) {
+	ret := _m.Called(until)
+
+	var r0 []*Cert
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]*Cert, error)); ok {
+		return rf(until)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []*Cert); ok {
+		r0 = rf(until)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*Cert)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(until)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Put provides a mock function with given fields: name, issuer, cert
+func (_m *MockCertDB) Put(name string, issuer string, cert *certificate.Resource) error {
+	ret := _m.Called(name, issuer, cert)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, *certificate.Resource) error); ok {
+		r0 = rf(name, issuer, cert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutCert provides a mock function with given fields: cert
+func (_m *MockCertDB) PutCert(cert *Cert) error {
+	ret := _m.Called(cert)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*Cert) error); ok {
+		r0 = rf(cert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutDeployToken provides a mock function with given fields: token
+func (_m *MockCertDB) PutDeployToken(token *DeployToken) error {
+	ret := _m.Called(token)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*DeployToken) error); ok {
+		r0 = rf(token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutOCSP provides a mock function with given fields: name, response
+func (_m *MockCertDB) PutOCSP(name string, response []byte) error {
+	ret := _m.Called(name, response)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte) error); ok {
+		r0 = rf(name, response)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutQuota provides a mock function with given fields: quota
+func (_m *MockCertDB) PutQuota(quota *Quota) error {
+	ret := _m.Called(quota)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*Quota) error); ok {
+		r0 = rf(quota)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutTakedown provides a mock function with given fields: takedown
+func (_m *MockCertDB) PutTakedown(takedown *Takedown) error {
+	ret := _m.Called(takedown)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*Takedown) error); ok {
+		r0 = rf(takedown)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Quotas provides a mock function with given fields:
+func (_m *MockCertDB) Quotas() ([]*Quota, error) {
+	ret := _m.Called()
+
+	var r0 []*Quota
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]*Quota, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []*Quota); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*Quota)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResetQuota provides a mock function with given fields: owner
+func (_m *MockCertDB) ResetQuota(owner string) error {
+	ret := _m.Called(owner)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(owner)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TakeQuota provides a mock function with given fields: owner, limit, period
+func (_m *MockCertDB) TakeQuota(owner string, limit int, period time.Duration) (*Quota, bool, error) {
+	ret := _m.Called(owner, limit, period)
+
+	var r0 *Quota
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(string, int, time.Duration) (*Quota, bool, error)); ok {
+		return rf(owner, limit, period)
+	}
+	if rf, ok := ret.Get(0).(func(string, int, time.Duration) *Quota); ok {
+		r0 = rf(owner, limit, period)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Quota)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int, time.Duration) bool); ok {
+		r1 = rf(owner, limit, period)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(string, int, time.Duration) error); ok {
+		r2 = rf(owner, limit, period)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Takedowns provides a mock function with given fields:
+func (_m *MockCertDB) Takedowns() ([]*Takedown, error) {
+	ret := _m.Called()
+
+	var r0 []*Takedown
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]*Takedown, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []*Takedown); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*Takedown)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateRenewal provides a mock function with given fields: cert
+func (_m *MockCertDB) UpdateRenewal(cert *Cert) error {
+	ret := _m.Called(cert)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, *certificate.Resource) error); ok {
-		r0 = rf(name, cert)
+	if rf, ok := ret.Get(0).(func(*Cert) error); ok {
+		r0 = rf(cert)
 	} else {
 		r0 = ret.Error(0)
 	}
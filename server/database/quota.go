@@ -0,0 +1,29 @@
+package database
+
+import "time"
+
+// Quota counts the certificates an owner obtained in the current quota period
+type Quota struct {
+	Owner string `xorm:"pk NOT NULL 'owner'" json:"owner"`
+	Used  int    `xorm:"NOT NULL DEFAULT 0 'used'" json:"used"`
+	// PeriodStart is the unix time of the first certificate of the current period
+	PeriodStart int64 `xorm:"NOT NULL DEFAULT 0 'period_start'" json:"periodStart"`
+}
+
+// ResetsAt returns when the current period of the quota ends.
+func (q Quota) ResetsAt(period time.Duration) time.Time {
+	return time.Unix(q.PeriodStart, 0).Add(period)
+}
+
+// take counts a certificate unless limit is reached, starting a new period if the current one is over.
+func (q *Quota) take(limit int, period time.Duration, now time.Time) bool {
+	if !now.Before(q.ResetsAt(period)) {
+		q.Used = 0
+		q.PeriodStart = now.Unix()
+	}
+	if q.Used >= limit {
+		return false
+	}
+	q.Used++
+	return true
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaTake(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	quota := &Quota{Owner: "example"}
+
+	assert.True(t, quota.take(2, time.Hour, now))
+	assert.EqualValues(t, now.Unix(), quota.PeriodStart)
+	assert.True(t, quota.take(2, time.Hour, now.Add(time.Minute)))
+	assert.False(t, quota.take(2, time.Hour, now.Add(59*time.Minute)))
+	assert.EqualValues(t, 2, quota.Used)
+
+	// a new period starts once the current one is over
+	assert.True(t, quota.take(2, time.Hour, now.Add(time.Hour)))
+	assert.EqualValues(t, 1, quota.Used)
+	assert.EqualValues(t, now.Add(2*time.Hour), quota.ResetsAt(time.Hour))
+
+	assert.False(t, (&Quota{}).take(0, time.Hour, now))
+}
+
+// testQuotaDB runs the same checks against every CertDB implementation
+func testQuotaDB(t *testing.T, certDB CertDB) {
+	_, err := certDB.GetQuota("example")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for i := 1; i <= 2; i++ {
+		quota, ok, err := certDB.TakeQuota("example", 2, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.EqualValues(t, i, quota.Used)
+	}
+	quota, ok, err := certDB.TakeQuota("example", 2, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.EqualValues(t, 2, quota.Used)
+
+	_, ok, err = certDB.TakeQuota("other", 2, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	quotas, err := certDB.Quotas()
+	assert.NoError(t, err)
+	if assert.Len(t, quotas, 2) {
+		assert.EqualValues(t, "example", quotas[0].Owner)
+		assert.EqualValues(t, 2, quotas[0].Used)
+		assert.EqualValues(t, "other", quotas[1].Owner)
+	}
+
+	// quotas are stored as they are, e.g. when migrating them
+	assert.NoError(t, certDB.PutQuota(&Quota{Owner: "other", Used: 5, PeriodStart: 1000}))
+	assert.NoError(t, certDB.PutQuota(&Quota{Owner: "new", Used: 1, PeriodStart: 2000}))
+	quota, err = certDB.GetQuota("other")
+	assert.NoError(t, err)
+	assert.EqualValues(t, &Quota{Owner: "other", Used: 5, PeriodStart: 1000}, quota)
+	quota, err = certDB.GetQuota("new")
+	assert.NoError(t, err)
+	assert.EqualValues(t, &Quota{Owner: "new", Used: 1, PeriodStart: 2000}, quota)
+
+	assert.NoError(t, certDB.ResetQuota("example"))
+	_, err = certDB.GetQuota("example")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, ok, err = certDB.TakeQuota("example", 2, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestXormDBQuotas(t *testing.T) {
+	testQuotaDB(t, newTestDB(t))
+}
+
+func TestFileDBQuotas(t *testing.T) {
+	certDB := newTestFileDB(t)
+	testQuotaDB(t, certDB)
+
+	// the quota file must not show up as a cert
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kinds of takedown targets
+const (
+	TakedownOwner  = "owner"
+	TakedownRepo   = "repo"
+	TakedownBranch = "branch"
+	TakedownDomain = "domain"
+)
+
+// Takedown blocks all sites of an owner, a repository, a branch or a custom domain
+type Takedown struct {
+	Kind string `xorm:"pk NOT NULL 'kind'" json:"kind"`
+	// Target is the blocked owner, "owner/repo", "owner/repo/branch" or domain, see NormalizeTakedownTarget
+	Target string `xorm:"pk NOT NULL 'target'" json:"target"`
+	Reason string `xorm:"TEXT 'reason'" json:"reason"`
+	// Status is the status code of the page served instead of the site, 451 or 403
+	Status  int   `xorm:"NOT NULL DEFAULT 451 'status'" json:"status"`
+	Created int64 `xorm:"NOT NULL DEFAULT 0 'created'" json:"created"`
+}
+
+// NewTakedown validates a takedown of target. A status of 0 defaults to 451 (Unavailable For Legal Reasons).
+func NewTakedown(kind, target, reason string, status int) (*Takedown, error) {
+	target, err := NormalizeTakedownTarget(kind, target)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case 0:
+		status = http.StatusUnavailableForLegalReasons
+	case http.StatusUnavailableForLegalReasons, http.StatusForbidden:
+	default:
+		return nil, fmt.Errorf("takedown status must be %d or %d, not %d", http.StatusUnavailableForLegalReasons, http.StatusForbidden, status)
+	}
+	return &Takedown{Kind: kind, Target: target, Reason: strings.TrimSpace(reason), Status: status}, nil
+}
+
+// NormalizeTakedownTarget checks that target fits kind and returns it the way it is stored.
+// Owners, repositories and domains are case-insensitive, branches are not.
+func NormalizeTakedownTarget(kind, target string) (string, error) {
+	target = strings.Trim(strings.TrimSpace(target), "/")
+	var parts []string
+	switch kind {
+	case TakedownOwner:
+		parts = []string{target}
+	case TakedownRepo:
+		parts = strings.Split(target, "/")
+		if len(parts) != 2 {
+			return "", fmt.Errorf("repo takedown needs a target like 'owner/repo', not %q", target)
+		}
+	case TakedownBranch:
+		parts = strings.SplitN(target, "/", 3)
+		if len(parts) != 3 {
+			return "", fmt.Errorf("branch takedown needs a target like 'owner/repo/branch', not %q", target)
+		}
+	case TakedownDomain:
+		target = strings.ToLower(strings.TrimSuffix(target, "."))
+		if target == "" || strings.ContainsAny(target, "/:") {
+			return "", fmt.Errorf("invalid domain %q", target)
+		}
+		return target, nil
+	default:
+		return "", fmt.Errorf("unknown takedown kind %q, must be %s, %s, %s or %s", kind, TakedownOwner, TakedownRepo, TakedownBranch, TakedownDomain)
+	}
+
+	for i, part := range parts {
+		if part == "" || (i < 2 && strings.Contains(part, "/")) {
+			return "", fmt.Errorf("invalid %s takedown target %q", kind, target)
+		}
+		if i < 2 {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
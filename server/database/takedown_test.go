@@ -0,0 +1,92 @@
+package database
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTakedown(t *testing.T) {
+	for _, tc := range []struct {
+		kind, target, expected string
+	}{
+		{kind: TakedownOwner, target: "Example", expected: "example"},
+		{kind: TakedownRepo, target: "Example/Pages", expected: "example/pages"},
+		// branch names are case sensitive and may contain slashes
+		{kind: TakedownBranch, target: "/Example/Pages/Feature/X/", expected: "example/pages/Feature/X"},
+		{kind: TakedownDomain, target: "Phishing.Example.com.", expected: "phishing.example.com"},
+	} {
+		takedown, err := NewTakedown(tc.kind, tc.target, " phishing ", 0)
+		if assert.NoError(t, err, tc.target) {
+			assert.EqualValues(t, tc.expected, takedown.Target)
+			assert.EqualValues(t, "phishing", takedown.Reason)
+			assert.EqualValues(t, http.StatusUnavailableForLegalReasons, takedown.Status)
+		}
+	}
+
+	for _, tc := range []struct {
+		kind, target string
+	}{
+		{kind: TakedownOwner, target: ""},
+		{kind: TakedownOwner, target: "example/pages"},
+		{kind: TakedownRepo, target: "example"},
+		{kind: TakedownRepo, target: "example/pages/main"},
+		{kind: TakedownBranch, target: "example/pages"},
+		{kind: TakedownBranch, target: "example//main"},
+		{kind: TakedownDomain, target: "example.com/path"},
+		{kind: "user", target: "example"},
+	} {
+		_, err := NewTakedown(tc.kind, tc.target, "", 0)
+		assert.Error(t, err, "%s %q", tc.kind, tc.target)
+	}
+
+	takedown, err := NewTakedown(TakedownOwner, "example", "", http.StatusForbidden)
+	assert.NoError(t, err)
+	assert.EqualValues(t, http.StatusForbidden, takedown.Status)
+	_, err = NewTakedown(TakedownOwner, "example", "", http.StatusNotFound)
+	assert.Error(t, err)
+}
+
+// testTakedownDB runs the same checks against every CertDB implementation
+func testTakedownDB(t *testing.T, certDB CertDB) {
+	takedowns, err := certDB.Takedowns()
+	assert.NoError(t, err)
+	assert.Empty(t, takedowns)
+
+	assert.NoError(t, certDB.PutTakedown(&Takedown{Kind: TakedownRepo, Target: "example/pages", Reason: "first", Status: 451}))
+	assert.NoError(t, certDB.PutTakedown(&Takedown{Kind: TakedownDomain, Target: "example.com", Status: 403}))
+	// a new takedown of the same target replaces the old one
+	assert.NoError(t, certDB.PutTakedown(&Takedown{Kind: TakedownRepo, Target: "example/pages", Reason: "second", Status: 451}))
+
+	takedowns, err = certDB.Takedowns()
+	assert.NoError(t, err)
+	if assert.Len(t, takedowns, 2) {
+		assert.EqualValues(t, TakedownDomain, takedowns[0].Kind)
+		assert.EqualValues(t, 403, takedowns[0].Status)
+		assert.EqualValues(t, "example/pages", takedowns[1].Target)
+		assert.EqualValues(t, "second", takedowns[1].Reason)
+		assert.NotZero(t, takedowns[1].Created)
+	}
+
+	assert.NoError(t, certDB.DeleteTakedown(TakedownRepo, "example/pages"))
+	// lifting a missing takedown is not an error
+	assert.NoError(t, certDB.DeleteTakedown(TakedownRepo, "example/pages"))
+	takedowns, err = certDB.Takedowns()
+	assert.NoError(t, err)
+	assert.Len(t, takedowns, 1)
+}
+
+func TestXormDBTakedowns(t *testing.T) {
+	testTakedownDB(t, newTestDB(t))
+}
+
+func TestFileDBTakedowns(t *testing.T) {
+	certDB := newTestFileDB(t)
+	testTakedownDB(t, certDB)
+
+	// the takedown file must not show up as a cert
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -21,9 +23,11 @@ var ErrNotFound = errors.New("entry not found")
 
 type xDB struct {
 	engine *xorm.Engine
+	// encryption encrypts private keys, if nil they are stored as plaintext
+	encryption *KeyEncryption
 }
 
-func NewXormDB(dbType, dbConn string) (CertDB, error) {
+func NewXormDB(dbType, dbConn string, encryption *KeyEncryption) (CertDB, error) {
 	if !supportedDriver(dbType) {
 		return nil, fmt.Errorf("not supported db type '%s'", dbType)
 	}
@@ -36,12 +40,13 @@ func NewXormDB(dbType, dbConn string) (CertDB, error) {
 		return nil, err
 	}
 
-	if err := e.Sync2(new(Cert)); err != nil {
+	if err := e.Sync2(new(Cert), new(Quota), new(DeployToken), new(Takedown)); err != nil {
 		return nil, fmt.Errorf("could not sync db model :%w", err)
 	}
 
 	return &xDB{
-		engine: e,
+		engine:     e,
+		encryption: encryption,
 	}, nil
 }
 
@@ -49,14 +54,17 @@ func (x xDB) Close() error {
 	return x.engine.Close()
 }
 
-func (x xDB) Put(domain string, cert *certificate.Resource) error {
+func (x xDB) Put(domain, issuer string, cert *certificate.Resource) error {
 	log.Trace().Str("domain", cert.Domain).Msg("inserting cert to db")
 
 	domain = integrationTestReplacements(domain)
-	c, err := toCert(domain, cert)
+	c, err := toCert(domain, issuer, cert)
 	if err != nil {
 		return err
 	}
+	if c.PrivateKey, err = x.encryption.Encrypt(c.Domain, c.PrivateKey); err != nil {
+		return err
+	}
 
 	sess := x.engine.NewSession()
 	if err := sess.Begin(); err != nil {
@@ -64,8 +72,14 @@ func (x xDB) Put(domain string, cert *certificate.Resource) error {
 	}
 	defer sess.Close()
 
-	if exist, _ := sess.ID(c.Domain).Exist(new(Cert)); exist {
-		if _, err := sess.ID(c.Domain).Update(c); err != nil {
+	exist, err := sess.ID(c.Domain).Exist(new(Cert))
+	if err != nil {
+		return err
+	}
+	if exist {
+		// always reset the issuer, renewal state and ocsp response, they belong to the old cert
+		// (xorm skips empty blobs even if they are listed in MustCols)
+		if _, err := sess.ID(c.Domain).MustCols("issuer", "next_attempt", "attempts", "last_error").SetExpr("ocsp", "NULL").Update(c); err != nil {
 			return err
 		}
 	} else {
@@ -77,6 +91,48 @@ func (x xDB) Put(domain string, cert *certificate.Resource) error {
 	return sess.Commit()
 }
 
+func (x xDB) PutCert(cert *Cert) error {
+	log.Trace().Str("domain", cert.Domain).Msg("inserting raw cert to db")
+
+	// work on a copy, the caller keeps the plaintext key
+	c := *cert
+	var err error
+	if c.PrivateKey, err = x.encryption.Encrypt(c.Domain, c.PrivateKey); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if c.Created == 0 {
+		c.Created = now
+	}
+	if c.Updated == 0 {
+		c.Updated = now
+	}
+
+	sess := x.engine.NewSession()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	exist, err := sess.ID(c.Domain).Exist(new(Cert))
+	if err != nil {
+		return err
+	}
+	// NoAutoTime keeps the timestamps of the given cert, it only applies to the next statement
+	if exist {
+		if _, err := sess.NoAutoTime().ID(c.Domain).AllCols().Update(&c); err != nil {
+			return err
+		}
+	} else {
+		if _, err := sess.NoAutoTime().Insert(&c); err != nil {
+			return err
+		}
+	}
+
+	return sess.Commit()
+}
+
 func (x xDB) Get(domain string) (*certificate.Resource, error) {
 	// handle wildcard certs
 	if domain[:1] == "." {
@@ -91,6 +147,9 @@ func (x xDB) Get(domain string) (*certificate.Resource, error) {
 	} else if !found {
 		return nil, fmt.Errorf("%w: name='%s'", ErrNotFound, domain)
 	}
+	if err := x.decrypt(cert); err != nil {
+		return nil, err
+	}
 	return cert.Raw(), nil
 }
 
@@ -111,17 +170,275 @@ func (x xDB) Items(page, pageSize int) ([]*Cert, error) {
 	// paginated return
 	if pageSize > 0 {
 		certs := make([]*Cert, 0, pageSize)
-		if page >= 0 {
+		if page <= 0 {
 			page = 1
 		}
-		err := x.engine.Limit(pageSize, (page-1)*pageSize).Find(&certs)
-		return certs, err
+		if err := x.engine.Asc("domain").Limit(pageSize, (page-1)*pageSize).Find(&certs); err != nil {
+			return nil, err
+		}
+		return x.decryptListed(certs), nil
 	}
 
 	// return all
 	certs := make([]*Cert, 0, 64)
-	err := x.engine.Find(&certs)
-	return certs, err
+	if err := x.engine.Find(&certs); err != nil {
+		return nil, err
+	}
+	return x.decryptListed(certs), nil
+}
+
+func (x xDB) UpdateRenewal(cert *Cert) error {
+	log.Trace().Str("domain", cert.Domain).Int64("next_attempt", cert.NextAttempt).Msg("update renewal state in db")
+	_, err := x.engine.NoAutoTime().ID(cert.Domain).
+		Cols("next_attempt", "attempts", "last_error").
+		Update(&Cert{NextAttempt: cert.NextAttempt, Attempts: cert.Attempts, LastError: cert.LastError})
+	return err
+}
+
+func (x xDB) PendingRenewals(until int64) ([]*Cert, error) {
+	certs := make([]*Cert, 0, 8)
+	if err := x.engine.Where("next_attempt > 0 AND next_attempt <= ?", until).Asc("next_attempt").Find(&certs); err != nil {
+		return nil, err
+	}
+	return x.decryptListed(certs), nil
+}
+
+func (x xDB) GetOCSP(domain string) ([]byte, error) {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	cert := new(Cert)
+	if found, err := x.engine.ID(domain).Cols("ocsp").Get(cert); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("%w: name='%s'", ErrNotFound, domain)
+	}
+	return cert.OCSP, nil
+}
+
+func (x xDB) PutOCSP(domain string, response []byte) error {
+	// handle wildcard certs
+	if domain[:1] == "." {
+		domain = "*" + domain
+	}
+	domain = integrationTestReplacements(domain)
+
+	log.Trace().Str("domain", domain).Msg("update ocsp response in db")
+	_, err := x.engine.NoAutoTime().ID(domain).Cols("ocsp").Update(&Cert{OCSP: response})
+	return err
+}
+
+func (x xDB) TakeQuota(owner string, limit int, period time.Duration) (*Quota, bool, error) {
+	log.Trace().Str("owner", owner).Msg("take certificate quota in db")
+
+	sess := x.engine.NewSession()
+	if err := sess.Begin(); err != nil {
+		return nil, false, err
+	}
+	defer sess.Close()
+
+	quota := &Quota{Owner: owner}
+	exist, err := sess.ID(owner).Get(quota)
+	if err != nil {
+		return nil, false, err
+	}
+	if !quota.take(limit, period, time.Now()) {
+		return quota, false, sess.Commit()
+	}
+
+	if exist {
+		_, err = sess.ID(owner).AllCols().Update(quota)
+	} else {
+		_, err = sess.Insert(quota)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return quota, true, sess.Commit()
+}
+
+func (x xDB) GetQuota(owner string) (*Quota, error) {
+	quota := new(Quota)
+	if found, err := x.engine.ID(owner).Get(quota); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("%w: owner='%s'", ErrNotFound, owner)
+	}
+	return quota, nil
+}
+
+func (x xDB) Quotas() ([]*Quota, error) {
+	quotas := make([]*Quota, 0, 8)
+	return quotas, x.engine.Asc("owner").Find(&quotas)
+}
+
+func (x xDB) PutQuota(quota *Quota) error {
+	log.Trace().Str("owner", quota.Owner).Msg("put certificate quota in db")
+
+	sess := x.engine.NewSession()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	exist, err := sess.ID(quota.Owner).Exist(new(Quota))
+	if err != nil {
+		return err
+	}
+	if exist {
+		_, err = sess.ID(quota.Owner).AllCols().Update(quota)
+	} else {
+		_, err = sess.Insert(quota)
+	}
+	if err != nil {
+		return err
+	}
+	return sess.Commit()
+}
+
+func (x xDB) ResetQuota(owner string) error {
+	log.Trace().Str("owner", owner).Msg("reset certificate quota in db")
+	_, err := x.engine.ID(owner).Delete(new(Quota))
+	return err
+}
+
+func (x xDB) PutDeployToken(token *DeployToken) error {
+	log.Trace().Str("owner", token.Owner).Str("repo", token.Repo).Msg("put deploy token in db")
+
+	t := *token
+	t.Owner, t.Repo = strings.ToLower(t.Owner), strings.ToLower(t.Repo)
+	if t.Created == 0 {
+		t.Created = time.Now().Unix()
+	}
+	var err error
+	if t.Token, err = x.encryption.Encrypt(deployTokenKey(t.Owner, t.Repo), t.Token); err != nil {
+		return err
+	}
+
+	sess := x.engine.NewSession()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	exist, err := sess.Where("owner = ? AND repo = ?", t.Owner, t.Repo).Exist(new(DeployToken))
+	if err != nil {
+		return err
+	}
+	if exist {
+		_, err = sess.Where("owner = ? AND repo = ?", t.Owner, t.Repo).AllCols().Update(&t)
+	} else {
+		_, err = sess.Insert(&t)
+	}
+	if err != nil {
+		return err
+	}
+	return sess.Commit()
+}
+
+func (x xDB) GetDeployToken(owner, repo string) (*DeployToken, error) {
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+	token := new(DeployToken)
+	if found, err := x.engine.Where("owner = ? AND repo = ?", owner, repo).Get(token); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("%w: repo='%s/%s'", ErrNotFound, owner, repo)
+	}
+	return token, x.decryptDeployTokens(token)
+}
+
+func (x xDB) DeployTokens() ([]*DeployToken, error) {
+	tokens := make([]*DeployToken, 0, 8)
+	if err := x.engine.Asc("owner", "repo").Find(&tokens); err != nil {
+		return nil, err
+	}
+	return tokens, x.decryptDeployTokens(tokens...)
+}
+
+func (x xDB) DeleteDeployToken(owner, repo string) error {
+	log.Trace().Str("owner", owner).Str("repo", repo).Msg("delete deploy token in db")
+	_, err := x.engine.Where("owner = ? AND repo = ?", strings.ToLower(owner), strings.ToLower(repo)).Delete(new(DeployToken))
+	return err
+}
+
+func (x xDB) PutTakedown(takedown *Takedown) error {
+	log.Trace().Str("kind", takedown.Kind).Str("target", takedown.Target).Msg("put takedown in db")
+
+	t := *takedown
+	if t.Created == 0 {
+		t.Created = time.Now().Unix()
+	}
+
+	sess := x.engine.NewSession()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	exist, err := sess.Where("kind = ? AND target = ?", t.Kind, t.Target).Exist(new(Takedown))
+	if err != nil {
+		return err
+	}
+	if exist {
+		_, err = sess.Where("kind = ? AND target = ?", t.Kind, t.Target).AllCols().Update(&t)
+	} else {
+		_, err = sess.Insert(&t)
+	}
+	if err != nil {
+		return err
+	}
+	return sess.Commit()
+}
+
+func (x xDB) Takedowns() ([]*Takedown, error) {
+	takedowns := make([]*Takedown, 0, 8)
+	return takedowns, x.engine.Asc("kind", "target").Find(&takedowns)
+}
+
+func (x xDB) DeleteTakedown(kind, target string) error {
+	log.Trace().Str("kind", kind).Str("target", target).Msg("delete takedown in db")
+	_, err := x.engine.Where("kind = ? AND target = ?", kind, target).Delete(new(Takedown))
+	return err
+}
+
+// decryptDeployTokens replaces the stored tokens with their plaintext
+func (x xDB) decryptDeployTokens(tokens ...*DeployToken) error {
+	for _, token := range tokens {
+		plaintext, err := x.encryption.Decrypt(deployTokenKey(token.Owner, token.Repo), token.Token)
+		if err != nil {
+			return fmt.Errorf("deploy token of '%s/%s': %w", token.Owner, token.Repo, err)
+		}
+		token.Token = plaintext
+	}
+	return nil
+}
+
+// decrypt replaces the stored private key of the cert with its plaintext
+func (x xDB) decrypt(cert *Cert) error {
+	privateKey, err := x.encryption.Decrypt(cert.Domain, cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("cert '%s': %w", cert.Domain, err)
+	}
+	cert.PrivateKey = privateKey
+	return nil
+}
+
+// decryptListed decrypts the private keys of listed certs. Certs that can't be decrypted,
+// e.g. because they were written with a key that is not configured anymore, are logged and left out,
+// so they don't break the listing of all other certs.
+func (x xDB) decryptListed(certs []*Cert) []*Cert {
+	decrypted := certs[:0]
+	for _, cert := range certs {
+		if err := x.decrypt(cert); err != nil {
+			log.Error().Err(err).Msg("Skipping cert whose private key can't be decrypted")
+			continue
+		}
+		decrypted = append(decrypted, cert)
+	}
+	return decrypted
 }
 
 // Supported database drivers
@@ -12,7 +12,7 @@ import (
 func newTestDB(t *testing.T) *xDB {
 	e, err := xorm.NewEngine("sqlite3", ":memory:")
 	assert.NoError(t, err)
-	assert.NoError(t, e.Sync2(new(Cert)))
+	assert.NoError(t, e.Sync2(new(Cert), new(Quota), new(DeployToken), new(Takedown)))
 	return &xDB{engine: e}
 }
 
@@ -25,19 +25,19 @@ func TestSanitizeWildcardCerts(t *testing.T) {
 	// TODO: cert key and domain mismatch are don not fail hard jet
 	// https://codeberg.org/Codeberg/pages-server/src/commit/d8595cee882e53d7f44f1ddc4ef8a1f7b8f31d8d/server/database/interface.go#L64
 	//
-	// assert.Error(t, certDB.Put(".wildcard.de", &certificate.Resource{
+	// assert.Error(t, certDB.Put(".wildcard.de", "", &certificate.Resource{
 	// 	Domain:      "*.localhost.mock.directory",
 	// 	Certificate: localhost_mock_directory_certificate,
 	// }))
 
 	// insert new wildcard cert
-	assert.NoError(t, certDB.Put(".wildcard.de", &certificate.Resource{
+	assert.NoError(t, certDB.Put(".wildcard.de", "", &certificate.Resource{
 		Domain:      "*.wildcard.de",
 		Certificate: localhost_mock_directory_certificate,
 	}))
 
 	// update existing cert
-	assert.NoError(t, certDB.Put(".wildcard.de", &certificate.Resource{
+	assert.NoError(t, certDB.Put(".wildcard.de", "", &certificate.Resource{
 		Domain:      "*.wildcard.de",
 		Certificate: localhost_mock_directory_certificate,
 	}))
@@ -49,6 +49,155 @@ func TestSanitizeWildcardCerts(t *testing.T) {
 	assert.EqualValues(t, c1, c2)
 }
 
+func TestPutCertKeepsTimestamps(t *testing.T) {
+	certDB := newTestDB(t)
+
+	assert.NoError(t, certDB.PutCert(&Cert{
+		Domain:      "example.com",
+		Created:     1000,
+		Updated:     2000,
+		ValidTill:   3000,
+		Certificate: localhost_mock_directory_certificate,
+	}))
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.EqualValues(t, 1000, items[0].Created)
+		assert.EqualValues(t, 2000, items[0].Updated)
+		assert.EqualValues(t, 3000, items[0].ValidTill)
+	}
+}
+
+func TestItemsPagination(t *testing.T) {
+	certDB := newTestDB(t)
+	for _, domain := range []string{"a.example", "b.example", "c.example"} {
+		assert.NoError(t, certDB.PutCert(&Cert{Domain: domain}))
+	}
+
+	page1, err := certDB.Items(1, 2)
+	assert.NoError(t, err)
+	page2, err := certDB.Items(2, 2)
+	assert.NoError(t, err)
+
+	if assert.Len(t, page1, 2) && assert.Len(t, page2, 1) {
+		assert.EqualValues(t, "a.example", page1[0].Domain)
+		assert.EqualValues(t, "b.example", page1[1].Domain)
+		assert.EqualValues(t, "c.example", page2[0].Domain)
+	}
+}
+
+func TestPrivateKeysAreEncrypted(t *testing.T) {
+	certDB := newTestDB(t)
+	encryption, err := NewKeyEncryption(testKey)
+	assert.NoError(t, err)
+	certDB.encryption = encryption
+
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "example.com", PrivateKey: []byte("secret")}))
+
+	stored := new(Cert)
+	_, err = certDB.engine.ID("example.com").Get(stored)
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(stored.PrivateKey))
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.EqualValues(t, "secret", items[0].PrivateKey)
+	}
+}
+
+func TestItemsSkipCertsThatCantBeDecrypted(t *testing.T) {
+	certDB := newTestDB(t)
+	old, err := NewKeyEncryption(testOldKey)
+	assert.NoError(t, err)
+	certDB.encryption = old
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "b.example", PrivateKey: []byte("old secret"), NextAttempt: 1}))
+
+	certDB.encryption, err = NewKeyEncryption(testKey)
+	assert.NoError(t, err)
+	for _, domain := range []string{"a.example", "c.example"} {
+		assert.NoError(t, certDB.PutCert(&Cert{Domain: domain, PrivateKey: []byte("secret"), NextAttempt: 1}))
+	}
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	page, err := certDB.Items(1, 3)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 2) {
+		assert.EqualValues(t, "a.example", page[0].Domain)
+		assert.EqualValues(t, "c.example", page[1].Domain)
+	}
+	pending, err := certDB.PendingRenewals(1)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	_, err = certDB.Get("b.example")
+	assert.ErrorIs(t, err, ErrUnknownEncryptionKey)
+}
+
+func TestRenewalState(t *testing.T) {
+	certDB := newTestDB(t)
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "a.example", Created: 1000, Updated: 2000}))
+	assert.NoError(t, certDB.PutCert(&Cert{Domain: "b.example"}))
+	assert.NoError(t, certDB.Put("c.example", "", &certificate.Resource{
+		Domain:      "c.example",
+		Certificate: localhost_mock_directory_certificate,
+	}))
+
+	assert.NoError(t, certDB.UpdateRenewal(&Cert{Domain: "a.example", NextAttempt: 200, Attempts: 2, LastError: "failed"}))
+	assert.NoError(t, certDB.UpdateRenewal(&Cert{Domain: "b.example", NextAttempt: 100}))
+	assert.NoError(t, certDB.UpdateRenewal(&Cert{Domain: "c.example", NextAttempt: 300}))
+
+	pending, err := certDB.PendingRenewals(250)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.EqualValues(t, "b.example", pending[0].Domain)
+		assert.EqualValues(t, "a.example", pending[1].Domain)
+		assert.EqualValues(t, 2, pending[1].Attempts)
+		assert.EqualValues(t, "failed", pending[1].LastError)
+		// updating the renewal state keeps the timestamps
+		assert.EqualValues(t, 2000, pending[1].Updated)
+	}
+
+	// storing a new certificate resets the renewal state
+	assert.NoError(t, certDB.Put("c.example", "", &certificate.Resource{
+		Domain:      "c.example",
+		Certificate: localhost_mock_directory_certificate,
+	}))
+	pending, err = certDB.PendingRenewals(1000)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestOCSPResponse(t *testing.T) {
+	certDB := newTestDB(t)
+	res := &certificate.Resource{
+		Domain:      "*.wildcard.de",
+		Certificate: localhost_mock_directory_certificate,
+	}
+	assert.NoError(t, certDB.Put(".wildcard.de", "", res))
+
+	response, err := certDB.GetOCSP(".wildcard.de")
+	assert.NoError(t, err)
+	assert.Empty(t, response)
+
+	assert.NoError(t, certDB.PutOCSP(".wildcard.de", []byte("response")))
+	response, err = certDB.GetOCSP("*.wildcard.de")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "response", response)
+
+	// the response belongs to the old certificate
+	assert.NoError(t, certDB.Put(".wildcard.de", "", res))
+	response, err = certDB.GetOCSP(".wildcard.de")
+	assert.NoError(t, err)
+	assert.Empty(t, response)
+
+	_, err = certDB.GetOCSP("not.found")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
 var localhost_mock_directory_certificate = []byte(`-----BEGIN CERTIFICATE-----
 MIIDczCCAlugAwIBAgIIJyBaXHmLk6gwDQYJKoZIhvcNAQELBQAwKDEmMCQGA1UE
 AxMdUGViYmxlIEludGVybWVkaWF0ZSBDQSA0OWE0ZmIwHhcNMjMwMjEwMDEwOTA2
@@ -90,3 +239,55 @@ nvw5RdAq+Km/ZWyJgGikK6cfMmh91YALCDFnoWUWrCjkBaBFKrG59ONV9f0IQX07
 aNfFXFCF5l466xw9dHjw5iaFib10cpY3iq4kyPYIMs6uaewkCtxWKKjiozM4g4w3
 HqwyUyZ52WUJOJ/6G9DJLDtN3fgGR+IAp8BhYd5CqOscnt3h
 -----END CERTIFICATE-----`)
+
+func TestCertsWithKeyTypes(t *testing.T) {
+	certDB := newTestDB(t)
+
+	for _, name := range []string{".wildcard.de", ".wildcard.de#EC256"} {
+		assert.NoError(t, certDB.Put(name, "", &certificate.Resource{
+			Domain:      "*.wildcard.de",
+			Certificate: localhost_mock_directory_certificate,
+		}))
+	}
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	var domains []string
+	for _, item := range items {
+		domains = append(domains, item.Domain)
+	}
+	assert.ElementsMatch(t, []string{"*.wildcard.de", "*.wildcard.de#EC256"}, domains)
+
+	cert, err := certDB.Get(".wildcard.de#EC256")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "*.wildcard.de", cert.Domain)
+
+	assert.NoError(t, certDB.Delete(".wildcard.de#EC256"))
+	_, err = certDB.Get(".wildcard.de#EC256")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = certDB.Get(".wildcard.de")
+	assert.NoError(t, err)
+}
+
+func TestCertIssuer(t *testing.T) {
+	certDB := newTestDB(t)
+	res := &certificate.Resource{
+		Domain:      "example.com",
+		Certificate: localhost_mock_directory_certificate,
+	}
+	assert.NoError(t, certDB.Put("example.com", "letsencrypt", res))
+
+	items, err := certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.EqualValues(t, "letsencrypt", items[0].Issuer)
+	}
+
+	// certs without an issuer like mock certs must not keep the issuer of the replaced cert
+	assert.NoError(t, certDB.Put("example.com", "", res))
+	items, err = certDB.Items(0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Empty(t, items[0].Issuer)
+	}
+}
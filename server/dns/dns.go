@@ -1,6 +1,9 @@
 package dns
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -11,52 +14,161 @@ import (
 // lookupCacheTimeout specifies the timeout for the DNS lookup cache.
 var lookupCacheTimeout = 15 * time.Minute
 
+// negativeCacheTimeout is used for failed lookups instead, so that fixed or new records are noticed soon.
+var negativeCacheTimeout = time.Minute
+
 var defaultPagesRepo = "pages"
 
-// GetTargetFromDNS searches for CNAME or TXT entries on the request domain ending with MainDomainSuffix.
-// If everything is fine, it returns the target data.
-func GetTargetFromDNS(domain, mainDomainSuffix, firstDefaultBranch string, dnsLookupCache cache.ICache) (targetOwner, targetRepo, targetBranch string) {
+// ErrNotPointingToServer is returned if a domain mapped by a TXT record resolves to other addresses than the public IPs of the server.
+var ErrNotPointingToServer = errors.New("domain does not point to the pages server")
+
+// Resolver looks up the DNS records custom domains are mapped with, *net.Resolver implements it.
+type Resolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var (
+	resolver Resolver = net.DefaultResolver
+	// publicIPs of the server, the A/AAAA records of domains mapped by TXT records must match them
+	publicIPs []net.IP
+)
+
+// Configure sets the resolver for all lookups and the public IPs of the server, it has to be called before serving requests.
+// Without public IPs the A/AAAA records of domains aren't checked.
+func Configure(r Resolver, ips []net.IP) {
+	resolver = r
+	publicIPs = ips
+}
+
+// ParseIPs parses the configured public IPs of the server.
+func ParseIPs(values []string) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(values))
+	for _, value := range values {
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// lookupResult is cached for every custom domain
+type lookupResult struct {
+	target Target
+	err    error
+}
+
+// GetTargetFromDNS searches for CNAME or TXT entries on the request domain ending with MainDomainSuffix,
+// or a TXT entry "pages-target=owner/repo@branch:/dir".
+// If everything is fine, it returns the target data, an empty owner means that the domain isn't mapped.
+// The error explains why a domain with a TXT record can't be served, e.g. because it points elsewhere.
+func GetTargetFromDNS(domain, mainDomainSuffix, firstDefaultBranch string, dnsLookupCache cache.ICache) (Target, error) {
 	// Get CNAME or TXT
-	var cname string
-	var err error
-	if cachedName, ok := dnsLookupCache.Get(domain); ok {
-		cname = cachedName.(string)
+	var result lookupResult
+	if cached, ok := dnsLookupCache.Get(domain); ok {
+		result = cached.(lookupResult)
 	} else {
-		cname, err = net.LookupCNAME(domain)
-		cname = strings.TrimSuffix(cname, ".")
-		if err != nil || !strings.HasSuffix(cname, mainDomainSuffix) {
-			cname = ""
-			// TODO: check if the A record matches!
-			names, err := net.LookupTXT(domain)
-			if err == nil {
-				for _, name := range names {
-					name = strings.TrimSuffix(strings.TrimSpace(name), ".")
-					if strings.HasSuffix(name, mainDomainSuffix) {
-						cname = name
-						break
-					}
-				}
+		result = lookupTarget(domain, mainDomainSuffix)
+		timeout := lookupCacheTimeout
+		if result.target.Owner == "" {
+			timeout = negativeCacheTimeout
+		}
+		_ = dnsLookupCache.Set(domain, result, timeout)
+	}
+	if result.err != nil {
+		return Target{}, result.err
+	}
+	target := result.target
+	if target.Owner == "" {
+		return target, nil
+	}
+	if target.Repo == "" {
+		target.Repo = defaultPagesRepo
+	}
+	if target.Branch == "" && target.Repo != defaultPagesRepo {
+		target.Branch = firstDefaultBranch
+	}
+	// if target.Branch is still empty, the caller must find the default branch
+	return target, nil
+}
+
+// lookupTarget returns the target domain is mapped to.
+func lookupTarget(domain, mainDomainSuffix string) lookupResult {
+	ctx := context.Background()
+
+	cname, err := resolver.LookupCNAME(ctx, domain)
+	cname = strings.TrimSuffix(cname, ".")
+	if err == nil && strings.HasSuffix(cname, mainDomainSuffix) {
+		return lookupResult{target: parseTargetName(cname, mainDomainSuffix)}
+	}
+
+	txts, err := resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		return lookupResult{}
+	}
+	var target Target
+	for _, txt := range txts {
+		txt = strings.TrimSpace(txt)
+		if value, ok := strings.CutPrefix(txt, targetRecordPrefix); ok {
+			if target, err = ParseTarget(value); err != nil {
+				return lookupResult{err: fmt.Errorf("invalid TXT record %q of %s: %w", txt, domain, err)}
 			}
+			break
 		}
-		_ = dnsLookupCache.Set(domain, cname, lookupCacheTimeout)
+		if name := strings.TrimSuffix(txt, "."); strings.HasSuffix(name, mainDomainSuffix) && target.Owner == "" {
+			target = parseTargetName(name, mainDomainSuffix)
+		}
+	}
+	if target.Owner == "" {
+		return lookupResult{}
+	}
+
+	// unlike a CNAME, a TXT record doesn't make the domain resolve to us
+	if err := checkPublicIPs(ctx, domain); err != nil {
+		return lookupResult{err: err}
+	}
+	return lookupResult{target: target}
+}
+
+// checkPublicIPs makes sure that all A/AAAA records of domain point to the public IPs of the server.
+func checkPublicIPs(ctx context.Context, domain string) error {
+	if len(publicIPs) == 0 {
+		return nil
 	}
-	if cname == "" {
-		return
+
+	addrs, err := resolver.LookupIPAddr(ctx, domain)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: %s has no A/AAAA records, they must point to %s", ErrNotPointingToServer, domain, joinIPs(publicIPs))
 	}
-	cnameParts := strings.Split(strings.TrimSuffix(cname, mainDomainSuffix), ".")
-	targetOwner = cnameParts[len(cnameParts)-1]
-	if len(cnameParts) > 1 {
-		targetRepo = cnameParts[len(cnameParts)-2]
+
+	var foreign []net.IP
+	for _, addr := range addrs {
+		if !containsIP(publicIPs, addr.IP) {
+			foreign = append(foreign, addr.IP)
+		}
 	}
-	if len(cnameParts) > 2 {
-		targetBranch = cnameParts[len(cnameParts)-3]
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: the A/AAAA records of %s point to %s instead of %s", ErrNotPointingToServer, domain, joinIPs(foreign), joinIPs(publicIPs))
 	}
-	if targetRepo == "" {
-		targetRepo = defaultPagesRepo
+	return nil
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return true
+		}
 	}
-	if targetBranch == "" && targetRepo != defaultPagesRepo {
-		targetBranch = firstDefaultBranch
+	return false
+}
+
+func joinIPs(ips []net.IP) string {
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		values = append(values, ip.String())
 	}
-	// if targetBranch is still empty, the caller must find the default branch
-	return
+	return strings.Join(values, ", ")
 }
@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/cache"
+)
+
+var errNoRecord = errors.New("no such record")
+
+// fakeResolver answers from static records
+type fakeResolver struct {
+	cnames map[string]string
+	txts   map[string][]string
+	ips    map[string][]net.IPAddr
+}
+
+func (r *fakeResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	if cname, ok := r.cnames[host]; ok {
+		return cname, nil
+	}
+	return "", errNoRecord
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if txts, ok := r.txts[name]; ok {
+		return txts, nil
+	}
+	return nil, errNoRecord
+}
+
+func (r *fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if ips, ok := r.ips[host]; ok {
+		return ips, nil
+	}
+	return nil, errNoRecord
+}
+
+func useResolver(t *testing.T, r Resolver, ips ...string) {
+	parsed, err := ParseIPs(ips)
+	assert.NoError(t, err)
+	Configure(r, parsed)
+	t.Cleanup(func() { Configure(net.DefaultResolver, nil) })
+}
+
+func TestGetTargetFromDNS(t *testing.T) {
+	useResolver(t, &fakeResolver{
+		cnames: map[string]string{
+			"www.example.org": "main.blog.owner.codeberg.page.",
+		},
+		txts: map[string][]string{
+			"example.org":   {"v=spf1 -all", "owner.codeberg.page"},
+			"example.com":   {"blog.owner.codeberg.page."},
+			"example.net":   {"owner.codeberg.page"},
+			"elsewhere.org": {"owner.codeberg.page"},
+			"docs.example":  {"owner.codeberg.page", "pages-target=owner/my.repo@release/1.0:/docs/"},
+			"broken.org":    {"pages-target=@main"},
+		},
+		ips: map[string][]net.IPAddr{
+			"example.org":   {{IP: net.ParseIP("192.0.2.1")}},
+			"example.com":   {{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("2001:db8::1")}},
+			"elsewhere.org": {{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("198.51.100.7")}},
+			"docs.example":  {{IP: net.ParseIP("192.0.2.1")}},
+		},
+	}, "192.0.2.1", "2001:db8::1")
+
+	target, err := GetTargetFromDNS("www.example.org", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Owner: "owner", Repo: "blog", Branch: "main"}, target)
+
+	target, err = GetTargetFromDNS("example.org", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Owner: "owner", Repo: "pages"}, target)
+
+	target, err = GetTargetFromDNS("example.com", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Owner: "owner", Repo: "blog", Branch: "pages"}, target)
+
+	// the structured record wins over the name under the main domain
+	target, err = GetTargetFromDNS("docs.example", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Owner: "owner", Repo: "my.repo", Branch: "release/1.0", Dir: "docs"}, target)
+
+	_, err = GetTargetFromDNS("broken.org", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.ErrorContains(t, err, "missing owner")
+
+	// no A/AAAA records
+	target, err = GetTargetFromDNS("example.net", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.ErrorIs(t, err, ErrNotPointingToServer)
+	assert.Empty(t, target.Owner)
+
+	// one of the A records points elsewhere
+	target, err = GetTargetFromDNS("elsewhere.org", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.ErrorIs(t, err, ErrNotPointingToServer)
+	assert.ErrorContains(t, err, "198.51.100.7")
+	assert.Empty(t, target.Owner)
+
+	// unknown domains are no error
+	target, err = GetTargetFromDNS("unknown.org", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Empty(t, target.Owner)
+}
+
+func TestGetTargetFromDNSWithoutPublicIPs(t *testing.T) {
+	useResolver(t, &fakeResolver{
+		txts: map[string][]string{"example.net": {"owner.codeberg.page"}},
+	})
+
+	target, err := GetTargetFromDNS("example.net", ".codeberg.page", "pages", cache.NewInMemoryCache())
+	assert.NoError(t, err)
+	assert.Equal(t, "owner", target.Owner)
+}
+
+func TestParseIPs(t *testing.T) {
+	ips, err := ParseIPs([]string{"192.0.2.1", " 2001:db8::1 "})
+	assert.NoError(t, err)
+	assert.Len(t, ips, 2)
+
+	_, err = ParseIPs([]string{"codeberg.page"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	miekg "github.com/miekg/dns"
+)
+
+// ErrNotAuthenticated is returned if DNSSEC is required, but the DNS server didn't validate the answer.
+var ErrNotAuthenticated = errors.New("DNS answer is not authenticated by DNSSEC")
+
+// maxCNAMEChain limits how many CNAMEs in an answer are followed
+const maxCNAMEChain = 8
+
+// NewResolver returns a resolver querying servers with protocol, the system resolver if no servers are given.
+// Every query times out after timeout.
+func NewResolver(servers []string, protocol string, timeout time.Duration, requireDNSSEC bool) (Resolver, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("DNS timeout must be positive, got %s", timeout)
+	}
+
+	if len(servers) == 0 {
+		if requireDNSSEC {
+			return nil, errors.New("requiring DNSSEC needs DNS servers that validate it")
+		}
+		return &systemResolver{resolver: net.DefaultResolver, timeout: timeout}, nil
+	}
+
+	defaultPort := "53"
+	switch protocol {
+	case "udp", "tcp":
+	case "tcp-tls":
+		defaultPort = "853"
+	default:
+		return nil, fmt.Errorf("unknown DNS protocol %q, valid options are \"udp\", \"tcp\" and \"tcp-tls\"", protocol)
+	}
+
+	resolver := &serverResolver{requireDNSSEC: requireDNSSEC}
+	for _, server := range servers {
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			host, port = strings.Trim(server, "[]"), defaultPort
+		}
+		client := &miekg.Client{Net: protocol, Timeout: timeout}
+		if protocol == "tcp-tls" {
+			client.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		resolver.servers = append(resolver.servers, upstreamServer{
+			addr:   net.JoinHostPort(host, port),
+			client: client,
+		})
+	}
+	return resolver, nil
+}
+
+// systemResolver uses the resolver of the operating system with a timeout for every query
+type systemResolver struct {
+	resolver *net.Resolver
+	timeout  time.Duration
+}
+
+func (r *systemResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.resolver.LookupCNAME(ctx, host)
+}
+
+func (r *systemResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.resolver.LookupTXT(ctx, name)
+}
+
+func (r *systemResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.resolver.LookupIPAddr(ctx, host)
+}
+
+type upstreamServer struct {
+	addr   string
+	client *miekg.Client
+}
+
+// serverResolver queries the configured DNS servers in order until one of them answers
+type serverResolver struct {
+	servers       []upstreamServer
+	requireDNSSEC bool
+}
+
+// exchange returns the answer section for name and qtype.
+func (r *serverResolver) exchange(ctx context.Context, name string, qtype uint16) ([]miekg.RR, error) {
+	msg := new(miekg.Msg)
+	msg.SetQuestion(miekg.Fqdn(name), qtype)
+	// ask the server to tell whether it validated the answer
+	msg.AuthenticatedData = true
+	msg.SetEdns0(4096, r.requireDNSSEC)
+
+	var errs []error
+	for _, server := range r.servers {
+		resp, _, err := server.client.ExchangeContext(ctx, msg, server.addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server.addr, err))
+			continue
+		}
+		switch resp.Rcode {
+		case miekg.RcodeSuccess:
+		case miekg.RcodeNameError:
+			return nil, &net.DNSError{Err: "no such host", Name: name, Server: server.addr, IsNotFound: true}
+		default:
+			// SERVFAIL might be temporary, so the next server is asked
+			errs = append(errs, fmt.Errorf("%s: %s", server.addr, miekg.RcodeToString[resp.Rcode]))
+			continue
+		}
+		if r.requireDNSSEC && !resp.AuthenticatedData {
+			return nil, fmt.Errorf("%w: %s", ErrNotAuthenticated, name)
+		}
+		return resp.Answer, nil
+	}
+	return nil, fmt.Errorf("lookup of %s failed: %w", name, errors.Join(errs...))
+}
+
+// LookupCNAME returns the canonical name of host after following all CNAMEs, like net.LookupCNAME.
+func (r *serverResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	// recursive servers include the whole chain in the answer for the address
+	answer, err := r.exchange(ctx, host, miekg.TypeA)
+	if err != nil {
+		return "", err
+	}
+
+	name := miekg.Fqdn(host)
+	for i := 0; i < maxCNAMEChain; i++ {
+		next := ""
+		for _, rr := range answer {
+			if cname, ok := rr.(*miekg.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name, nil
+}
+
+func (r *serverResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answer, err := r.exchange(ctx, name, miekg.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	var txts []string
+	for _, rr := range answer {
+		if txt, ok := rr.(*miekg.TXT); ok {
+			// long records are split into several strings
+			txts = append(txts, strings.Join(txt.Txt, ""))
+		}
+	}
+	return txts, nil
+}
+
+func (r *serverResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	var errs []error
+	for _, qtype := range []uint16{miekg.TypeA, miekg.TypeAAAA} {
+		answer, err := r.exchange(ctx, host, qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rr := range answer {
+			switch rr := rr.(type) {
+			case *miekg.A:
+				addrs = append(addrs, net.IPAddr{IP: rr.A})
+			case *miekg.AAAA:
+				addrs = append(addrs, net.IPAddr{IP: rr.AAAA})
+			}
+		}
+	}
+	if len(errs) == 2 {
+		return nil, errors.Join(errs...)
+	}
+	return addrs, nil
+}
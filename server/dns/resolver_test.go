@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// testZone is answered by the test DNS server, records of names starting with "signed." are marked as authenticated
+var testZone = []string{
+	"www.example.org. 300 IN CNAME blog.example.org.",
+	"blog.example.org. 300 IN CNAME main.blog.owner.codeberg.page.",
+	"main.blog.owner.codeberg.page. 300 IN A 192.0.2.1",
+	"example.org. 300 IN TXT \"owner.codeberg.page\"",
+	"example.org. 300 IN TXT \"long \" \"record\"",
+	"example.org. 300 IN A 192.0.2.1",
+	"example.org. 300 IN AAAA 2001:db8::1",
+	"signed.example.org. 300 IN TXT \"owner.codeberg.page\"",
+}
+
+// startTestServer serves testZone via UDP and returns its address
+func startTestServer(t *testing.T) string {
+	var records []miekg.RR
+	for _, record := range testZone {
+		rr, err := miekg.NewRR(record)
+		assert.NoError(t, err)
+		records = append(records, rr)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &miekg.Server{PacketConn: pc, Handler: miekg.HandlerFunc(func(w miekg.ResponseWriter, req *miekg.Msg) {
+		resp := new(miekg.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		name := question.Name
+		found := false
+		// answer like a recursive server, including the CNAME chain
+		for i := 0; i < maxCNAMEChain; i++ {
+			var next string
+			for _, rr := range records {
+				if !strings.EqualFold(rr.Header().Name, name) {
+					continue
+				}
+				found = true
+				if cname, ok := rr.(*miekg.CNAME); ok {
+					resp.Answer = append(resp.Answer, rr)
+					next = cname.Target
+				} else if rr.Header().Rrtype == question.Qtype {
+					resp.Answer = append(resp.Answer, rr)
+				}
+			}
+			if next == "" {
+				break
+			}
+			name = next
+		}
+		if !found {
+			resp.Rcode = miekg.RcodeNameError
+		}
+		resp.AuthenticatedData = strings.HasPrefix(question.Name, "signed.")
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestServerResolver(t *testing.T) {
+	addr := startTestServer(t)
+	resolver, err := NewResolver([]string{addr}, "udp", time.Second, false)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	cname, err := resolver.LookupCNAME(ctx, "www.example.org")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "main.blog.owner.codeberg.page.", cname)
+
+	cname, err = resolver.LookupCNAME(ctx, "example.org")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "example.org.", cname)
+
+	txts, err := resolver.LookupTXT(ctx, "example.org")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"owner.codeberg.page", "long record"}, txts)
+
+	addrs, err := resolver.LookupIPAddr(ctx, "example.org")
+	assert.NoError(t, err)
+	assert.Len(t, addrs, 2)
+
+	_, err = resolver.LookupTXT(ctx, "unknown.org")
+	var dnsErr *net.DNSError
+	assert.ErrorAs(t, err, &dnsErr)
+	assert.True(t, dnsErr.IsNotFound)
+}
+
+func TestServerResolverFailsOver(t *testing.T) {
+	addr := startTestServer(t)
+	// nothing listens on the first server
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unused := pc.LocalAddr().String()
+	assert.NoError(t, pc.Close())
+
+	resolver, err := NewResolver([]string{unused, addr}, "udp", 200*time.Millisecond, false)
+	assert.NoError(t, err)
+	txts, err := resolver.LookupTXT(context.Background(), "signed.example.org")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"owner.codeberg.page"}, txts)
+}
+
+func TestServerResolverRequiresDNSSEC(t *testing.T) {
+	addr := startTestServer(t)
+	resolver, err := NewResolver([]string{addr}, "udp", time.Second, true)
+	assert.NoError(t, err)
+
+	txts, err := resolver.LookupTXT(context.Background(), "signed.example.org")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"owner.codeberg.page"}, txts)
+
+	_, err = resolver.LookupTXT(context.Background(), "example.org")
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+}
+
+func TestNewResolver(t *testing.T) {
+	resolver, err := NewResolver(nil, "udp", time.Second, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &systemResolver{}, resolver)
+
+	resolver, err = NewResolver([]string{"192.0.2.53", "[2001:db8::53]", "dns.example:8853"}, "tcp-tls", time.Second, false)
+	assert.NoError(t, err)
+	var addrs []string
+	for _, server := range resolver.(*serverResolver).servers {
+		addrs = append(addrs, server.addr)
+	}
+	assert.EqualValues(t, []string{"192.0.2.53:853", "[2001:db8::53]:853", "dns.example:8853"}, addrs)
+
+	_, err = NewResolver(nil, "udp", time.Second, true)
+	assert.Error(t, err)
+	_, err = NewResolver([]string{"192.0.2.53"}, "https", time.Second, false)
+	assert.Error(t, err)
+	_, err = NewResolver(nil, "udp", 0, false)
+	assert.Error(t, err)
+}
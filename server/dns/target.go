@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// targetRecordPrefix starts TXT records describing the target of a custom domain as "pages-target=owner/repo@branch:/dir".
+const targetRecordPrefix = "pages-target="
+
+// Target is the repository a custom domain is served from.
+type Target struct {
+	Owner  string
+	Repo   string
+	Branch string
+	// Dir is the directory of the repository that is served, empty for the whole repository
+	Dir string
+}
+
+// ParseTarget parses the value of a "pages-target=" TXT record, "owner[/repo][@branch][:/dir]".
+// The branch ends at the first colon, which git doesn't allow in branch names.
+func ParseTarget(value string) (Target, error) {
+	var target Target
+	value = strings.TrimSpace(value)
+
+	value, dir, _ := strings.Cut(value, ":")
+	value, target.Branch, _ = strings.Cut(value, "@")
+	target.Owner, target.Repo, _ = strings.Cut(value, "/")
+
+	if target.Owner == "" {
+		return Target{}, errors.New("missing owner")
+	}
+	if strings.ContainsAny(target.Owner, "/ ") || strings.ContainsAny(target.Repo, "/ ") {
+		return Target{}, fmt.Errorf("invalid owner or repo %q", value)
+	}
+	if dir != "" {
+		// the directory can never point outside of the repository
+		target.Dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	}
+	return target, nil
+}
+
+// parseTargetName parses a name under the main domain, "[[branch.]repo.]owner" followed by mainDomainSuffix.
+func parseTargetName(name, mainDomainSuffix string) Target {
+	var target Target
+	parts := strings.Split(strings.TrimSuffix(name, mainDomainSuffix), ".")
+	target.Owner = parts[len(parts)-1]
+	if len(parts) > 1 {
+		target.Repo = parts[len(parts)-2]
+	}
+	if len(parts) > 2 {
+		target.Branch = parts[len(parts)-3]
+	}
+	return target
+}
@@ -0,0 +1,29 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	for value, expected := range map[string]Target{
+		"owner":                         {Owner: "owner"},
+		"owner/repo":                    {Owner: "owner", Repo: "repo"},
+		"owner/my.repo@v1.2":            {Owner: "owner", Repo: "my.repo", Branch: "v1.2"},
+		"owner/repo@feature/x":          {Owner: "owner", Repo: "repo", Branch: "feature/x"},
+		"owner/repo@main:/docs/":        {Owner: "owner", Repo: "repo", Branch: "main", Dir: "docs"},
+		"owner/repo:site/public":        {Owner: "owner", Repo: "repo", Dir: "site/public"},
+		"owner/repo:/../../other-repo/": {Owner: "owner", Repo: "repo", Dir: "other-repo"},
+		" owner/repo:/ ":                {Owner: "owner", Repo: "repo"},
+	} {
+		target, err := ParseTarget(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, target, value)
+	}
+
+	for _, value := range []string{"", "/repo", "@main", "owner/repo/x", "own er/repo"} {
+		_, err := ParseTarget(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,56 @@
+package dns
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"codeberg.org/codeberg/pages/server/cache"
+)
+
+// VerificationPrefix is prepended to a domain to get the name of its verification TXT record.
+const VerificationPrefix = "_pages-verify."
+
+// VerificationToken returns the token owner has to publish to claim custom domains.
+// It is derived from the server secret, so it doesn't need to be stored anywhere.
+func VerificationToken(secret, owner string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.ToLower(owner)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// VerificationRecord returns the name of the TXT record that verifies domain.
+func VerificationRecord(domain string) string {
+	return VerificationPrefix + strings.TrimSuffix(domain, ".")
+}
+
+// IsVerified checks whether the verification TXT record of domain contains token.
+func IsVerified(domain, token string, dnsLookupCache cache.ICache) bool {
+	record := VerificationRecord(domain)
+	// the records are cached instead of the result, as the token changes with the owner of the domain
+	cacheKey := "txt/" + record
+
+	if cached, ok := dnsLookupCache.Get(cacheKey); ok {
+		return containsToken(cached.([]string), token)
+	}
+
+	txts, _ := resolver.LookupTXT(context.Background(), record)
+	verified := containsToken(txts, token)
+	timeout := lookupCacheTimeout
+	if !verified {
+		timeout = negativeCacheTimeout
+	}
+	_ = dnsLookupCache.Set(cacheKey, txts, timeout)
+	return verified
+}
+
+func containsToken(txts []string, token string) bool {
+	for _, txt := range txts {
+		if hmac.Equal([]byte(strings.TrimSpace(txt)), []byte(token)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/cache"
+)
+
+func TestVerificationToken(t *testing.T) {
+	token := VerificationToken("secret", "Owner")
+	assert.Len(t, token, 32)
+	// owners are case-insensitive
+	assert.Equal(t, token, VerificationToken("secret", "owner"))
+	assert.NotEqual(t, token, VerificationToken("secret", "other"))
+	assert.NotEqual(t, token, VerificationToken("other-secret", "owner"))
+}
+
+func TestIsVerified(t *testing.T) {
+	dnsLookupCache := cache.NewInMemoryCache()
+	token := VerificationToken("secret", "owner")
+	_ = dnsLookupCache.Set("txt/_pages-verify.example.org", []string{"unrelated", " " + token + " "}, time.Minute)
+
+	assert.True(t, IsVerified("example.org", token, dnsLookupCache))
+	assert.False(t, IsVerified("example.org", VerificationToken("secret", "other"), dnsLookupCache))
+}
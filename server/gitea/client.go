@@ -11,6 +11,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.gitea.io/sdk/gitea"
@@ -27,6 +28,7 @@ const (
 	// cache key prefixes
 	branchTimestampCacheKeyPrefix = "branchTime"
 	defaultBranchCacheKeyPrefix   = "defaultBranch"
+	repoPrivateCacheKeyPrefix     = "repoPrivate"
 	rawContentCacheKeyPrefix      = "rawContent"
 
 	// pages server
@@ -44,9 +46,17 @@ const (
 )
 
 type Client struct {
-	sdkClient     *gitea.Client
 	responseCache cache.ICache
 
+	// token is the global token, used for repositories without deploy token and owner token
+	token        string
+	ownerTokens  map[string]string
+	deployTokens DeployTokenStore
+	newSDKClient func(token string) (*gitea.Client, error)
+	// sdkClients are created on first use, by cache partition of their token
+	sdkClients      map[string]*gitea.Client
+	sdkClientsMutex sync.RWMutex
+
 	giteaRoot string
 
 	followSymlinks bool
@@ -56,7 +66,9 @@ type Client struct {
 	defaultMimeType    string
 }
 
-func NewClient(cfg config.GiteaConfig, respCache cache.ICache) (*Client, error) {
+// NewClient returns a client that reads repositories with the token configured for their owner, or the global token.
+// Deploy tokens registered for single repositories take precedence if deployTokens is not nil.
+func NewClient(cfg config.GiteaConfig, respCache cache.ICache, deployTokens DeployTokenStore) (*Client, error) {
 	rootURL, err := url.Parse(cfg.Root)
 	if err != nil {
 		return nil, err
@@ -75,17 +87,30 @@ func NewClient(cfg config.GiteaConfig, respCache cache.ICache) (*Client, error)
 		defaultMimeType = "application/octet-stream"
 	}
 
-	sdk, err := gitea.NewClient(
-		giteaRoot,
-		gitea.SetHTTPClient(&stdClient),
-		gitea.SetToken(cfg.Token),
-		gitea.SetUserAgent("pages-server/"+version.Version),
-	)
+	ownerTokens := make(map[string]string, len(cfg.OwnerTokens))
+	for owner, token := range cfg.OwnerTokens {
+		ownerTokens[strings.ToLower(owner)] = token
+	}
+
+	newSDKClient := func(token string) (*gitea.Client, error) {
+		return gitea.NewClient(
+			giteaRoot,
+			gitea.SetHTTPClient(&stdClient),
+			gitea.SetToken(token),
+			gitea.SetUserAgent("pages-server/"+version.Version),
+		)
+	}
+	sdk, err := newSDKClient(cfg.Token)
 
 	return &Client{
-		sdkClient:     sdk,
 		responseCache: respCache,
 
+		token:        cfg.Token,
+		ownerTokens:  ownerTokens,
+		deployTokens: deployTokens,
+		newSDKClient: newSDKClient,
+		sdkClients:   map[string]*gitea.Client{defaultPartition: sdk},
+
 		giteaRoot: giteaRoot,
 
 		followSymlinks: cfg.FollowSymlinks,
@@ -110,7 +135,11 @@ func (client *Client) GiteaRawContent(targetOwner, targetRepo, ref, resource str
 }
 
 func (client *Client) ServeRawContent(targetOwner, targetRepo, ref, resource string) (io.ReadCloser, http.Header, int, error) {
-	cacheKey := fmt.Sprintf("%s/%s/%s|%s|%s", rawContentCacheKeyPrefix, targetOwner, targetRepo, ref, resource)
+	sdk, partition, err := client.repoClient(targetOwner, targetRepo)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%s|%s|%s|%s", rawContentCacheKeyPrefix, strings.ToLower(targetOwner), strings.ToLower(targetRepo), ref, resource, partition)
 	log := log.With().Str("cache_key", cacheKey).Logger()
 	log.Trace().Msg("try file in cache")
 	// handle if cache entry exist
@@ -134,7 +163,7 @@ func (client *Client) ServeRawContent(targetOwner, targetRepo, ref, resource str
 	}
 	log.Trace().Msg("file not in cache")
 	// not in cache, open reader via gitea api
-	reader, resp, err := client.sdkClient.GetFileReader(targetOwner, targetRepo, ref, resource, client.supportLFS)
+	reader, resp, err := sdk.GetFileReader(targetOwner, targetRepo, ref, resource, client.supportLFS)
 	if resp != nil {
 		switch resp.StatusCode {
 		case http.StatusOK:
@@ -205,7 +234,11 @@ func (client *Client) ServeRawContent(targetOwner, targetRepo, ref, resource str
 }
 
 func (client *Client) GiteaGetRepoBranchTimestamp(repoOwner, repoName, branchName string) (*BranchTimestamp, error) {
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s", branchTimestampCacheKeyPrefix, repoOwner, repoName, branchName)
+	sdk, partition, err := client.repoClient(repoOwner, repoName)
+	if err != nil {
+		return &BranchTimestamp{}, err
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%s/%s|%s", branchTimestampCacheKeyPrefix, strings.ToLower(repoOwner), strings.ToLower(repoName), branchName, partition)
 
 	if stamp, ok := client.responseCache.Get(cacheKey); ok && stamp != nil {
 		branchTimeStamp := stamp.(*BranchTimestamp)
@@ -217,7 +250,7 @@ func (client *Client) GiteaGetRepoBranchTimestamp(repoOwner, repoName, branchNam
 		return branchTimeStamp, nil
 	}
 
-	branch, resp, err := client.sdkClient.GetRepoBranch(repoOwner, repoName, branchName)
+	branch, resp, err := sdk.GetRepoBranch(repoOwner, repoName, branchName)
 	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			log.Trace().Msgf("[cache] set cache branch %q not found", branchName)
@@ -245,13 +278,17 @@ func (client *Client) GiteaGetRepoBranchTimestamp(repoOwner, repoName, branchNam
 }
 
 func (client *Client) GiteaGetRepoDefaultBranch(repoOwner, repoName string) (string, error) {
-	cacheKey := fmt.Sprintf("%s/%s/%s", defaultBranchCacheKeyPrefix, repoOwner, repoName)
+	sdk, partition, err := client.repoClient(repoOwner, repoName)
+	if err != nil {
+		return "", err
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%s|%s", defaultBranchCacheKeyPrefix, strings.ToLower(repoOwner), strings.ToLower(repoName), partition)
 
 	if branch, ok := client.responseCache.Get(cacheKey); ok && branch != nil {
 		return branch.(string), nil
 	}
 
-	repo, resp, err := client.sdkClient.GetRepo(repoOwner, repoName)
+	repo, resp, err := sdk.GetRepo(repoOwner, repoName)
 	if err != nil {
 		return "", err
 	}
@@ -266,6 +303,81 @@ func (client *Client) GiteaGetRepoDefaultBranch(repoOwner, repoName string) (str
 	return branch, nil
 }
 
+// GiteaGetRepoPrivate returns whether a repository is private.
+func (client *Client) GiteaGetRepoPrivate(repoOwner, repoName string) (bool, error) {
+	sdk, partition, err := client.repoClient(repoOwner, repoName)
+	if err != nil {
+		return false, err
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%s|%s", repoPrivateCacheKeyPrefix, strings.ToLower(repoOwner), strings.ToLower(repoName), partition)
+
+	if private, ok := client.responseCache.Get(cacheKey); ok && private != nil {
+		return private.(bool), nil
+	}
+
+	repo, resp, err := sdk.GetRepo(repoOwner, repoName)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, ErrorNotFound
+		}
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code '%d'", resp.StatusCode)
+	}
+
+	if err := client.responseCache.Set(cacheKey, repo.Private, defaultBranchCacheTimeout); err != nil {
+		log.Error().Err(err).Msg("[cache] error on cache write")
+	}
+	return repo.Private, nil
+}
+
+// PurgeCache removes all cached responses of a repository owner, optionally limited to a repo and a branch.
+// The responses of all token partitions are removed. It returns the number of removed cache entries.
+// Owners and repos are lowercased in all cache keys, as they are case-insensitive in Gitea.
+func (client *Client) PurgeCache(owner, repo, branch string) int {
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+	var prefixes, keys []string
+	switch {
+	case repo == "":
+		prefixes = []string{
+			fmt.Sprintf("%s/%s/", deployTokenCacheKeyPrefix, owner),
+			fmt.Sprintf("%s/%s/", branchTimestampCacheKeyPrefix, owner),
+			fmt.Sprintf("%s/%s/", defaultBranchCacheKeyPrefix, owner),
+			fmt.Sprintf("%s/%s/", repoPrivateCacheKeyPrefix, owner),
+			fmt.Sprintf("%s/%s/", rawContentCacheKeyPrefix, owner),
+			fmt.Sprintf("%s/%s/", directoryListingCacheKeyPrefix, owner),
+		}
+	case branch == "":
+		prefixes = []string{
+			fmt.Sprintf("%s/%s/%s/", branchTimestampCacheKeyPrefix, owner, repo),
+			fmt.Sprintf("%s/%s/%s|", rawContentCacheKeyPrefix, owner, repo),
+			fmt.Sprintf("%s/%s/%s|", directoryListingCacheKeyPrefix, owner, repo),
+			fmt.Sprintf("%s/%s/%s|", defaultBranchCacheKeyPrefix, owner, repo),
+			fmt.Sprintf("%s/%s/%s|", repoPrivateCacheKeyPrefix, owner, repo),
+		}
+		keys = []string{deployTokenCacheKey(owner, repo)}
+	default:
+		prefixes = []string{
+			fmt.Sprintf("%s/%s/%s|%s|", rawContentCacheKeyPrefix, owner, repo, branch),
+			fmt.Sprintf("%s/%s/%s|%s|", directoryListingCacheKeyPrefix, owner, repo, branch),
+			fmt.Sprintf("%s/%s/%s/%s|", branchTimestampCacheKeyPrefix, owner, repo, branch),
+		}
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if _, ok := client.responseCache.Get(key); ok {
+			removed++
+		}
+		client.responseCache.Remove(key)
+	}
+	for _, prefix := range prefixes {
+		removed += cache.RemovePrefix(client.responseCache, prefix)
+	}
+	return removed
+}
+
 func (client *Client) getMimeTypeByExtension(resource string) string {
 	mimeType := mime.TypeByExtension(path.Ext(resource))
 	mimeTypeSplit := strings.SplitN(mimeType, ";", 2)
@@ -0,0 +1,38 @@
+package gitea
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+)
+
+func TestPurgeCacheIgnoresCase(t *testing.T) {
+	giteaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			_ = json.NewEncoder(w).Encode(map[string]string{"version": "1.21.0"})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"default_branch": "main", "private": true})
+		}
+	}))
+	defer giteaServer.Close()
+	client, err := NewClient(config.GiteaConfig{Root: giteaServer.URL}, cache.NewInMemoryCache(), nil)
+	assert.NoError(t, err)
+
+	// owners and repos are case-insensitive, so they share the cache entries of all spellings
+	_, err = client.GiteaGetRepoDefaultBranch("Example", "Pages")
+	assert.NoError(t, err)
+	_, err = client.GiteaGetRepoDefaultBranch("example", "pages")
+	assert.NoError(t, err)
+	_, err = client.GiteaGetRepoPrivate("EXAMPLE", "pages")
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 2, client.PurgeCache("example", "pages", ""))
+	assert.EqualValues(t, 0, client.PurgeCache("Example", "Pages", ""))
+}
@@ -0,0 +1,93 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	directoryListingCacheKeyPrefix = "directoryListing"
+
+	// maxDirectoryCommitLookups limits how many last commits are requested for a single directory listing,
+	// as the contents API doesn't return them and every entry needs its own request.
+	maxDirectoryCommitLookups = 50
+
+	contentTypeDir       = "dir"
+	contentTypeSubmodule = "submodule"
+)
+
+// DirectoryEntry is a file or directory in a directory listing.
+type DirectoryEntry struct {
+	Name  string
+	IsDir bool
+	Size  int64
+	// LastModified is the date of the last commit touching the entry, zero if unknown
+	LastModified time.Time
+}
+
+// GiteaListDirectory lists the entries of a directory with the Gitea contents API.
+// It returns ErrorNotFound if the directory doesn't exist or is a file.
+func (client *Client) GiteaListDirectory(targetOwner, targetRepo, ref, dir string) ([]DirectoryEntry, error) {
+	dir = strings.Trim(dir, "/")
+	sdk, partition, err := client.repoClient(targetOwner, targetRepo)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%s|%s|%s|%s", directoryListingCacheKeyPrefix, strings.ToLower(targetOwner), strings.ToLower(targetRepo), ref, dir, partition)
+	if cached, ok := client.responseCache.Get(cacheKey); ok {
+		log.Trace().Msgf("[cache] use directory listing of %q", dir)
+		return cached.([]DirectoryEntry), nil
+	}
+
+	contents, resp, err := sdk.ListContents(targetOwner, targetRepo, ref, dir)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, ErrorNotFound
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusOK {
+			// the contents API returns a single object for files
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	entries := make([]DirectoryEntry, 0, len(contents))
+	for _, content := range contents {
+		if content.Type == contentTypeSubmodule {
+			continue
+		}
+		entry := DirectoryEntry{
+			Name:  content.Name,
+			IsDir: content.Type == contentTypeDir,
+			Size:  content.Size,
+		}
+		if len(entries) < maxDirectoryCommitLookups {
+			entry.LastModified = lastCommitDate(sdk, targetOwner, targetRepo, ref, content.Path)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := client.responseCache.Set(cacheKey, entries, fileCacheTimeout); err != nil {
+		log.Error().Err(err).Msg("[cache] error on cache write")
+	}
+	return entries, nil
+}
+
+// lastCommitDate returns the date of the last commit on ref touching resource, or zero on errors.
+func lastCommitDate(sdk *gitea.Client, targetOwner, targetRepo, ref, resource string) time.Time {
+	commits, _, err := sdk.ListRepoCommits(targetOwner, targetRepo, gitea.ListCommitOptions{
+		ListOptions: gitea.ListOptions{PageSize: 1},
+		SHA:         ref,
+		Path:        resource,
+	})
+	if err != nil || len(commits) == 0 || commits[0].CommitMeta == nil {
+		log.Debug().Err(err).Msgf("could not get last commit of %q", resource)
+		return time.Time{}
+	}
+	return commits[0].Created
+}
@@ -0,0 +1,100 @@
+package gitea
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+const (
+	deployTokenCacheKeyPrefix = "deployToken"
+
+	// defaultPartition is the cache partition of responses fetched with the global token
+	defaultPartition = "default"
+)
+
+// DeployTokenStore holds the tokens registered for single repositories through the admin API.
+type DeployTokenStore interface {
+	GetDeployToken(owner, repo string) (*database.DeployToken, error)
+}
+
+// repoToken returns the api token a repository is read with: its deploy token, the token of its owner,
+// or the global token.
+func (client *Client) repoToken(owner, repo string) (string, error) {
+	if client.deployTokens != nil {
+		cacheKey := deployTokenCacheKey(owner, repo)
+		cached, ok := client.responseCache.Get(cacheKey)
+		if !ok {
+			token := ""
+			deployToken, err := client.deployTokens.GetDeployToken(owner, repo)
+			if err == nil {
+				token = string(deployToken.Token)
+			} else if !errors.Is(err, database.ErrNotFound) {
+				return "", fmt.Errorf("could not get deploy token of %s/%s: %w", owner, repo, err)
+			}
+			// repositories without deploy token are cached too, so the database is not asked on every request
+			if err := client.responseCache.Set(cacheKey, token, defaultBranchCacheTimeout); err != nil {
+				log.Error().Err(err).Msg("[cache] error on cache write")
+			}
+			cached = token
+		}
+		if token := cached.(string); token != "" {
+			return token, nil
+		}
+	}
+	if token, ok := client.ownerTokens[strings.ToLower(owner)]; ok {
+		return token, nil
+	}
+	return client.token, nil
+}
+
+// repoClient returns the sdk client that uses the token of a repository, and the cache partition
+// of the token. Responses are cached per partition, so content only readable with an elevated token
+// is never served for repositories read with another token.
+func (client *Client) repoClient(owner, repo string) (*gitea.Client, string, error) {
+	token, err := client.repoToken(owner, repo)
+	if err != nil {
+		return nil, "", err
+	}
+	partition := client.tokenPartition(token)
+
+	client.sdkClientsMutex.RLock()
+	sdk, ok := client.sdkClients[partition]
+	client.sdkClientsMutex.RUnlock()
+	if ok {
+		return sdk, partition, nil
+	}
+
+	// created outside of the lock, as the sdk asks the server for its version
+	sdk, err = client.newSDKClient(token)
+	if sdk == nil {
+		return nil, "", fmt.Errorf("could not create gitea client for %s/%s: %w", owner, repo, err)
+	}
+	client.sdkClientsMutex.Lock()
+	defer client.sdkClientsMutex.Unlock()
+	if existing, ok := client.sdkClients[partition]; ok {
+		return existing, partition, nil
+	}
+	client.sdkClients[partition] = sdk
+	return sdk, partition, nil
+}
+
+// tokenPartition identifies a token in cache keys without revealing it.
+func (client *Client) tokenPartition(token string) string {
+	if token == client.token {
+		return defaultPartition
+	}
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:8])
+}
+
+func deployTokenCacheKey(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s", deployTokenCacheKeyPrefix, strings.ToLower(owner), strings.ToLower(repo))
+}
@@ -0,0 +1,68 @@
+package gitea
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+func TestRepoToken(t *testing.T) {
+	certDB := database.NewMockCertDB(t)
+	certDB.On("GetDeployToken", "example", "pages").Return(&database.DeployToken{Owner: "example", Repo: "pages", Token: []byte("deploy")}, nil).Once()
+	certDB.On("GetDeployToken", mock.Anything, mock.Anything).Return(nil, database.ErrNotFound)
+
+	client := &Client{
+		responseCache: cache.NewInMemoryCache(),
+		token:         "global",
+		ownerTokens:   map[string]string{"example": "owner"},
+		deployTokens:  certDB,
+	}
+
+	for _, tc := range []struct{ owner, repo, token string }{
+		{"example", "pages", "deploy"},
+		// the deploy token is cached
+		{"example", "pages", "deploy"},
+		{"Example", "other", "owner"},
+		{"other", "pages", "global"},
+	} {
+		token, err := client.repoToken(tc.owner, tc.repo)
+		assert.NoError(t, err)
+		assert.EqualValues(t, tc.token, token, "%s/%s", tc.owner, tc.repo)
+	}
+
+	// changed deploy tokens are picked up once the cache of the repository is purged
+	client.PurgeCache("example", "pages", "")
+	token, err := client.repoToken("example", "pages")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "owner", token)
+}
+
+func TestTokenPartition(t *testing.T) {
+	client := &Client{token: "global"}
+	assert.EqualValues(t, defaultPartition, client.tokenPartition("global"))
+	assert.Len(t, client.tokenPartition("elevated"), 16)
+	assert.NotContains(t, client.tokenPartition("elevated"), "elevated")
+	assert.NotEqual(t, client.tokenPartition("elevated"), client.tokenPartition("other"))
+}
+
+func TestPurgeCacheRemovesAllPartitions(t *testing.T) {
+	responseCache := cache.NewInMemoryCache()
+	client := &Client{responseCache: responseCache}
+	for _, key := range []string{
+		"defaultBranch/example/pages|default",
+		"defaultBranch/example/pages|0123456789abcdef",
+		"branchTime/example/pages/main|0123456789abcdef",
+		"rawContent/example/pages|main|index.html|0123456789abcdef",
+		"defaultBranch/example/pages2|default",
+	} {
+		assert.NoError(t, responseCache.Set(key, "", fileCacheTimeout))
+	}
+
+	assert.EqualValues(t, 4, client.PurgeCache("example", "pages", ""))
+	_, ok := responseCache.Get("defaultBranch/example/pages2|default")
+	assert.True(t, ok)
+}
@@ -11,6 +11,10 @@ import (
 	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/context"
 	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/oauth"
+	"codeberg.org/codeberg/pages/server/proxyprotocol"
+	"codeberg.org/codeberg/pages/server/ratelimit"
+	"codeberg.org/codeberg/pages/server/takedown"
 )
 
 const (
@@ -19,31 +23,64 @@ const (
 	defaultPagesRepo                = "pages"
 )
 
+// dependencies are set up once in Handler and shared by all requests.
+type dependencies struct {
+	// allowDirectoryListing is the switch of the operator, sites have to enable directory listings as well
+	allowDirectoryListing bool
+	cacheControl          config.CacheControlConfig
+	oauthProvider         *oauth.Provider
+	rateLimiter           *ratelimit.Limiter
+	takedowns             *takedown.List
+
+	dnsLookupCache       cache.ICache
+	canonicalDomainCache cache.ICache
+	redirectsCache       cache.ICache
+	siteConfigCache      cache.ICache
+	quotaExceededCache   cache.ICache
+}
+
 // Handler handles a single HTTP request to the web server.
 func Handler(
 	cfg config.ServerConfig,
 	giteaClient *gitea.Client,
-	dnsLookupCache, canonicalDomainCache, redirectsCache cache.ICache,
+	oauthProvider *oauth.Provider,
+	rateLimiter *ratelimit.Limiter,
+	takedowns *takedown.List,
+	dnsLookupCache, canonicalDomainCache, redirectsCache, siteConfigCache, quotaExceededCache cache.ICache,
 ) http.HandlerFunc {
+	deps := &dependencies{
+		allowDirectoryListing: cfg.DirectoryListing,
+		cacheControl:          cfg.CacheControl,
+		oauthProvider:         oauthProvider,
+		rateLimiter:           rateLimiter,
+		takedowns:             takedowns,
+		dnsLookupCache:        dnsLookupCache,
+		canonicalDomainCache:  canonicalDomainCache,
+		redirectsCache:        redirectsCache,
+		siteConfigCache:       siteConfigCache,
+		quotaExceededCache:    quotaExceededCache,
+	}
+
 	return func(w http.ResponseWriter, req *http.Request) {
 		log.Debug().Msg("\n----------------------------------------------------------")
-		log := log.With().Strs("Handler", []string{req.Host, req.RequestURI}).Logger()
+		logContext := log.With().Strs("Handler", []string{req.Host, req.RequestURI}).Str("client", req.RemoteAddr)
+		if proxyAddr := proxyprotocol.ProxyAddr(req.Context()); proxyAddr != nil {
+			logContext = logContext.Str("proxy", proxyAddr.String())
+		}
+		log := logContext.Logger()
 		ctx := context.New(w, req)
 
 		ctx.RespWriter.Header().Set("Server", "pages-server")
 
-		// Force new default from specification (since November 2020) - see https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Referrer-Policy#strict-origin-when-cross-origin
-		ctx.RespWriter.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-
-		// Enable browser caching for up to 10 minutes
-		ctx.RespWriter.Header().Set("Cache-Control", "public, max-age=600")
+		// Error pages and redirects get a short policy, served files get theirs in upstream
+		if cfg.CacheControl.Errors != "" {
+			ctx.RespWriter.Header().Set("Cache-Control", cfg.CacheControl.Errors)
+		}
 
 		trimmedHost := ctx.TrimHostPort()
 
-		// Add HSTS for RawDomain and MainDomain
-		if hsts := getHSTSHeader(trimmedHost, cfg.MainDomain, cfg.RawDomain); hsts != "" {
-			ctx.RespWriter.Header().Set("Strict-Transport-Security", hsts)
-		}
+		// Add the security headers configured for the class of the domain, e.g. HSTS
+		setSecurityHeaders(ctx.RespWriter.Header(), getHeaderPolicy(trimmedHost, cfg))
 
 		// Handle all http methods
 		ctx.RespWriter.Header().Set("Allow", http.MethodGet+", "+http.MethodHead+", "+http.MethodOptions)
@@ -62,6 +99,21 @@ func Handler(
 			return
 		}
 
+		// Serve the takedown page of blocked domains, whatever they point to
+		if takedown.Blocked(ctx, takedowns.Domain(trimmedHost)) {
+			return
+		}
+
+		// Throttle clients sending too many requests, before anything is requested from Gitea
+		if !rateLimiter.AllowClient(ctx) {
+			return
+		}
+
+		// Serve the endpoints of the OAuth login
+		if oauthProvider.HandleRequest(ctx) {
+			return
+		}
+
 		// Block blacklisted paths (like ACME challenges)
 		for _, blacklistedPath := range cfg.BlacklistedPaths {
 			if strings.HasPrefix(ctx.Path(), blacklistedPath) {
@@ -92,7 +144,7 @@ func Handler(
 				cfg.MainDomain,
 				trimmedHost,
 				pathElements,
-				canonicalDomainCache, redirectsCache)
+				deps)
 		} else if strings.HasSuffix(trimmedHost, cfg.MainDomain) {
 			log.Debug().Msg("subdomain request detected")
 			handleSubDomain(log, ctx, giteaClient,
@@ -100,7 +152,7 @@ func Handler(
 				cfg.PagesBranches,
 				trimmedHost,
 				pathElements,
-				canonicalDomainCache, redirectsCache)
+				deps)
 		} else {
 			log.Debug().Msg("custom domain request detected")
 			handleCustomDomain(log, ctx, giteaClient,
@@ -108,7 +160,7 @@ func Handler(
 				trimmedHost,
 				pathElements,
 				cfg.PagesBranches[0],
-				dnsLookupCache, canonicalDomainCache, redirectsCache)
+				deps)
 		}
 	}
 }
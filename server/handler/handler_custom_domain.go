@@ -6,7 +6,7 @@ import (
 	"strings"
 
 	"codeberg.org/codeberg/pages/html"
-	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/certificates"
 	"codeberg.org/codeberg/pages/server/context"
 	"codeberg.org/codeberg/pages/server/dns"
 	"codeberg.org/codeberg/pages/server/gitea"
@@ -19,10 +19,15 @@ func handleCustomDomain(log zerolog.Logger, ctx *context.Context, giteaClient *g
 	trimmedHost string,
 	pathElements []string,
 	firstDefaultBranch string,
-	dnsLookupCache, canonicalDomainCache, redirectsCache cache.ICache,
+	deps *dependencies,
 ) {
 	// Serve pages from custom domains
-	targetOwner, targetRepo, targetBranch := dns.GetTargetFromDNS(trimmedHost, mainDomainSuffix, firstDefaultBranch, dnsLookupCache)
+	target, err := dns.GetTargetFromDNS(trimmedHost, mainDomainSuffix, firstDefaultBranch, deps.dnsLookupCache)
+	if err != nil {
+		html.ReturnErrorPage(ctx, err.Error(), http.StatusFailedDependency)
+		return
+	}
+	targetOwner, targetBranch := target.Owner, target.Branch
 	if targetOwner == "" {
 		html.ReturnErrorPage(ctx,
 			"could not obtain repo owner from custom domain",
@@ -30,6 +35,12 @@ func handleCustomDomain(log zerolog.Logger, ctx *context.Context, giteaClient *g
 		return
 	}
 
+	// the visitor accepted the self-signed certificate served instead of a real one
+	if msg, blocked := certificates.QuotaExceededMessage(deps.quotaExceededCache, targetOwner, trimmedHost); blocked {
+		html.ReturnErrorPage(ctx, msg, http.StatusTooManyRequests)
+		return
+	}
+
 	pathParts := pathElements
 	canonicalLink := false
 	if strings.HasPrefix(pathElements[0], "@") {
@@ -43,18 +54,19 @@ func handleCustomDomain(log zerolog.Logger, ctx *context.Context, giteaClient *g
 	if targetOpt, works := tryBranch(log, ctx, giteaClient, &upstream.Options{
 		TryIndexPages: true,
 		TargetOwner:   targetOwner,
-		TargetRepo:    targetRepo,
+		TargetRepo:    target.Repo,
 		TargetBranch:  targetBranch,
 		TargetPath:    path.Join(pathParts...),
+		PublishDir:    target.Dir,
 	}, canonicalLink); works {
-		canonicalDomain, valid := targetOpt.CheckCanonicalDomain(giteaClient, trimmedHost, mainDomainSuffix, canonicalDomainCache)
+		canonicalDomain, valid := targetOpt.CheckCanonicalDomain(giteaClient, trimmedHost, mainDomainSuffix, deps.canonicalDomainCache)
 		if !valid {
 			html.ReturnErrorPage(ctx, "domain not specified in <code>.domains</code> file", http.StatusMisdirectedRequest)
 			return
 		} else if canonicalDomain != trimmedHost {
 			// only redirect if the target is also a codeberg page!
-			targetOwner, _, _ = dns.GetTargetFromDNS(strings.SplitN(canonicalDomain, "/", 2)[0], mainDomainSuffix, firstDefaultBranch, dnsLookupCache)
-			if targetOwner != "" {
+			canonicalTarget, _ := dns.GetTargetFromDNS(strings.SplitN(canonicalDomain, "/", 2)[0], mainDomainSuffix, firstDefaultBranch, deps.dnsLookupCache)
+			if canonicalTarget.Owner != "" {
 				ctx.Redirect("https://"+canonicalDomain+"/"+targetOpt.TargetPath, http.StatusTemporaryRedirect)
 				return
 			}
@@ -64,7 +76,7 @@ func handleCustomDomain(log zerolog.Logger, ctx *context.Context, giteaClient *g
 		}
 
 		log.Debug().Msg("tryBranch, now trying upstream 7")
-		tryUpstream(ctx, giteaClient, mainDomainSuffix, trimmedHost, targetOpt, canonicalDomainCache, redirectsCache)
+		tryUpstream(ctx, giteaClient, mainDomainSuffix, trimmedHost, targetOpt, deps)
 		return
 	}
 
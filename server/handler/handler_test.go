@@ -19,7 +19,7 @@ func TestHandlerPerformance(t *testing.T) {
 		LFSEnabled:     false,
 		FollowSymlinks: false,
 	}
-	giteaClient, _ := gitea.NewClient(cfg, cache.NewInMemoryCache())
+	giteaClient, _ := gitea.NewClient(cfg, cache.NewInMemoryCache(), nil)
 	serverCfg := config.ServerConfig{
 		MainDomain: "codeberg.page",
 		RawDomain:  "raw.codeberg.page",
@@ -29,7 +29,7 @@ func TestHandlerPerformance(t *testing.T) {
 		AllowedCorsDomains: []string{"raw.codeberg.org", "fonts.codeberg.org", "design.codeberg.org"},
 		PagesBranches:      []string{"pages"},
 	}
-	testHandler := Handler(serverCfg, giteaClient, cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	testHandler := Handler(serverCfg, giteaClient, nil, nil, nil, cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache(), cache.NewInMemoryCache())
 
 	testCase := func(uri string, status int) {
 		t.Run(uri, func(t *testing.T) {
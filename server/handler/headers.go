@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"codeberg.org/codeberg/pages/config"
+)
+
+// getHeaderPolicy returns the security headers of the main domain for its subdomains, the ones of the raw domain for
+// the raw domain and the ones of custom domains for everything else.
+func getHeaderPolicy(host string, cfg config.ServerConfig) config.HeaderPolicy {
+	switch {
+	case cfg.RawDomain != "" && strings.EqualFold(host, cfg.RawDomain):
+		return cfg.Headers.Raw
+	case strings.HasSuffix(host, cfg.MainDomain):
+		return cfg.Headers.Main
+	default:
+		return cfg.Headers.Custom
+	}
+}
+
+// setSecurityHeaders sets all headers of the policy with a value.
+func setSecurityHeaders(header http.Header, policy config.HeaderPolicy) {
+	for name, value := range map[string]string{
+		"Strict-Transport-Security":    policy.HSTS,
+		"X-Content-Type-Options":       policy.ContentTypeOptions,
+		"Referrer-Policy":              policy.ReferrerPolicy,
+		"Content-Security-Policy":      policy.ContentSecurityPolicy,
+		"Cross-Origin-Opener-Policy":   policy.CrossOriginOpenerPolicy,
+		"Cross-Origin-Embedder-Policy": policy.CrossOriginEmbedderPolicy,
+		"Cross-Origin-Resource-Policy": policy.CrossOriginResourcePolicy,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := config.NewDefaultConfig().Server
+	cfg.MainDomain = ".codeberg.page"
+	cfg.RawDomain = "raw.codeberg.page"
+	cfg.Headers.Custom.HSTS = "max-age=31536000"
+	cfg.Headers.Custom.CrossOriginOpenerPolicy = "same-origin"
+
+	headers := func(host string) http.Header {
+		header := http.Header{}
+		setSecurityHeaders(header, getHeaderPolicy(host, cfg))
+		return header
+	}
+
+	main := headers("example.codeberg.page")
+	assert.EqualValues(t, config.DefaultHSTS, main.Get("Strict-Transport-Security"))
+	assert.EqualValues(t, "nosniff", main.Get("X-Content-Type-Options"))
+	assert.EqualValues(t, "strict-origin-when-cross-origin", main.Get("Referrer-Policy"))
+	assert.Empty(t, main.Values("Content-Security-Policy"))
+
+	raw := headers("RAW.codeberg.page")
+	assert.EqualValues(t, config.DefaultHSTS, raw.Get("Strict-Transport-Security"))
+	assert.EqualValues(t, "sandbox", raw.Get("Content-Security-Policy"))
+
+	custom := headers("example.com")
+	assert.EqualValues(t, "max-age=31536000", custom.Get("Strict-Transport-Security"))
+	assert.EqualValues(t, "same-origin", custom.Get("Cross-Origin-Opener-Policy"))
+	assert.EqualValues(t, "nosniff", custom.Get("X-Content-Type-Options"))
+	assert.Empty(t, custom.Values("Cross-Origin-Embedder-Policy"))
+
+	// custom domains get no HSTS unless the operator opts in
+	cfg.Headers.Custom.HSTS = ""
+	assert.Empty(t, headers("example.com").Values("Strict-Transport-Security"))
+}
@@ -7,9 +7,9 @@ import (
 	"github.com/rs/zerolog"
 
 	"codeberg.org/codeberg/pages/html"
-	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/context"
 	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/takedown"
 	"codeberg.org/codeberg/pages/server/upstream"
 )
 
@@ -17,12 +17,26 @@ import (
 func tryUpstream(ctx *context.Context, giteaClient *gitea.Client,
 	mainDomainSuffix, trimmedHost string,
 	options *upstream.Options,
-	canonicalDomainCache cache.ICache,
-	redirectsCache cache.ICache,
+	deps *dependencies,
 ) {
+	if takedown.Blocked(ctx, deps.takedowns.Site(options.TargetOwner, options.TargetRepo, options.TargetBranch)) {
+		return
+	}
+
+	// a single site must not use up the Gitea API for all others
+	if !deps.rateLimiter.AllowSite(ctx, options.TargetOwner, options.TargetRepo) {
+		return
+	}
+
+	// private repositories are only served to users who can read them,
+	// this is checked before the redirect so the canonical domain isn't revealed to others
+	if !deps.oauthProvider.Authorize(ctx, giteaClient, options.TargetOwner, options.TargetRepo) {
+		return
+	}
+
 	// check if a canonical domain exists on a request on MainDomain
 	if strings.HasSuffix(trimmedHost, mainDomainSuffix) && !options.ServeRaw {
-		canonicalDomain, _ := options.CheckCanonicalDomain(giteaClient, "", mainDomainSuffix, canonicalDomainCache)
+		canonicalDomain, _ := options.CheckCanonicalDomain(giteaClient, "", mainDomainSuffix, deps.canonicalDomainCache)
 		if !strings.HasSuffix(strings.SplitN(canonicalDomain, "/", 2)[0], mainDomainSuffix) {
 			canonicalPath := ctx.Req.RequestURI
 			if options.TargetRepo != defaultPagesRepo {
@@ -38,9 +52,22 @@ func tryUpstream(ctx *context.Context, giteaClient *gitea.Client,
 
 	// Add host for debugging.
 	options.Host = trimmedHost
+	options.CacheControl = deps.cacheControl
+
+	siteConfig := options.GetSiteConfig(giteaClient, deps.siteConfigCache)
+	// raw content is always served from the root of the repository
+	if !options.ServeRaw {
+		options.ApplySiteConfig(siteConfig)
+		// the operator and the site have to allow directory listings
+		options.AllowDirectoryListing = deps.allowDirectoryListing
+	}
 
+	// protected sites are protected on the raw domain as well
+	if !options.Authorize(ctx, siteConfig) {
+		return
+	}
 	// Try to request the file from the Gitea API
-	if !options.Upstream(ctx, giteaClient, redirectsCache) {
+	if !options.Upstream(ctx, giteaClient, deps.redirectsCache) {
 		html.ReturnErrorPage(ctx, "forge client failed", ctx.StatusCode)
 	}
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/gitea"
+	"codeberg.org/codeberg/pages/server/oauth"
+	"codeberg.org/codeberg/pages/server/upstream"
+)
+
+func TestTryUpstreamHidesCanonicalDomainOfPrivateRepos(t *testing.T) {
+	giteaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			_ = json.NewEncoder(w).Encode(map[string]string{"version": "1.21.0"})
+		case "/api/v1/repos/owner/repo":
+			_ = json.NewEncoder(w).Encode(map[string]any{"private": true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer giteaServer.Close()
+	giteaClient, err := gitea.NewClient(config.GiteaConfig{Root: giteaServer.URL}, cache.NewInMemoryCache(), nil)
+	assert.NoError(t, err)
+	oauthProvider, err := oauth.NewProvider(config.OAuthConfig{
+		Enabled:        true,
+		ClientID:       "client",
+		ClientSecret:   "secret",
+		SessionSecret:  "session-secret",
+		SessionTimeout: "1h",
+	}, giteaServer.URL, "raw.example.page")
+	assert.NoError(t, err)
+
+	canonicalDomainCache := cache.NewInMemoryCache()
+	assert.NoError(t, canonicalDomainCache.Set("owner/repo/pages", []string{"secret.example.org"}, time.Minute))
+	deps := &dependencies{
+		oauthProvider:        oauthProvider,
+		canonicalDomainCache: canonicalDomainCache,
+		redirectsCache:       cache.NewInMemoryCache(),
+		siteConfigCache:      cache.NewInMemoryCache(),
+	}
+
+	resp := httptest.NewRecorder()
+	ctx := context.New(resp, httptest.NewRequest(http.MethodGet, "https://owner.example.page/repo/", nil))
+	options := &upstream.Options{TargetOwner: "owner", TargetRepo: "repo", TargetBranch: "pages"}
+	tryUpstream(ctx, giteaClient, ".example.page", "owner.example.page", options, deps)
+
+	// visitors without a session are sent to the login instead of the custom domain
+	location := resp.Header().Get("Location")
+	assert.NotContains(t, location, "secret.example.org")
+	assert.True(t, strings.HasPrefix(location, "https://raw.example.page/"), location)
+}
@@ -0,0 +1,107 @@
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// exchangeCode returns the access token of the user who logged in on Gitea.
+func (p *Provider) exchangeCode(code string) (string, error) {
+	resp, err := p.httpClient.PostForm(p.giteaRoot+"/login/oauth/access_token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {p.callbackURL()},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code '%d'", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in response")
+	}
+	return token.AccessToken, nil
+}
+
+// canRead returns the name of the user the access token belongs to and whether the user can read the repository.
+func (p *Provider) canRead(accessToken, owner, repo string) (string, bool, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if status, err := p.getJSON(accessToken, "/api/v1/user", &user); err != nil {
+		return "", false, err
+	} else if status != http.StatusOK {
+		return "", false, fmt.Errorf("unexpected status code '%d'", status)
+	}
+
+	var repository struct {
+		Permissions struct {
+			Pull bool `json:"pull"`
+		} `json:"permissions"`
+	}
+	status, err := p.getJSON(accessToken, "/api/v1/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(repo), &repository)
+	switch {
+	case err != nil:
+		return user.Login, false, err
+	case status == http.StatusNotFound || status == http.StatusForbidden:
+		// Gitea hides private repositories from users who can't read them
+		return user.Login, false, nil
+	case status != http.StatusOK:
+		return user.Login, false, fmt.Errorf("unexpected status code '%d'", status)
+	}
+	return user.Login, repository.Permissions.Pull, nil
+}
+
+func (p *Provider) getJSON(accessToken, apiPath string, v any) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, p.giteaRoot+apiPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *Provider) authorizeURL(state string) string {
+	return p.giteaRoot + "/login/oauth/authorize?" + url.Values{
+		"client_id":     {p.clientID},
+		"redirect_uri":  {p.callbackURL()},
+		"response_type": {"code"},
+		"state":         {state},
+	}.Encode()
+}
+
+func (p *Provider) callbackURL() string {
+	return "https://" + p.domain + callbackPath
+}
+
+// localPath makes sure that a path from a ticket only redirects within the site.
+func localPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
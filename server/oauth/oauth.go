@@ -0,0 +1,268 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/html"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/gitea"
+)
+
+const (
+	// loginPath is served on the OAuth domain, it ties the login to the browser before redirecting to Gitea
+	loginPath = "/.pages-oauth/login"
+	// callbackPath is served on the OAuth domain, Gitea redirects there after the login
+	callbackPath = "/.pages-oauth/callback"
+	// sessionPath is served on every pages domain, it turns a ticket into a session cookie of the domain
+	sessionPath = "/.pages-oauth/session"
+
+	sessionCookiePrefix = "pages_session_"
+	loginCookiePrefix   = "pages_login_"
+
+	stateTimeout  = 10 * time.Minute
+	ticketTimeout = time.Minute
+)
+
+// Provider logs users in with OAuth2 on Gitea, so that private repositories are only served to users who can read them.
+type Provider struct {
+	giteaRoot    string
+	clientID     string
+	clientSecret string
+	domain       string
+	secret       []byte
+	// sessionTimeout limits how long a permission check is trusted
+	sessionTimeout time.Duration
+	httpClient     *http.Client
+	now            func() time.Time
+}
+
+// NewProvider returns nil if OAuth is disabled, the callback is served on the raw domain if no domain is configured.
+func NewProvider(cfg config.OAuthConfig, giteaRoot, rawDomain string) (*Provider, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.New("OAuth enabled, but no client id or client secret set (OAUTH_CLIENT_ID, OAUTH_CLIENT_SECRET)")
+	}
+	if cfg.SessionSecret == "" {
+		return nil, errors.New("OAuth enabled, but no session secret set (OAUTH_SESSION_SECRET)")
+	}
+	domain := cfg.Domain
+	if domain == "" {
+		domain = rawDomain
+	}
+	if domain == "" {
+		return nil, errors.New("OAuth enabled, but neither an OAuth domain nor a raw domain set (OAUTH_DOMAIN)")
+	}
+	sessionTimeout, err := time.ParseDuration(cfg.SessionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OAuth session timeout (OAUTH_SESSION_TIMEOUT): %w", err)
+	}
+
+	return &Provider{
+		giteaRoot:      strings.TrimSuffix(giteaRoot, "/"),
+		clientID:       cfg.ClientID,
+		clientSecret:   cfg.ClientSecret,
+		domain:         strings.ToLower(domain),
+		secret:         []byte(cfg.SessionSecret),
+		sessionTimeout: sessionTimeout,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		now:            time.Now,
+	}, nil
+}
+
+// HandleRequest serves the login, callback and session endpoints of the login, it returns false for all other requests.
+func (p *Provider) HandleRequest(ctx *context.Context) bool {
+	if p == nil {
+		return false
+	}
+	switch ctx.Req.URL.Path {
+	case loginPath:
+		if !strings.EqualFold(ctx.TrimHostPort(), p.domain) {
+			return false
+		}
+		p.handleLogin(ctx)
+		return true
+	case callbackPath:
+		if !strings.EqualFold(ctx.TrimHostPort(), p.domain) {
+			return false
+		}
+		p.handleCallback(ctx)
+		return true
+	case sessionPath:
+		p.handleSession(ctx)
+		return true
+	}
+	return false
+}
+
+// Authorize returns whether the repository may be served. Private repositories are only served to users
+// who logged in and can read them on Gitea, others are sent to the login.
+func (p *Provider) Authorize(ctx *context.Context, giteaClient *gitea.Client, owner, repo string) bool {
+	if p == nil {
+		return true
+	}
+
+	private, err := giteaClient.GiteaGetRepoPrivate(owner, repo)
+	if errors.Is(err, gitea.ErrorNotFound) {
+		// nothing will be served anyway
+		return true
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not check whether %s/%s is private", owner, repo)
+		html.ReturnErrorPage(ctx, "could not check access to the repository", http.StatusFailedDependency)
+		return false
+	}
+	if !private {
+		return true
+	}
+
+	ctx.RespWriter.Header().Set("Cache-Control", "private, no-cache")
+	ctx.RespWriter.Header().Add("Vary", "Cookie")
+
+	host := strings.ToLower(ctx.TrimHostPort())
+	if cookie, err := ctx.Req.Cookie(sessionCookieName(owner, repo)); err == nil {
+		session, err := p.verify(kindSession, cookie.Value)
+		if err == nil && session.Host == host && strings.EqualFold(session.Owner, owner) && strings.EqualFold(session.Repo, repo) {
+			return true
+		}
+	}
+
+	if ctx.Req.Method != http.MethodGet && ctx.Req.Method != http.MethodHead {
+		html.ReturnErrorPage(ctx, "this site is private, please log in", http.StatusUnauthorized)
+		return false
+	}
+	login := p.sign(claims{Kind: kindLogin, Host: host, Owner: owner, Repo: repo, Path: ctx.Req.URL.RequestURI()}, stateTimeout)
+	ctx.Redirect("https://"+p.domain+loginPath+"?"+url.Values{"login": {login}}.Encode(), http.StatusFound)
+	return false
+}
+
+// handleLogin sets a cookie with a random nonce on the OAuth domain and sends the user to Gitea with the nonce in the state.
+// The callback only accepts states whose nonce matches the cookie, so a callback URL can't log in another browser.
+func (p *Provider) handleLogin(ctx *context.Context) {
+	login, err := p.verify(kindLogin, ctx.Req.URL.Query().Get("login"))
+	if err != nil {
+		html.ReturnErrorPage(ctx, "the login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		log.Error().Err(err).Msg("could not generate OAuth nonce")
+		html.ReturnErrorPage(ctx, "the login failed, please try again", http.StatusInternalServerError)
+		return
+	}
+	state := login
+	state.Kind = kindState
+	state.Nonce = hex.EncodeToString(nonce)
+
+	http.SetCookie(ctx.RespWriter, &http.Cookie{
+		Name:     loginCookieName(state.Nonce),
+		Value:    state.Nonce,
+		Path:     callbackPath,
+		MaxAge:   int(stateTimeout.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	ctx.RespWriter.Header().Set("Cache-Control", "no-store")
+	ctx.Redirect(p.authorizeURL(p.sign(state, stateTimeout)), http.StatusFound)
+}
+
+// handleCallback checks the permission of the user who logged in, and hands the login over to the pages domain.
+func (p *Provider) handleCallback(ctx *context.Context) {
+	query := ctx.Req.URL.Query()
+	state, err := p.verify(kindState, query.Get("state"))
+	if err != nil || state.Nonce == "" {
+		html.ReturnErrorPage(ctx, "the login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	cookie, err := ctx.Req.Cookie(loginCookieName(state.Nonce))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		html.ReturnErrorPage(ctx, "the login was started in another browser, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(ctx.RespWriter, &http.Cookie{
+		Name:     cookie.Name,
+		Path:     callbackPath,
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	ctx.RespWriter.Header().Set("Cache-Control", "no-store")
+	if query.Get("code") == "" {
+		html.ReturnErrorPage(ctx, "the login was cancelled", http.StatusForbidden)
+		return
+	}
+
+	accessToken, err := p.exchangeCode(query.Get("code"))
+	if err != nil {
+		log.Error().Err(err).Msg("could not exchange OAuth code")
+		html.ReturnErrorPage(ctx, "the login failed, please try again", http.StatusBadGateway)
+		return
+	}
+	user, ok, err := p.canRead(accessToken, state.Owner, state.Repo)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not check permission of %q on %s/%s", user, state.Owner, state.Repo)
+		html.ReturnErrorPage(ctx, "could not check access to the repository", http.StatusBadGateway)
+		return
+	}
+	if !ok {
+		html.ReturnErrorPage(ctx,
+			fmt.Sprintf("<code>%s</code> can't read <code>%s/%s</code>", user, state.Owner, state.Repo),
+			http.StatusForbidden)
+		return
+	}
+
+	ticket := p.sign(claims{Kind: kindTicket, Host: state.Host, Owner: state.Owner, Repo: state.Repo, Path: state.Path, User: user}, ticketTimeout)
+	ctx.Redirect("https://"+state.Host+sessionPath+"?"+url.Values{"ticket": {ticket}}.Encode(), http.StatusFound)
+}
+
+// handleSession sets the session cookie for the domain the ticket was issued for.
+func (p *Provider) handleSession(ctx *context.Context) {
+	ticket, err := p.verify(kindTicket, ctx.Req.URL.Query().Get("ticket"))
+	if err != nil || ticket.Host != strings.ToLower(ctx.TrimHostPort()) {
+		html.ReturnErrorPage(ctx, "the login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	session := claims{Kind: kindSession, Host: ticket.Host, Owner: ticket.Owner, Repo: ticket.Repo, User: ticket.User}
+	http.SetCookie(ctx.RespWriter, &http.Cookie{
+		Name:     sessionCookieName(ticket.Owner, ticket.Repo),
+		Value:    p.sign(session, p.sessionTimeout),
+		Path:     "/",
+		MaxAge:   int(p.sessionTimeout.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	ctx.RespWriter.Header().Set("Cache-Control", "no-store")
+	ctx.Redirect(localPath(ticket.Path), http.StatusFound)
+}
+
+// loginCookieName differs for every login, so that logins in several tabs don't interfere.
+func loginCookieName(nonce string) string {
+	if len(nonce) > 16 {
+		nonce = nonce[:16]
+	}
+	return loginCookiePrefix + nonce
+}
+
+// sessionCookieName differs for every repository, as a domain can serve several repositories.
+func sessionCookieName(owner, repo string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(owner + "/" + repo)))
+	return sessionCookiePrefix + hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,212 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+func newTestProvider(t *testing.T, giteaRoot string) *Provider {
+	p, err := NewProvider(config.OAuthConfig{
+		Enabled:        true,
+		ClientID:       "client",
+		ClientSecret:   "secret",
+		SessionSecret:  "session-secret",
+		SessionTimeout: "1h",
+	}, giteaRoot, "raw.example.page")
+	assert.NoError(t, err)
+	return p
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(config.OAuthConfig{}, "https://gitea.example.org", "")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	// a disabled provider authorizes everything
+	assert.True(t, p.Authorize(nil, nil, "owner", "repo"))
+
+	_, err = NewProvider(config.OAuthConfig{Enabled: true, ClientID: "client", ClientSecret: "secret", SessionTimeout: "1h"}, "https://gitea.example.org", "raw.example.page")
+	assert.Error(t, err)
+	_, err = NewProvider(config.OAuthConfig{Enabled: true, ClientID: "client", ClientSecret: "secret", SessionSecret: "secret", SessionTimeout: "1h"}, "https://gitea.example.org", "")
+	assert.Error(t, err)
+
+	p = newTestProvider(t, "https://gitea.example.org/")
+	assert.EqualValues(t, "https://raw.example.page/.pages-oauth/callback", p.callbackURL())
+	assert.EqualValues(t, "https://gitea.example.org", p.giteaRoot)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	p := newTestProvider(t, "https://gitea.example.org")
+	token := p.sign(claims{Kind: kindSession, Host: "owner.example.page", Owner: "owner", Repo: "repo", User: "alice"}, time.Hour)
+
+	c, err := p.verify(kindSession, token)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "alice", c.User)
+
+	// tokens of other kinds are rejected
+	_, err = p.verify(kindTicket, token)
+	assert.ErrorIs(t, err, errInvalidToken)
+
+	// tampered tokens are rejected
+	_, err = p.verify(kindSession, "x"+token)
+	assert.ErrorIs(t, err, errInvalidToken)
+
+	// tokens of other secrets are rejected
+	other := newTestProvider(t, "https://gitea.example.org")
+	other.secret = []byte("other")
+	_, err = other.verify(kindSession, token)
+	assert.ErrorIs(t, err, errInvalidToken)
+
+	// expired tokens are rejected
+	p.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = p.verify(kindSession, token)
+	assert.ErrorIs(t, err, errInvalidToken)
+}
+
+func TestLocalPath(t *testing.T) {
+	assert.EqualValues(t, "/docs/?page=2", localPath("/docs/?page=2"))
+	assert.EqualValues(t, "/", localPath("//evil.example.org/"))
+	assert.EqualValues(t, "/", localPath("/\\evil.example.org/"))
+	assert.EqualValues(t, "/", localPath("https://evil.example.org/"))
+}
+
+// startLogin serves the login endpoint and returns the state sent to Gitea together with the login cookie
+func startLogin(t *testing.T, p *Provider, path string) (string, *http.Cookie) {
+	login := p.sign(claims{Kind: kindLogin, Host: "owner.example.page", Owner: "owner", Repo: "repo", Path: path}, stateTimeout)
+	req := httptest.NewRequest(http.MethodGet, "https://raw.example.page/.pages-oauth/login?"+url.Values{"login": {login}}.Encode(), nil)
+	resp := httptest.NewRecorder()
+	assert.True(t, p.HandleRequest(context.New(resp, req)))
+	assert.EqualValues(t, http.StatusFound, resp.Code)
+
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "/login/oauth/authorize", location.Path)
+	assert.EqualValues(t, "https://raw.example.page/.pages-oauth/callback", location.Query().Get("redirect_uri"))
+	cookies := resp.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+	assert.EqualValues(t, callbackPath, cookies[0].Path)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+	return location.Query().Get("state"), cookies[0]
+}
+
+func TestLogin(t *testing.T) {
+	giteaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			assert.EqualValues(t, "code", r.FormValue("code"))
+			assert.EqualValues(t, "secret", r.FormValue("client_secret"))
+			_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "user-token"})
+		case "/api/v1/user":
+			assert.EqualValues(t, "Bearer user-token", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]string{"login": "alice"})
+		case "/api/v1/repos/owner/repo":
+			_ = json.NewEncoder(w).Encode(map[string]any{"permissions": map[string]bool{"pull": true}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer giteaServer.Close()
+	p := newTestProvider(t, giteaServer.URL)
+
+	serve := func(rawURL string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, rawURL, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp := httptest.NewRecorder()
+		assert.True(t, p.HandleRequest(context.New(resp, req)))
+		return resp
+	}
+
+	// the login is only accepted on the OAuth domain
+	req := httptest.NewRequest(http.MethodGet, "https://owner.example.page/.pages-oauth/login", nil)
+	assert.False(t, p.HandleRequest(context.New(httptest.NewRecorder(), req)))
+
+	state, loginCookie := startLogin(t, p, "/repo/docs/")
+	callbackURL := "https://raw.example.page/.pages-oauth/callback?" + url.Values{"code": {"code"}, "state": {state}}.Encode()
+
+	// a callback URL can't be replayed to a browser that didn't start the login
+	resp := serve(callbackURL)
+	assert.EqualValues(t, http.StatusBadRequest, resp.Code)
+	_, otherCookie := startLogin(t, p, "/repo/docs/")
+	otherCookie.Name = loginCookie.Name
+	resp = serve(callbackURL, otherCookie)
+	assert.EqualValues(t, http.StatusBadRequest, resp.Code)
+	// a state without nonce is never accepted
+	resp = serve("https://raw.example.page/.pages-oauth/callback?"+url.Values{"code": {"code"}, "state": {
+		p.sign(claims{Kind: kindState, Host: "owner.example.page", Owner: "owner", Repo: "repo"}, stateTimeout),
+	}}.Encode(), &http.Cookie{Name: loginCookiePrefix, Value: ""})
+	assert.EqualValues(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(callbackURL, loginCookie)
+	assert.EqualValues(t, http.StatusFound, resp.Code)
+	// the login cookie is removed
+	if cookies := resp.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.EqualValues(t, loginCookie.Name, cookies[0].Name)
+		assert.Less(t, cookies[0].MaxAge, 0)
+	}
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "owner.example.page", location.Host)
+	assert.EqualValues(t, sessionPath, location.Path)
+
+	// the ticket only works on the domain it was issued for
+	resp = serve("https://other.example.page" + location.RequestURI())
+	assert.EqualValues(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(location.String())
+	assert.EqualValues(t, http.StatusFound, resp.Code)
+	assert.EqualValues(t, "/repo/docs/", resp.Header().Get("Location"))
+	cookies := resp.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.EqualValues(t, sessionCookieName("owner", "repo"), cookies[0].Name)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+
+	session, err := p.verify(kindSession, cookies[0].Value)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "alice", session.User)
+	assert.EqualValues(t, "owner.example.page", session.Host)
+
+	// a state can't be used as ticket
+	resp = serve("https://owner.example.page/.pages-oauth/session?" + url.Values{"ticket": {state}}.Encode())
+	assert.EqualValues(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestLoginWithoutPermission(t *testing.T) {
+	giteaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "user-token"})
+		case "/api/v1/user":
+			_ = json.NewEncoder(w).Encode(map[string]string{"login": "mallory"})
+		default:
+			// private repositories are hidden from users who can't read them
+			http.NotFound(w, r)
+		}
+	}))
+	defer giteaServer.Close()
+	p := newTestProvider(t, giteaServer.URL)
+
+	state, loginCookie := startLogin(t, p, "/")
+	req := httptest.NewRequest(http.MethodGet, "https://raw.example.page/.pages-oauth/callback?"+url.Values{"code": {"code"}, "state": {state}}.Encode(), nil)
+	req.AddCookie(loginCookie)
+	resp := httptest.NewRecorder()
+	assert.True(t, p.HandleRequest(context.New(resp, req)))
+	assert.EqualValues(t, http.StatusForbidden, resp.Code)
+	// only the login cookie is removed, no session is set
+	if cookies := resp.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.EqualValues(t, loginCookie.Name, cookies[0].Name)
+	}
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Kinds of signed tokens, a token of one kind is never accepted as another one
+const (
+	kindLogin   = "login"
+	kindState   = "state"
+	kindTicket  = "ticket"
+	kindSession = "session"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// claims are signed into the login redirect, the OAuth2 state, the ticket handing a login over to a pages domain, and session cookies.
+type claims struct {
+	Kind    string `json:"k"`
+	Expires int64  `json:"e"`
+	Host    string `json:"h"`
+	Owner   string `json:"o"`
+	Repo    string `json:"r"`
+	// Path is the page to return to after the login
+	Path string `json:"p,omitempty"`
+	User string `json:"u,omitempty"`
+	// Nonce of the state, it has to match the login cookie of the browser
+	Nonce string `json:"n,omitempty"`
+}
+
+// sign returns the claims with their signature, they are valid for ttl.
+func (p *Provider) sign(c claims, ttl time.Duration) string {
+	c.Expires = p.now().Add(ttl).Unix()
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.mac(encoded))
+}
+
+// verify returns the claims of token if its signature is valid, it's of the given kind and not expired.
+func (p *Provider) verify(kind, token string) (claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims{}, errInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, p.mac(encoded)) {
+		return claims{}, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims{}, errInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return claims{}, errInvalidToken
+	}
+	if c.Kind != kind || p.now().Unix() > c.Expires {
+		return claims{}, errInvalidToken
+	}
+	return c, nil
+}
+
+func (p *Provider) mac(encoded string) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,27 @@
+package proxyprotocol
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+type proxyAddrKey struct{}
+
+// ConnContext stores the address of the proxy of connections that came through the Listener in ctx,
+// it is meant to be the ConnContext of an http.Server.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if proxyConn, ok := conn.(*Conn); ok {
+		return context.WithValue(ctx, proxyAddrKey{}, proxyConn.ProxyAddr())
+	}
+	return ctx
+}
+
+// ProxyAddr returns the address of the proxy a request came through, nil if the connection wasn't from a trusted proxy.
+func ProxyAddr(ctx context.Context) net.Addr {
+	addr, _ := ctx.Value(proxyAddrKey{}).(net.Addr)
+	return addr
+}
@@ -0,0 +1,125 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// v1MaxLength is the longest possible v1 header, including the final CRLF
+	v1MaxLength = 107
+
+	v2HeaderLength  = 16
+	v2CommandLocal  = 0x0
+	v2CommandProxy  = 0x1
+	v2FamilyInet    = 0x1
+	v2FamilyInet6   = 0x2
+	v2ProtocolTCP   = 0x1
+	v2AddrLenInet   = 12
+	v2AddrLenInet6  = 36
+	v2MaxLength     = 4096
+	v2VersionNibble = 0x2
+)
+
+var (
+	v1Signature = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errInvalidHeader = errors.New("invalid PROXY protocol header")
+)
+
+// readHeader reads a PROXY protocol header of version 1 or 2 if the connection starts with one.
+// It returns the source address of the header, or nil if there is no header or it doesn't carry an address,
+// e.g. for health checks of the proxy.
+func readHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case v1Signature[0]:
+		if signature, err := r.Peek(len(v1Signature)); err != nil || !bytes.Equal(signature, v1Signature) {
+			return nil, err
+		}
+		return readV1(r)
+	case v2Signature[0]:
+		if signature, err := r.Peek(len(v2Signature)); err != nil || !bytes.Equal(signature, v2Signature) {
+			return nil, err
+		}
+		return readV2(r)
+	}
+	return nil, nil
+}
+
+// readV1 parses the human-readable header, e.g. "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	if len(line) > v1MaxLength || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 header too long or not terminated", errInvalidHeader)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", errInvalidHeader, line)
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, fmt.Errorf("%w: invalid source address %q", errInvalidHeader, fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid source port %q", errInvalidHeader, fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 parses the binary header.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	version, command := header[12]>>4, header[12]&0x0f
+	family, protocol := header[13]>>4, header[13]&0x0f
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+	if version != v2VersionNibble || length > v2MaxLength {
+		return nil, fmt.Errorf("%w: version %d with %d bytes", errInvalidHeader, version, length)
+	}
+
+	// the addresses are followed by optional TLVs, which are not used
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case command == v2CommandLocal:
+		// connections of the proxy itself, e.g. health checks
+		return nil, nil
+	case command != v2CommandProxy:
+		return nil, fmt.Errorf("%w: unknown command %d", errInvalidHeader, command)
+	case protocol != v2ProtocolTCP:
+		return nil, nil
+	case family == v2FamilyInet && length >= v2AddrLenInet:
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case family == v2FamilyInet6 && length >= v2AddrLenInet6:
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	case family == v2FamilyInet || family == v2FamilyInet6:
+		return nil, fmt.Errorf("%w: address block too short", errInvalidHeader)
+	}
+	// unix sockets and unspecified families carry no usable client address
+	return nil, nil
+}
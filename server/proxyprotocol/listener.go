@@ -0,0 +1,91 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/server/utils"
+)
+
+// headerTimeout limits how long a connection may take to send its PROXY protocol header
+const headerTimeout = 10 * time.Second
+
+// Listener reads PROXY protocol headers of connections from trusted sources, so that the address
+// of the client is returned by RemoteAddr instead of the address of the proxy.
+type Listener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+// NewListener wraps listener, only connections from the trusted networks may send a PROXY protocol header.
+func NewListener(listener net.Listener, trusted []*net.IPNet) *Listener {
+	return &Listener{Listener: listener, trusted: trusted}
+}
+
+// Accept returns the next connection. The header is read with the first call of Read or RemoteAddr,
+// so that a slow connection doesn't block accepting others.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn}, nil
+}
+
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && utils.ContainsIP(l.trusted, tcpAddr.IP)
+}
+
+// Conn is a connection from a trusted proxy.
+type Conn struct {
+	net.Conn
+
+	once       sync.Once
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the client, or the address of the proxy if it didn't send one.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// ProxyAddr returns the address of the proxy the connection came from.
+func (c *Conn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	c.reader = bufio.NewReader(c.Conn)
+	if err := c.Conn.SetReadDeadline(time.Now().Add(headerTimeout)); err != nil {
+		c.err = err
+		return
+	}
+	c.remoteAddr, c.err = readHeader(c.reader)
+	if c.err != nil {
+		log.Debug().Err(c.err).Str("proxy", c.Conn.RemoteAddr().String()).Msg("could not read PROXY protocol header")
+		return
+	}
+	c.err = c.Conn.SetReadDeadline(time.Time{})
+}
@@ -0,0 +1,124 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/utils"
+)
+
+func v2Header(command, family byte, addresses []byte) string {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, v2VersionNibble<<4|command, family<<4|v2ProtocolTCP, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return string(append(header, addresses...))
+}
+
+func TestReadHeader(t *testing.T) {
+	inet := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb}
+	inet6 := make([]byte, v2AddrLenInet6)
+	copy(inet6, net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(inet6[32:34], 56324)
+
+	for _, tc := range []struct {
+		name, input, addr string
+		err               bool
+	}{
+		{name: "v1 tcp4", input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", addr: "192.0.2.1:56324"},
+		{name: "v1 tcp6", input: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", addr: "[2001:db8::1]:56324"},
+		{name: "v1 unknown", input: "PROXY UNKNOWN\r\n"},
+		{name: "v1 wrong family", input: "PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n", err: true},
+		{name: "v1 not terminated", input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n", err: true},
+		{name: "v1 too long", input: "PROXY TCP4 " + strings.Repeat("1", 100) + "\r\n", err: true},
+		{name: "v2 inet", input: v2Header(v2CommandProxy, v2FamilyInet, inet), addr: "192.0.2.1:56324"},
+		{name: "v2 inet6", input: v2Header(v2CommandProxy, v2FamilyInet6, inet6), addr: "[2001:db8::1]:56324"},
+		{name: "v2 local", input: v2Header(v2CommandLocal, 0, nil)},
+		{name: "v2 short", input: v2Header(v2CommandProxy, v2FamilyInet, inet[:4]), err: true},
+		{name: "no header", input: "GET / HTTP/1.1\r\n"},
+		{name: "tls", input: "\x16\x03\x01"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tc.input + "payload"))
+			addr, err := readHeader(r)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tc.addr == "" {
+				assert.Nil(t, addr)
+			} else if assert.NotNil(t, addr) {
+				assert.EqualValues(t, tc.addr, addr.String())
+			}
+
+			// the connection continues right after the header
+			rest, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasSuffix(string(rest), "payload"))
+			if tc.addr != "" {
+				assert.EqualValues(t, "payload", string(rest))
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer inner.Close()
+
+	for _, tc := range []struct {
+		trusted []string
+		addr    string
+	}{
+		{trusted: []string{"127.0.0.0/8"}, addr: "192.0.2.1:56324"},
+		// headers of untrusted sources are not parsed
+		{trusted: []string{"192.0.2.0/24"}},
+	} {
+		trusted, err := utils.ParseNetworks(tc.trusted)
+		assert.NoError(t, err)
+		listener := NewListener(inner, trusted)
+
+		client, err := net.Dial("tcp", inner.Addr().String())
+		assert.NoError(t, err)
+		_, err = client.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello"))
+		assert.NoError(t, err)
+		assert.NoError(t, client.Close())
+
+		conn, err := listener.Accept()
+		assert.NoError(t, err)
+		body, err := io.ReadAll(conn)
+		assert.NoError(t, err)
+		if tc.addr != "" {
+			assert.EqualValues(t, tc.addr, conn.RemoteAddr().String())
+			assert.EqualValues(t, client.LocalAddr().String(), conn.(*Conn).ProxyAddr().String())
+			assert.EqualValues(t, "hello", string(body))
+		} else {
+			assert.EqualValues(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+			assert.Contains(t, string(body), "PROXY TCP4")
+		}
+		assert.NoError(t, conn.Close())
+	}
+}
+
+func TestConnContext(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	// connections from untrusted sources are not wrapped, so they have no proxy
+	assert.Nil(t, ProxyAddr(ConnContext(context.Background(), server)))
+
+	conn := &Conn{Conn: server}
+	assert.EqualValues(t, server.RemoteAddr(), ProxyAddr(ConnContext(context.Background(), conn)))
+	// the TLS listener wraps the connection of the PROXY protocol listener
+	assert.EqualValues(t, server.RemoteAddr(), ProxyAddr(ConnContext(context.Background(), tls.Server(conn, &tls.Config{}))))
+}
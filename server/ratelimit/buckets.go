@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// buckets holds a token bucket per key. A bucket that was not used for a whole period is full again,
+// so it is dropped and created anew on the next request.
+type buckets struct {
+	limit  rate.Limit
+	burst  int
+	period time.Duration
+
+	mutex     sync.Mutex
+	limiters  map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// newBuckets allows requests per period for every key, it returns nil if requests is not positive.
+func newBuckets(requests int, period time.Duration) *buckets {
+	if requests <= 0 {
+		return nil
+	}
+	return &buckets{
+		limit:    rate.Limit(float64(requests) / period.Seconds()),
+		burst:    requests,
+		period:   period,
+		limiters: make(map[string]*bucket),
+	}
+}
+
+// take consumes a token of the bucket of key. If none is left, it returns false and how long to wait for the next token.
+func (b *buckets) take(key string, now time.Time) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if now.Sub(b.lastSweep) >= b.period {
+		b.sweep(now)
+	}
+
+	bk, ok := b.limiters[key]
+	if !ok {
+		bk = &bucket{limiter: rate.NewLimiter(b.limit, b.burst)}
+		b.limiters[key] = bk
+	}
+	bk.lastUsed = now
+
+	reservation := bk.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// the token is only taken if the request is served
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep drops the buckets that are full again, the caller has to hold the lock
+func (b *buckets) sweep(now time.Time) {
+	for key, bk := range b.limiters {
+		if now.Sub(bk.lastUsed) >= b.period {
+			delete(b.limiters, key)
+		}
+	}
+	b.lastSweep = now
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/html"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/utils"
+)
+
+const (
+	headerRetryAfter    = "Retry-After"
+	headerForwardedFor  = "X-Forwarded-For"
+	headerCacheControl  = "Cache-Control"
+	ipv6ClientPrefixLen = 64
+)
+
+// Limiter throttles requests with a token bucket per client IP and per site, so that a single client
+// or site can't use up the Gitea API for everyone.
+type Limiter struct {
+	clients *buckets
+	sites   *buckets
+
+	allowedClients []*net.IPNet
+	// allowedSites contains owners and owner/repo pairs in lower case
+	allowedSites   map[string]bool
+	trustedProxies []*net.IPNet
+
+	now func() time.Time
+}
+
+// New returns nil if rate limiting is disabled.
+func New(cfg config.RateLimitConfig) (*Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	clientPeriod, err := time.ParseDuration(cfg.ClientPeriod)
+	if err != nil || clientPeriod <= 0 {
+		return nil, fmt.Errorf("invalid client rate limit period (RATE_LIMIT_CLIENT_PERIOD) %q", cfg.ClientPeriod)
+	}
+	sitePeriod, err := time.ParseDuration(cfg.SitePeriod)
+	if err != nil || sitePeriod <= 0 {
+		return nil, fmt.Errorf("invalid site rate limit period (RATE_LIMIT_SITE_PERIOD) %q", cfg.SitePeriod)
+	}
+	allowedClients, err := utils.ParseNetworks(cfg.AllowedClients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed client (RATE_LIMIT_ALLOWED_CLIENTS): %w", err)
+	}
+	trustedProxies, err := utils.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy (RATE_LIMIT_TRUSTED_PROXIES): %w", err)
+	}
+	allowedSites := make(map[string]bool, len(cfg.AllowedSites))
+	for _, site := range cfg.AllowedSites {
+		allowedSites[strings.ToLower(strings.Trim(site, "/"))] = true
+	}
+
+	return &Limiter{
+		clients:        newBuckets(cfg.ClientRequests, clientPeriod),
+		sites:          newBuckets(cfg.SiteRequests, sitePeriod),
+		allowedClients: allowedClients,
+		allowedSites:   allowedSites,
+		trustedProxies: trustedProxies,
+		now:            time.Now,
+	}, nil
+}
+
+// AllowClient returns whether the client of the request may be served, otherwise it responds with 429.
+func (l *Limiter) AllowClient(ctx *context.Context) bool {
+	if l == nil || l.clients == nil {
+		return true
+	}
+	ip := l.clientIP(ctx.Req)
+	if ip != nil && utils.ContainsIP(l.allowedClients, ip) {
+		return true
+	}
+
+	key := clientKey(ip, ctx.Req.RemoteAddr)
+	if ok, wait := l.clients.take(key, l.now()); !ok {
+		log.Debug().Str("client", key).Msg("client exceeded rate limit")
+		tooManyRequests(ctx, wait, "you sent too many requests, please try again later")
+		return false
+	}
+	return true
+}
+
+// AllowSite returns whether a request for the repository may be served, otherwise it responds with 429.
+func (l *Limiter) AllowSite(ctx *context.Context, owner, repo string) bool {
+	if l == nil || l.sites == nil {
+		return true
+	}
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+	key := owner + "/" + repo
+	if l.allowedSites[owner] || l.allowedSites[key] {
+		return true
+	}
+
+	if ok, wait := l.sites.take(key, l.now()); !ok {
+		log.Debug().Str("site", key).Msg("site exceeded rate limit")
+		tooManyRequests(ctx, wait, "this site got too many requests, please try again later")
+		return false
+	}
+	return true
+}
+
+// clientIP returns the address of the client. Requests of trusted proxies are attributed to the
+// last address in the X-Forwarded-For header that is not a trusted proxy itself.
+func (l *Limiter) clientIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !utils.ContainsIP(l.trustedProxies, ip) {
+		return ip
+	}
+
+	// proxies append the address they got the request from, so only the right end of the header can be trusted
+	forwarded := strings.Split(strings.Join(req.Header.Values(headerForwardedFor), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		next := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if next == nil {
+			break
+		}
+		ip = next
+		if !utils.ContainsIP(l.trustedProxies, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// clientKey is the address of IPv4 clients and the /64 network of IPv6 clients, which usually get a whole network.
+func clientKey(ip net.IP, remoteAddr string) string {
+	if ip == nil {
+		return remoteAddr
+	}
+	if ip.To4() != nil {
+		return ip.String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(ipv6ClientPrefixLen, 128)), Mask: net.CIDRMask(ipv6ClientPrefixLen, 128)}).String()
+}
+
+func tooManyRequests(ctx *context.Context, wait time.Duration, msg string) {
+	ctx.RespWriter.Header().Set(headerRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	ctx.RespWriter.Header().Set(headerCacheControl, "no-store")
+	html.ReturnErrorPage(ctx, msg, http.StatusTooManyRequests)
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+func newTestLimiter(t *testing.T, cfg config.RateLimitConfig) (*Limiter, *time.Time) {
+	cfg.Enabled = true
+	if cfg.ClientPeriod == "" {
+		cfg.ClientPeriod = "1m"
+	}
+	if cfg.SitePeriod == "" {
+		cfg.SitePeriod = "1m"
+	}
+	l, err := New(cfg)
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func request(remoteAddr string, forwardedFor ...string) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.codeberg.page/", nil)
+	req.RemoteAddr = remoteAddr
+	for _, value := range forwardedFor {
+		req.Header.Add(headerForwardedFor, value)
+	}
+	resp := httptest.NewRecorder()
+	return context.New(resp, req), resp
+}
+
+func TestNew(t *testing.T) {
+	l, err := New(config.RateLimitConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+	// a disabled limiter allows everything
+	assert.True(t, l.AllowClient(nil))
+	assert.True(t, l.AllowSite(nil, "owner", "repo"))
+
+	_, err = New(config.RateLimitConfig{Enabled: true, ClientPeriod: "never", SitePeriod: "1m"})
+	assert.Error(t, err)
+	_, err = New(config.RateLimitConfig{Enabled: true, ClientPeriod: "1m", SitePeriod: "1m", AllowedClients: []string{"not-an-ip"}})
+	assert.Error(t, err)
+}
+
+func TestAllowClient(t *testing.T) {
+	l, now := newTestLimiter(t, config.RateLimitConfig{ClientRequests: 2, AllowedClients: []string{"192.0.2.0/24"}})
+
+	for i := 0; i < 2; i++ {
+		ctx, _ := request("198.51.100.1:1234")
+		assert.True(t, l.AllowClient(ctx))
+	}
+	ctx, resp := request("198.51.100.1:1234")
+	assert.False(t, l.AllowClient(ctx))
+	assert.EqualValues(t, http.StatusTooManyRequests, resp.Code)
+	assert.EqualValues(t, "30", resp.Header().Get(headerRetryAfter))
+	assert.EqualValues(t, "no-store", resp.Header().Get(headerCacheControl))
+
+	// other clients have their own bucket
+	ctx, _ = request("198.51.100.2:1234")
+	assert.True(t, l.AllowClient(ctx))
+	// allowed clients are never throttled
+	for i := 0; i < 3; i++ {
+		ctx, _ = request("192.0.2.1:1234")
+		assert.True(t, l.AllowClient(ctx))
+	}
+
+	// the bucket refills over time
+	*now = now.Add(30 * time.Second)
+	ctx, _ = request("198.51.100.1:1234")
+	assert.True(t, l.AllowClient(ctx))
+}
+
+func TestAllowClientIPv6Network(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{ClientRequests: 1})
+
+	ctx, _ := request("[2001:db8:1:1::1]:1234")
+	assert.True(t, l.AllowClient(ctx))
+	// the same /64 network shares the bucket
+	ctx, _ = request("[2001:db8:1:1::2]:1234")
+	assert.False(t, l.AllowClient(ctx))
+	ctx, _ = request("[2001:db8:1:2::1]:1234")
+	assert.True(t, l.AllowClient(ctx))
+}
+
+func TestClientIP(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}})
+
+	// the header of untrusted clients is ignored
+	ctx, _ := request("198.51.100.1:1234", "203.0.113.1")
+	assert.EqualValues(t, "198.51.100.1", l.clientIP(ctx.Req).String())
+
+	// trusted proxies are skipped from the right
+	ctx, _ = request("10.0.0.1:1234", "203.0.113.7, 203.0.113.1", "192.0.2.1")
+	assert.EqualValues(t, "203.0.113.1", l.clientIP(ctx.Req).String())
+
+	// a missing header keeps the proxy
+	ctx, _ = request("10.0.0.1:1234")
+	assert.EqualValues(t, "10.0.0.1", l.clientIP(ctx.Req).String())
+}
+
+func TestAllowSite(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{SiteRequests: 1, AllowedSites: []string{"codeberg", "example/docs"}})
+
+	ctx, _ := request("198.51.100.1:1234")
+	assert.True(t, l.AllowSite(ctx, "example", "pages"))
+	ctx, resp := request("198.51.100.2:1234")
+	assert.False(t, l.AllowSite(ctx, "Example", "Pages"))
+	assert.EqualValues(t, http.StatusTooManyRequests, resp.Code)
+	assert.EqualValues(t, "60", resp.Header().Get(headerRetryAfter))
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, l.AllowSite(ctx, "codeberg", "pages"))
+		assert.True(t, l.AllowSite(ctx, "example", "docs"))
+	}
+	// the client limit is disabled
+	assert.True(t, l.AllowClient(ctx))
+}
+
+func TestBucketsSweep(t *testing.T) {
+	b := newBuckets(1, time.Minute)
+	now := time.Unix(1700000000, 0)
+	ok, _ := b.take("a", now)
+	assert.True(t, ok)
+	ok, _ = b.take("b", now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	// "a" is full again and dropped, "b" is still in use
+	ok, _ = b.take("b", now.Add(time.Minute))
+	assert.False(t, ok)
+	assert.Len(t, b.limiters, 1)
+}
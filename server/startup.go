@@ -18,10 +18,18 @@ import (
 	cmd "codeberg.org/codeberg/pages/cli"
 	"codeberg.org/codeberg/pages/config"
 	"codeberg.org/codeberg/pages/server/acme"
+	"codeberg.org/codeberg/pages/server/admin"
 	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/certificates"
+	"codeberg.org/codeberg/pages/server/dns"
 	"codeberg.org/codeberg/pages/server/gitea"
 	"codeberg.org/codeberg/pages/server/handler"
+	"codeberg.org/codeberg/pages/server/oauth"
+	"codeberg.org/codeberg/pages/server/proxyprotocol"
+	"codeberg.org/codeberg/pages/server/ratelimit"
+	"codeberg.org/codeberg/pages/server/takedown"
+	"codeberg.org/codeberg/pages/server/upstream"
+	"codeberg.org/codeberg/pages/server/utils"
 )
 
 // Serve sets up and starts the web server.
@@ -63,8 +71,30 @@ func Serve(ctx *cli.Context) error {
 		return fmt.Errorf("no default branches set (PAGES_BRANCHES)")
 	}
 
+	publicIPs, err := dns.ParseIPs(cfg.Server.PublicIPs)
+	if err != nil {
+		return fmt.Errorf("invalid public IPs (PUBLIC_IPS): %w", err)
+	}
+	dnsTimeout, err := time.ParseDuration(cfg.DNS.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid DNS timeout (DNS_TIMEOUT): %w", err)
+	}
+	resolver, err := dns.NewResolver(cfg.DNS.Servers, cfg.DNS.Protocol, dnsTimeout, cfg.DNS.RequireDNSSEC)
+	if err != nil {
+		return fmt.Errorf("invalid DNS config: %w", err)
+	}
+	dns.Configure(resolver, publicIPs)
+
+	if cfg.Admin.Enabled && cfg.Admin.Token == "" {
+		return fmt.Errorf("admin api enabled, but no admin token set (ADMIN_TOKEN)")
+	}
+
+	if err := upstream.ValidateCacheControlRules(cfg.Server.CacheControl.Rules); err != nil {
+		return fmt.Errorf("invalid cache control rules: %w", err)
+	}
+
 	// Init ssl cert database
-	certDB, closeFn, err := cmd.OpenCertDB(ctx)
+	certDB, closeFn, err := cmd.OpenCertDBWithKey(cfg.Database.Type, cfg.Database.Conn, cfg.Database.EncryptionKey, cfg.Database.EncryptionKeyFile)
 	if err != nil {
 		return err
 	}
@@ -78,14 +108,33 @@ func Serve(ctx *cli.Context) error {
 	dnsLookupCache := cache.NewInMemoryCache()
 	// redirectsCache stores redirects in _redirects files
 	redirectsCache := cache.NewInMemoryCache()
+	// siteConfigCache stores the parsed .pages.toml files
+	siteConfigCache := cache.NewInMemoryCache()
 	// clientResponseCache stores responses from the Gitea server
 	clientResponseCache := cache.NewInMemoryCache()
+	// quotaExceededCache stores custom domains whose owner exceeded the certificate quota
+	quotaExceededCache := cache.NewInMemoryCache()
 
-	giteaClient, err := gitea.NewClient(cfg.Gitea, clientResponseCache)
+	giteaClient, err := gitea.NewClient(cfg.Gitea, clientResponseCache, certDB)
 	if err != nil {
 		return fmt.Errorf("could not create new gitea client: %v", err)
 	}
 
+	oauthProvider, err := oauth.NewProvider(cfg.OAuth, cfg.Gitea.Root, cfg.Server.RawDomain)
+	if err != nil {
+		return err
+	}
+
+	rateLimiter, err := ratelimit.New(cfg.RateLimit)
+	if err != nil {
+		return err
+	}
+
+	takedowns, err := takedown.New(certDB)
+	if err != nil {
+		return fmt.Errorf("could not load takedowns: %v", err)
+	}
+
 	acmeClient, err := acme.CreateAcmeClient(cfg.ACME, cfg.Server.HttpServerEnabled, challengeCache)
 	if err != nil {
 		return err
@@ -95,9 +144,14 @@ func Serve(ctx *cli.Context) error {
 		return err
 	}
 
+	proxyProtocolSources, err := parseProxyProtocolSources(cfg.Server)
+	if err != nil {
+		return err
+	}
+
 	// Create listener for SSL connections
 	log.Info().Msgf("Create TCP listener for SSL on %s", listeningSSLAddress)
-	listener, err := net.Listen("tcp", listeningSSLAddress)
+	listener, err := listen(listeningSSLAddress, proxyProtocolSources)
 	if err != nil {
 		return fmt.Errorf("couldn't create listener: %v", err)
 	}
@@ -108,34 +162,101 @@ func Serve(ctx *cli.Context) error {
 		giteaClient,
 		acmeClient,
 		cfg.Server.PagesBranches[0],
-		keyCache, challengeCache, dnsLookupCache, canonicalDomainCache,
+		takedowns,
+		keyCache, challengeCache, dnsLookupCache, canonicalDomainCache, quotaExceededCache,
 		certDB,
 	))
 
 	interval := 12 * time.Hour
+	// renewals are rate limited by the ACME client anyway, so a few workers are enough
+	renewalWorkers := 2
 	certMaintainCtx, cancelCertMaintain := context.WithCancel(context.Background())
 	defer cancelCertMaintain()
-	go certificates.MaintainCertDB(certMaintainCtx, interval, acmeClient, cfg.Server.MainDomain, certDB)
+	renewalQueue := certificates.NewRenewalQueue(acmeClient, cfg.Server.MainDomain, certDB, renewalWorkers)
+	go renewalQueue.Run(certMaintainCtx)
+	go certificates.MaintainCertDB(certMaintainCtx, interval, renewalQueue, cfg.Server.MainDomain, certDB)
+	go takedowns.Run(certMaintainCtx, takedown.ReloadInterval)
 
 	if cfg.Server.HttpServerEnabled {
 		// Create handler for http->https redirect and http acme challenges
 		httpHandler := certificates.SetupHTTPACMEChallengeServer(challengeCache, uint(cfg.Server.Port))
 
 		// Create listener for http and start listening
+		httpListener, err := listen(listeningHTTPAddress, proxyProtocolSources)
+		if err != nil {
+			return fmt.Errorf("couldn't create HTTP listener: %v", err)
+		}
 		go func() {
 			log.Info().Msgf("Start HTTP server listening on %s", listeningHTTPAddress)
-			err := http.ListenAndServe(listeningHTTPAddress, httpHandler)
+			httpServer := &http.Server{Handler: httpHandler, ConnContext: proxyprotocol.ConnContext}
+			err := httpServer.Serve(httpListener)
 			if err != nil {
 				log.Error().Err(err).Msg("Couldn't start HTTP server")
 			}
 		}()
 	}
 
+	if cfg.Admin.Enabled {
+		adminAPI := &admin.API{
+			Config:               *cfg,
+			CertDB:               certDB,
+			AcmeClient:           acmeClient,
+			Quota:                acmeClient.Quota(),
+			GiteaClient:          giteaClient,
+			Takedowns:            takedowns,
+			KeyCache:             keyCache,
+			DNSLookupCache:       dnsLookupCache,
+			CanonicalDomainCache: canonicalDomainCache,
+			RedirectsCache:       redirectsCache,
+			SiteConfigCache:      siteConfigCache,
+			QuotaExceededCache:   quotaExceededCache,
+		}
+		listeningAdminAddress := fmt.Sprintf("%s:%d", cfg.Admin.Host, cfg.Admin.Port)
+
+		// Create listener for the admin api and start listening
+		go func() {
+			log.Info().Msgf("Start admin API server listening on %s", listeningAdminAddress)
+			err := http.ListenAndServe(listeningAdminAddress, adminAPI.Handler())
+			if err != nil {
+				log.Error().Err(err).Msg("Couldn't start admin API server")
+			}
+		}()
+	}
+
 	// Create ssl handler based on settings
-	sslHandler := handler.Handler(cfg.Server, giteaClient, dnsLookupCache, canonicalDomainCache, redirectsCache)
+	sslHandler := handler.Handler(cfg.Server, giteaClient, oauthProvider, rateLimiter, takedowns, dnsLookupCache, canonicalDomainCache, redirectsCache, siteConfigCache, quotaExceededCache)
 
 	// Start the ssl listener
 	log.Info().Msgf("Start SSL server using TCP listener on %s", listener.Addr())
 
-	return http.Serve(listener, sslHandler)
+	sslServer := &http.Server{Handler: sslHandler, ConnContext: proxyprotocol.ConnContext}
+	return sslServer.Serve(listener)
+}
+
+// parseProxyProtocolSources returns the networks allowed to send PROXY protocol headers, or nil if it is disabled.
+func parseProxyProtocolSources(cfg config.ServerConfig) ([]*net.IPNet, error) {
+	if !cfg.ProxyProtocol {
+		return nil, nil
+	}
+	sources, err := utils.ParseNetworks(cfg.ProxyProtocolSources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol source (PROXY_PROTOCOL_SOURCES): %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("PROXY protocol is enabled, but no sources are trusted (PROXY_PROTOCOL_SOURCES)")
+	}
+	return sources, nil
+}
+
+// listen creates a TCP listener, which reads PROXY protocol headers if proxyProtocolSources are given.
+func listen(address string, proxyProtocolSources []*net.IPNet) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxyProtocolSources) == 0 {
+		return listener, nil
+	}
+	log.Info().Msgf("Read PROXY protocol headers on %s", address)
+	return proxyprotocol.NewListener(listener, proxyProtocolSources), nil
 }
@@ -0,0 +1,126 @@
+package takedown
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/html"
+	pagesContext "codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+// ReloadInterval is how often the takedowns are reloaded, so that changes made with the CLI apply without a restart
+const ReloadInterval = time.Minute
+
+// Store is the part of the CertDB the takedowns are loaded from
+type Store interface {
+	Takedowns() ([]*database.Takedown, error)
+}
+
+// List keeps the takedowns in memory, so that checking a request doesn't need the database.
+type List struct {
+	store Store
+
+	mutex   sync.RWMutex
+	entries map[string]*database.Takedown
+}
+
+// New loads the takedowns from store.
+func New(store Store) (*List, error) {
+	l := &List{store: store}
+	return l, l.Reload()
+}
+
+// Reload replaces the takedowns in memory with the stored ones.
+func (l *List) Reload() error {
+	takedowns, err := l.store.Takedowns()
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]*database.Takedown, len(takedowns))
+	for _, takedown := range takedowns {
+		entries[key(takedown.Kind, takedown.Target)] = takedown
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = entries
+	return nil
+}
+
+// Run reloads the takedowns every interval until ctx is done.
+func (l *List) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				log.Error().Err(err).Msg("could not reload takedowns")
+			}
+		}
+	}
+}
+
+// Domain returns the takedown of domain or one of its parent domains, nil if there is none.
+func (l *List) Domain(domain string) *database.Takedown {
+	if l == nil {
+		return nil
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for domain != "" {
+		if takedown, ok := l.entries[key(database.TakedownDomain, domain)]; ok {
+			return takedown
+		}
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return nil
+}
+
+// Site returns the takedown of the owner, the repository or the branch of a site, nil if there is none.
+// The branch may be empty if it isn't known yet.
+func (l *List) Site(owner, repo, branch string) *database.Takedown {
+	if l == nil || owner == "" {
+		return nil
+	}
+	owner, repo = strings.ToLower(owner), strings.ToLower(repo)
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if takedown, ok := l.entries[key(database.TakedownOwner, owner)]; ok {
+		return takedown
+	}
+	if repo == "" {
+		return nil
+	}
+	if takedown, ok := l.entries[key(database.TakedownRepo, owner+"/"+repo)]; ok {
+		return takedown
+	}
+	if branch == "" {
+		return nil
+	}
+	return l.entries[key(database.TakedownBranch, owner+"/"+repo+"/"+branch)]
+}
+
+// Blocked serves the takedown page if the site is taken down and returns whether it did.
+func Blocked(ctx *pagesContext.Context, takedown *database.Takedown) bool {
+	if takedown == nil {
+		return false
+	}
+	log.Debug().Str("kind", takedown.Kind).Str("target", takedown.Target).Msg("site is taken down")
+	html.ReturnTakedownPage(ctx, takedown.Reason, takedown.Status)
+	return true
+}
+
+func key(kind, target string) string {
+	return kind + ":" + target
+}
@@ -0,0 +1,62 @@
+package takedown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/database"
+)
+
+type testStore []*database.Takedown
+
+func (s *testStore) Takedowns() ([]*database.Takedown, error) {
+	return *s, nil
+}
+
+func TestList(t *testing.T) {
+	store := &testStore{
+		{Kind: database.TakedownOwner, Target: "phisher", Status: http.StatusUnavailableForLegalReasons},
+		{Kind: database.TakedownRepo, Target: "example/malware", Status: http.StatusForbidden},
+		{Kind: database.TakedownBranch, Target: "example/pages/Phishing", Status: http.StatusUnavailableForLegalReasons},
+		{Kind: database.TakedownDomain, Target: "phishing.example.com", Status: http.StatusUnavailableForLegalReasons},
+	}
+	list, err := New(store)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, list.Site("Phisher", "pages", "main"))
+	assert.NotNil(t, list.Site("phisher", "", ""))
+	assert.NotNil(t, list.Site("example", "Malware", ""))
+	assert.NotNil(t, list.Site("example", "pages", "Phishing"))
+	assert.Nil(t, list.Site("example", "pages", "phishing"))
+	assert.Nil(t, list.Site("example", "pages", "main"))
+	assert.Nil(t, list.Site("", "", ""))
+
+	// subdomains of taken down domains are blocked as well
+	assert.NotNil(t, list.Domain("phishing.example.com"))
+	assert.NotNil(t, list.Domain("WWW.Phishing.Example.com."))
+	assert.Nil(t, list.Domain("example.com"))
+	assert.Nil(t, list.Domain("other-phishing.example.com"))
+
+	*store = (*store)[:1]
+	assert.NoError(t, list.Reload())
+	assert.Nil(t, list.Domain("phishing.example.com"))
+	assert.NotNil(t, list.Site("phisher", "pages", "main"))
+
+	var disabled *List
+	assert.Nil(t, disabled.Site("phisher", "pages", "main"))
+	assert.Nil(t, disabled.Domain("phishing.example.com"))
+}
+
+func TestBlocked(t *testing.T) {
+	resp := httptest.NewRecorder()
+	assert.False(t, Blocked(context.New(resp, nil), nil))
+	assert.EqualValues(t, http.StatusOK, resp.Code)
+
+	assert.True(t, Blocked(context.New(resp, nil), &database.Takedown{Reason: "phishing", Status: http.StatusForbidden}))
+	assert.EqualValues(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "phishing")
+}
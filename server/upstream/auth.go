@@ -0,0 +1,87 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"codeberg.org/codeberg/pages/html"
+	"codeberg.org/codeberg/pages/server/context"
+)
+
+// siteAuthFile protects a site with HTTP Basic auth, it contains "user:bcrypt-hash" lines like htpasswd files.
+const siteAuthFile = ".pages-auth"
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+)
+
+// siteAuth holds the users that may read a protected site.
+type siteAuth struct {
+	// hashes of the passwords by user name, only bcrypt is supported
+	hashes map[string][]byte
+	// verified remembers checked credentials, as bcrypt is slow on purpose
+	verified sync.Map
+}
+
+// parseSiteAuth parses the lines of a `.pages-auth` file. Invalid lines are skipped,
+// so a file without valid lines protects the site from everyone.
+func parseSiteAuth(body []byte) *siteAuth {
+	auth := &siteAuth{hashes: make(map[string][]byte)}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if _, err := bcrypt.Cost([]byte(hash)); !ok || user == "" || err != nil {
+			log.Debug().Msgf("skip invalid line of %s for user %q", siteAuthFile, user)
+			continue
+		}
+		auth.hashes[user] = []byte(hash)
+	}
+	return auth
+}
+
+// check reports whether the password of user is valid.
+func (a *siteAuth) check(user, password string) bool {
+	hash, ok := a.hashes[user]
+	if !ok {
+		return false
+	}
+	// the hash is part of the key, so credentials are checked again if the file changes
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + string(hash)))
+	if _, ok := a.verified.Load(key); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	a.verified.Store(key, struct{}{})
+	return true
+}
+
+// Authorize asks for credentials if the site is protected by a `.pages-auth` file, and returns false if the
+// request must not be served. Responses of protected sites are private and never stored by shared caches.
+func (o *Options) Authorize(ctx *context.Context, siteConfig *SiteConfig) bool {
+	if siteConfig.auth == nil {
+		return true
+	}
+	ctx.RespWriter.Header().Set(headerCacheControl, "private, no-cache")
+	ctx.RespWriter.Header().Add(headerVary, headerAuthorization)
+
+	if user, password, ok := ctx.Req.BasicAuth(); ok && siteConfig.auth.check(user, password) {
+		return true
+	}
+	ctx.RespWriter.Header().Set(headerWWWAuthenticate, `Basic realm="`+o.TargetOwner+"/"+o.TargetRepo+`", charset="UTF-8"`)
+	html.ReturnErrorPage(ctx, "this site is protected, please log in", http.StatusUnauthorized)
+	return false
+}
@@ -0,0 +1,98 @@
+package upstream
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/gitea"
+)
+
+func TestParseSiteAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	auth := parseSiteAuth([]byte("# readers\nalice:" + string(hash) + "\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n\ninvalid\n"))
+	assert.Len(t, auth.hashes, 1)
+	assert.True(t, auth.check("alice", "secret"))
+	// verified credentials are remembered
+	assert.True(t, auth.check("alice", "secret"))
+	assert.False(t, auth.check("alice", "wrong"))
+	assert.False(t, auth.check("bob", "password"))
+
+	// a file without valid users protects the site from everyone
+	auth = parseSiteAuth([]byte("invalid"))
+	assert.Empty(t, auth.hashes)
+	assert.False(t, auth.check("", ""))
+}
+
+func TestAuthorize(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	protected := &SiteConfig{auth: parseSiteAuth([]byte("alice:" + string(hash)))}
+
+	request := func(siteConfig *SiteConfig, user, password string) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		resp := httptest.NewRecorder()
+		o := &Options{TargetOwner: "owner", TargetRepo: "repo"}
+		return resp, o.Authorize(context.New(resp, req), siteConfig)
+	}
+
+	resp, ok := request(&SiteConfig{}, "", "")
+	assert.True(t, ok)
+	assert.Empty(t, resp.Header().Get(headerCacheControl))
+
+	resp, ok = request(protected, "", "")
+	assert.False(t, ok)
+	assert.EqualValues(t, http.StatusUnauthorized, resp.Code)
+	assert.EqualValues(t, `Basic realm="owner/repo", charset="UTF-8"`, resp.Header().Get(headerWWWAuthenticate))
+
+	_, ok = request(protected, "alice", "wrong")
+	assert.False(t, ok)
+
+	resp, ok = request(protected, "alice", "secret")
+	assert.True(t, ok)
+	assert.EqualValues(t, "private, no-cache", resp.Header().Get(headerCacheControl))
+}
+
+func TestSiteAuthOfDefaultBranchProtectsAllBranches(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	giteaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/version":
+			_ = json.NewEncoder(w).Encode(map[string]string{"version": "1.21.0"})
+		case r.URL.Path == "/api/v1/repos/owner/repo":
+			_ = json.NewEncoder(w).Encode(map[string]string{"default_branch": "main"})
+		case strings.HasSuffix(r.URL.Path, "/"+siteAuthFile) && r.URL.Query().Get("ref") == "main":
+			_, _ = w.Write([]byte("alice:" + string(hash)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer giteaServer.Close()
+	giteaClient, err := gitea.NewClient(config.GiteaConfig{Root: giteaServer.URL}, cache.NewInMemoryCache(), nil)
+	assert.NoError(t, err)
+
+	// the other branch has no .pages-auth file, but is protected by the one of the default branch
+	o := &Options{TargetOwner: "owner", TargetRepo: "repo", TargetBranch: "other"}
+	siteConfig := o.GetSiteConfig(giteaClient, cache.NewInMemoryCache())
+	resp := httptest.NewRecorder()
+	assert.False(t, o.Authorize(context.New(resp, httptest.NewRequest(http.MethodGet, "https://example.com/", nil)), siteConfig))
+	assert.EqualValues(t, http.StatusUnauthorized, resp.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.SetBasicAuth("alice", "secret")
+	assert.True(t, o.Authorize(context.New(httptest.NewRecorder(), req), siteConfig))
+}
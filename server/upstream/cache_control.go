@@ -0,0 +1,169 @@
+package upstream
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"codeberg.org/codeberg/pages/config"
+)
+
+// ValidateCacheControlRules checks that every rule has a value, matches something and has a valid glob pattern.
+func ValidateCacheControlRules(rules []config.CacheControlRule) error {
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Value) == "" {
+			return fmt.Errorf("cache control rule for path %q and mime type %q has no value", rule.Path, rule.MimeType)
+		}
+		if rule.Path == "" && rule.MimeType == "" {
+			return fmt.Errorf("cache control rule %q needs a path or a mime type", rule.Value)
+		}
+		if _, err := path.Match(strings.TrimSuffix(rule.Path, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid cache control path %q: %w", rule.Path, err)
+		}
+	}
+	return nil
+}
+
+// cacheControl returns the Cache-Control header of a file served with the given Content-Type. The rules of the site
+// win over the ones of the operator, which win over the fingerprint detection and the default.
+// HTML pages are never immutable, as their URLs don't change when their content does.
+func (o *Options) cacheControl(filePath, contentType string) string {
+	if value, ok := o.siteCacheControl(filePath, contentType); ok {
+		return value
+	}
+	filePath = "/" + strings.TrimPrefix(filePath, "/")
+	mimeType, _, _ := mime.ParseMediaType(contentType)
+
+	if value, ok := matchCacheControlRules(o.CacheControl.Rules, filePath, mimeType); ok {
+		return value
+	}
+	if o.CacheControl.Immutable != "" && mimeType != "text/html" && isFingerprinted(filePath) {
+		return o.CacheControl.Immutable
+	}
+	if o.siteConfig != nil && o.siteConfig.CacheMaxAge != nil {
+		return "public, max-age=" + strconv.Itoa(*o.siteConfig.CacheMaxAge)
+	}
+	return o.CacheControl.Default
+}
+
+// siteCacheControl returns the Cache-Control header of the first rule in the site config that matches the file.
+func (o *Options) siteCacheControl(filePath, contentType string) (string, bool) {
+	if o.siteConfig == nil {
+		return "", false
+	}
+	mimeType, _, _ := mime.ParseMediaType(contentType)
+	return matchCacheControlRules(o.siteConfig.CacheControl, "/"+strings.TrimPrefix(filePath, "/"), mimeType)
+}
+
+func matchCacheControlRules(rules []config.CacheControlRule, filePath, mimeType string) (string, bool) {
+	for _, rule := range rules {
+		if rule.Path != "" && !matchPath(rule.Path, filePath) {
+			continue
+		}
+		if rule.MimeType != "" && !matchMimeType(rule.MimeType, mimeType) {
+			continue
+		}
+		return rule.Value, true
+	}
+	return "", false
+}
+
+// matchPath matches patterns without "/" against the file name and others against the whole path,
+// a trailing "/**" matches everything in the directory.
+func matchPath(pattern, filePath string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(filePath))
+		return matched
+	}
+	pattern = "/" + strings.TrimPrefix(pattern, "/")
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if dir == "" {
+			return true
+		}
+		for ; filePath != "/"; filePath = path.Dir(filePath) {
+			if matched, _ := path.Match(dir, path.Dir(filePath)); matched {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(pattern, filePath)
+	return matched
+}
+
+// matchMimeType matches "text/html" exactly and "image/*" against all images.
+func matchMimeType(pattern, mimeType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, strings.ToLower(prefix)+"/")
+	}
+	return strings.EqualFold(pattern, mimeType)
+}
+
+// isFingerprinted reports whether a file name contains a content hash, so the file never changes.
+// It recognizes hex hashes like "app.3f9a1c2b.js" or "main-3f9a1c2b.css" and the base64url hashes of
+// bundlers like "index-Dk3x9aZq7LmP2wRt.js".
+func isFingerprinted(filePath string) bool {
+	parts := strings.Split(path.Base(filePath), ".")
+	for i, part := range parts[:len(parts)-1] {
+		if i == 0 {
+			// the hash is appended to the name with a dash
+			dash := strings.LastIndex(part, "-")
+			if dash < 0 {
+				continue
+			}
+			part = part[dash+1:]
+		}
+		if isHash(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHash accepts lowercase hex strings of at least 8 characters and base64url strings of at least 16 characters.
+// They need a digit and a letter, base64url strings both cases, so that words, dates and names like "dec2023",
+// "abc123" or "Windows1" are not taken for hashes.
+func isHash(s string) bool {
+	var digits, lower, upper, hexLetters int
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'f':
+			hexLetters++
+			lower++
+		case c >= 'g' && c <= 'z':
+			lower++
+		case c >= 'A' && c <= 'Z':
+			upper++
+		case c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	if len(s) >= 8 && digits > 0 && hexLetters > 0 && digits+hexLetters == len(s) {
+		return true
+	}
+	return len(s) >= 16 && digits > 0 && lower > 0 && upper > 0
+}
+
+// privateCacheControl turns a public Cache-Control header into a private one. It drops immutable,
+// so that browsers still revalidate protected files after the access to them changed.
+func privateCacheControl(value string) string {
+	directives := []string{"private"}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		switch strings.ToLower(directive) {
+		case "", "public", "private", "immutable":
+			continue
+		}
+		directives = append(directives, directive)
+	}
+	if len(directives) == 1 {
+		// without any other directive the response would be cached heuristically
+		directives = append(directives, "no-cache")
+	}
+	return strings.Join(directives, ", ")
+}
@@ -0,0 +1,143 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/gitea"
+)
+
+func TestIsFingerprinted(t *testing.T) {
+	for _, name := range []string{
+		"/app.3f9a1c2b.js",
+		"/css/main-3f9a1c2b.css",
+		"/assets/index-Dk3x9aZq7LmP2wRt.js",
+		"/assets/chunk.Dk3x9aZq-LmP2_wRt.js",
+		"/chunk.0a1b2c3d4e5f.min.js",
+	} {
+		assert.True(t, isFingerprinted(name), name)
+	}
+	for _, name := range []string{
+		"/index.html",
+		"/app.js",
+		"/jquery-3.7.1.min.js",
+		"/posts/2024-01-01.html",
+		"/decade.css",
+		"/my-document.pdf",
+		"/3f9a1c2b",
+		"/app.3f9a1c.js",
+		"/archive-dec2023.html",
+		"/news/team-abc123.html",
+		"/blog/post.2024feb.html",
+		"/Windows1.css",
+		"/setup-Windows10.exe",
+		"/assets/index-BfK3x9aZ.js",
+		"/app.3F9A1C2B.js",
+		"/docs/MyProject2024Release.html",
+	} {
+		assert.False(t, isFingerprinted(name), name)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, matchPath("*.woff2", "/fonts/inter.woff2"))
+	assert.False(t, matchPath("*.woff2", "/fonts/inter.woff"))
+	assert.True(t, matchPath("/docs/*.html", "/docs/index.html"))
+	assert.False(t, matchPath("/docs/*.html", "/docs/api/index.html"))
+	assert.True(t, matchPath("docs/*.html", "/docs/index.html"))
+	assert.True(t, matchPath("/assets/**", "/assets/app.js"))
+	assert.True(t, matchPath("/assets/**", "/assets/img/logo.png"))
+	assert.False(t, matchPath("/assets/**", "/assets.html"))
+	assert.False(t, matchPath("/assets/**", "/other/assets/app.js"))
+	assert.True(t, matchPath("/**", "/index.html"))
+}
+
+func TestMatchMimeType(t *testing.T) {
+	assert.True(t, matchMimeType("text/html", "text/html"))
+	assert.True(t, matchMimeType("Text/HTML", "text/html"))
+	assert.False(t, matchMimeType("text/html", "text/css"))
+	assert.True(t, matchMimeType("image/*", "image/png"))
+	assert.False(t, matchMimeType("image/*", "text/html"))
+}
+
+func TestCacheControl(t *testing.T) {
+	o := &Options{CacheControl: config.CacheControlConfig{
+		Default:   "public, max-age=600",
+		Immutable: "public, max-age=31536000, immutable",
+		Rules: []config.CacheControlRule{
+			{MimeType: "text/html", Value: "no-cache"},
+			{Path: "/fonts/**", Value: "public, max-age=86400"},
+		},
+	}}
+	assert.EqualValues(t, "public, max-age=600", o.cacheControl("/app.js", "text/javascript"))
+	assert.EqualValues(t, "public, max-age=31536000, immutable", o.cacheControl("/app.3f9a1c2b.js", "text/javascript"))
+	assert.EqualValues(t, "no-cache", o.cacheControl("/index.html", "text/html; charset=utf-8"))
+	assert.EqualValues(t, "public, max-age=86400", o.cacheControl("fonts/inter-3f9a1c2b.woff2", "font/woff2"))
+	// HTML pages keep their URL when they change, so they are never immutable
+	withoutRules := &Options{CacheControl: config.CacheControlConfig{Default: o.CacheControl.Default, Immutable: o.CacheControl.Immutable}}
+	assert.EqualValues(t, "public, max-age=600", withoutRules.cacheControl("/page-3f9a1c2b.html", "text/html; charset=utf-8"))
+
+	// the rules of the site win over the ones of the operator, cacheMaxAge only replaces the default
+	cacheMaxAge := 60
+	o.ApplySiteConfig(&SiteConfig{
+		CacheMaxAge:  &cacheMaxAge,
+		CacheControl: []config.CacheControlRule{{Path: "*.html", Value: "public, max-age=300"}},
+	})
+	assert.EqualValues(t, "public, max-age=300", o.cacheControl("/index.html", "text/html"))
+	assert.EqualValues(t, "public, max-age=60", o.cacheControl("/app.js", "text/javascript"))
+	assert.EqualValues(t, "public, max-age=31536000, immutable", o.cacheControl("/app.3f9a1c2b.js", "text/javascript"))
+
+	// without an immutable policy fingerprinted files are treated like all others
+	o.CacheControl.Immutable = ""
+	assert.EqualValues(t, "public, max-age=60", o.cacheControl("/app.3f9a1c2b.js", "text/javascript"))
+}
+
+func TestValidateCacheControlRules(t *testing.T) {
+	assert.NoError(t, ValidateCacheControlRules(nil))
+	assert.NoError(t, ValidateCacheControlRules([]config.CacheControlRule{{Path: "/assets/**", Value: "no-store"}, {MimeType: "image/*", Value: "no-store"}}))
+	assert.Error(t, ValidateCacheControlRules([]config.CacheControlRule{{Path: "/assets/**"}}))
+	assert.Error(t, ValidateCacheControlRules([]config.CacheControlRule{{Value: "no-store"}}))
+	assert.Error(t, ValidateCacheControlRules([]config.CacheControlRule{{Path: "/[assets", Value: "no-store"}}))
+}
+
+func TestPrivateCacheControl(t *testing.T) {
+	assert.EqualValues(t, "private, max-age=600", privateCacheControl("public, max-age=600"))
+	assert.EqualValues(t, "private, max-age=31536000", privateCacheControl("public, max-age=31536000, immutable"))
+	assert.EqualValues(t, "private, no-cache", privateCacheControl("public"))
+	assert.EqualValues(t, "private, no-store", privateCacheControl("private, no-store"))
+}
+
+func TestSetHeaderKeepsProtectedSitesRevalidated(t *testing.T) {
+	serve := func(o *Options, filePath string, status int) string {
+		resp := httptest.NewRecorder()
+		ctx := context.New(resp, httptest.NewRequest(http.MethodGet, "https://example.com"+filePath, nil))
+		// set by the authorization of protected sites
+		ctx.RespWriter.Header().Set(headerCacheControl, "private, no-cache")
+		ctx.StatusCode = status
+		o.TargetPath = filePath
+		o.setHeader(ctx, http.Header{gitea.ContentTypeHeader: {"text/javascript"}})
+		return resp.Header().Get(headerCacheControl)
+	}
+
+	o := &Options{CacheControl: config.CacheControlConfig{
+		Default:   "public, max-age=600",
+		Immutable: "public, max-age=31536000, immutable",
+		Errors:    "public, max-age=60",
+		Rules:     []config.CacheControlRule{{Path: "*.js", Value: "public, max-age=3600"}},
+	}}
+	assert.EqualValues(t, "private, no-cache", serve(o, "/app.js", http.StatusOK))
+	assert.EqualValues(t, "private, no-cache", serve(o, "/app.3f9a1c2b.js", http.StatusOK))
+	assert.EqualValues(t, "private, no-cache", serve(o, "/missing.js", http.StatusNotFound))
+
+	// the site may relax the policy of its own files, but they are never shared or immutable
+	o.ApplySiteConfig(&SiteConfig{CacheControl: []config.CacheControlRule{
+		{Path: "/assets/**", Value: "public, max-age=31536000, immutable"},
+	}})
+	assert.EqualValues(t, "private, max-age=31536000", serve(o, "/assets/app.3f9a1c2b.js", http.StatusOK))
+	assert.EqualValues(t, "private, no-cache", serve(o, "/app.js", http.StatusOK))
+}
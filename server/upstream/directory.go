@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/html"
+	"codeberg.org/codeberg/pages/server/context"
+	"codeberg.org/codeberg/pages/server/gitea"
+)
+
+// serveDirectoryListing lists the files of the requested directory.
+// It returns false if the directory doesn't exist.
+func (o *Options) serveDirectoryListing(ctx *context.Context, giteaClient *gitea.Client) bool {
+	entries, err := giteaClient.GiteaListDirectory(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.contentPath(o.TargetPath))
+	if err != nil {
+		if !errors.Is(err, gitea.ErrorNotFound) {
+			log.Error().Err(err).Msgf("could not list directory %q", o.TargetPath)
+		}
+		return false
+	}
+
+	if o.redirectTrailingSlash(ctx) {
+		return true
+	}
+
+	dir := strings.TrimSuffix(ctx.Path(), "/") + "/"
+	listing := html.DirectoryListing{Path: dir}
+	if dir != "/" {
+		parent := path.Dir(strings.TrimSuffix(dir, "/"))
+		if parent != "/" {
+			parent += "/"
+		}
+		listing.Parent = escapePath(o.directoryPath(parent))
+	}
+	for _, entry := range entries {
+		// hidden files like .domains or .pages.toml are not part of the site
+		if strings.HasPrefix(entry.Name, ".") {
+			continue
+		}
+		href := dir + entry.Name
+		if entry.IsDir {
+			href = o.directoryPath(href + "/")
+		}
+		listing.Entries = append(listing.Entries, html.DirectoryEntry{
+			Name:         entry.Name,
+			Href:         escapePath(href),
+			IsDir:        entry.IsDir,
+			Size:         entry.Size,
+			LastModified: entry.LastModified,
+		})
+	}
+
+	ctx.RespWriter.Header().Set(headerLastModified, o.BranchTimestamp.In(time.UTC).Format(time.RFC1123))
+	html.ReturnDirectoryListing(ctx, listing)
+	return true
+}
+
+// redirectTrailingSlash redirects directories to the path the trailing slash policy of the site prefers.
+func (o *Options) redirectTrailingSlash(ctx *context.Context) bool {
+	switch o.trailingSlash() {
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(ctx.Path(), "/") {
+			ctx.Redirect(ctx.Path()+"/", http.StatusTemporaryRedirect)
+			return true
+		}
+	case TrailingSlashRemove:
+		if strings.HasSuffix(ctx.Path(), "/") && ctx.Path() != "/" {
+			ctx.Redirect(strings.TrimSuffix(ctx.Path(), "/"), http.StatusTemporaryRedirect)
+			return true
+		}
+	}
+	return false
+}
+
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
@@ -19,7 +19,7 @@ const canonicalDomainConfig = ".domains"
 // CheckCanonicalDomain returns the canonical domain specified in the repo (using the `.domains` file).
 func (o *Options) CheckCanonicalDomain(giteaClient *gitea.Client, actualDomain, mainDomainSuffix string, canonicalDomainCache cache.ICache) (domain string, valid bool) {
 	// Check if this request is cached.
-	if cachedValue, ok := canonicalDomainCache.Get(o.TargetOwner + "/" + o.TargetRepo + "/" + o.TargetBranch); ok {
+	if cachedValue, ok := canonicalDomainCache.Get(o.cacheKey()); ok {
 		domains := cachedValue.([]string)
 		for _, domain := range domains {
 			if domain == actualDomain {
@@ -62,7 +62,7 @@ func (o *Options) CheckCanonicalDomain(giteaClient *gitea.Client, actualDomain,
 	}
 
 	// Add result to cache.
-	_ = canonicalDomainCache.Set(o.TargetOwner+"/"+o.TargetRepo+"/"+o.TargetBranch, domains, canonicalDomainCacheTimeout)
+	_ = canonicalDomainCache.Set(o.cacheKey(), domains, canonicalDomainCacheTimeout)
 
 	// Return the first domain from the list and return if any of the domains
 	// matched the requested domain.
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"codeberg.org/codeberg/pages/server/context"
@@ -25,4 +26,29 @@ func (o *Options) setHeader(ctx *context.Context, header http.Header) {
 		ctx.RespWriter.Header().Set(gitea.ContentTypeHeader, mime)
 	}
 	ctx.RespWriter.Header().Set(headerLastModified, o.BranchTimestamp.In(time.UTC).Format(time.RFC1123))
+
+	contentType := ctx.RespWriter.Header().Get(gitea.ContentTypeHeader)
+	cacheControl := o.CacheControl.Errors
+	if ctx.StatusCode == http.StatusOK {
+		cacheControl = o.cacheControl(o.TargetPath, contentType)
+	}
+	// protected sites must not end up in shared caches and are revalidated, so that lost access applies right away,
+	// only a rule of the site itself may relax that
+	if protected := ctx.RespWriter.Header().Get(headerCacheControl); strings.HasPrefix(protected, "private") {
+		cacheControl = protected
+		if value, ok := o.siteCacheControl(o.TargetPath, contentType); ok && ctx.StatusCode == http.StatusOK {
+			cacheControl = privateCacheControl(value)
+		}
+	}
+	if cacheControl != "" {
+		ctx.RespWriter.Header().Set(headerCacheControl, cacheControl)
+	}
+	if o.siteConfig != nil && len(o.siteConfig.CorsOrigins) > 0 && ctx.Req != nil {
+		// the allowed origin depends on the origin of the request
+		ctx.RespWriter.Header().Add(headerVary, headerOrigin)
+		if origin := o.allowedCorsOrigin(ctx.Req.Header.Get(headerOrigin)); origin != "" {
+			ctx.RespWriter.Header().Set(headerAccessControlAllowOrigin, origin)
+			ctx.RespWriter.Header().Set(headerAccessControlAllowMethods, http.MethodGet+", "+http.MethodHead)
+		}
+	}
 }
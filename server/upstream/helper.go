@@ -3,6 +3,7 @@ package upstream
 import (
 	"errors"
 	"fmt"
+	"path"
 
 	"github.com/rs/zerolog/log"
 
@@ -43,5 +44,14 @@ func (o *Options) GetBranchTimestamp(giteaClient *gitea.Client) (bool, error) {
 }
 
 func (o *Options) ContentWebLink(giteaClient *gitea.Client) string {
-	return giteaClient.ContentWebLink(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.TargetPath) + "; rel=\"canonical\""
+	return giteaClient.ContentWebLink(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.contentPath(o.TargetPath)) + "; rel=\"canonical\""
+}
+
+// contentPath returns the path of a file in the repository, p is relative to the publish directory.
+func (o *Options) contentPath(p string) string {
+	if o.PublishDir == "" {
+		return p
+	}
+	// don't allow escaping from the publish directory
+	return path.Join(o.PublishDir, path.Clean("/"+p))
 }
@@ -25,14 +25,18 @@ const redirectsConfig = "_redirects"
 // getRedirects returns redirects specified in the _redirects file.
 func (o *Options) getRedirects(giteaClient *gitea.Client, redirectsCache cache.ICache) []Redirect {
 	var redirects []Redirect
-	cacheKey := o.TargetOwner + "/" + o.TargetRepo + "/" + o.TargetBranch
+	cacheKey := o.cacheKey()
+	if o.PublishDir != "" {
+		// colons can't be part of branch names
+		cacheKey += ":" + o.PublishDir
+	}
 
 	// Check for cached redirects
 	if cachedValue, ok := redirectsCache.Get(cacheKey); ok {
 		redirects = cachedValue.([]Redirect)
 	} else {
 		// Get _redirects file and parse
-		body, err := giteaClient.GiteaRawContent(o.TargetOwner, o.TargetRepo, o.TargetBranch, redirectsConfig)
+		body, err := giteaClient.GiteaRawContent(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.contentPath(redirectsConfig))
 		if err == nil {
 			for _, line := range strings.Split(string(body), "\n") {
 				redirectArr := strings.Fields(line)
@@ -0,0 +1,230 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog/log"
+
+	"codeberg.org/codeberg/pages/config"
+	"codeberg.org/codeberg/pages/server/cache"
+	"codeberg.org/codeberg/pages/server/gitea"
+)
+
+// siteConfigCacheTimeout specifies the timeout for the site config cache.
+var siteConfigCacheTimeout = 15 * time.Minute
+
+const siteConfigFile = ".pages.toml"
+
+// Trailing slash policies of directories with an index page
+const (
+	// TrailingSlashAdd redirects to the directory with a trailing slash, so that relative links work
+	TrailingSlashAdd = "add"
+	// TrailingSlashRemove redirects to the directory without a trailing slash
+	TrailingSlashRemove = "remove"
+	// TrailingSlashIgnore serves the index page with and without trailing slash
+	TrailingSlashIgnore = "ignore"
+)
+
+// SiteConfig is the optional configuration of a site in the `.pages.toml` file of its repository.
+type SiteConfig struct {
+	// PublishDir is served as site root instead of the root of the repository
+	PublishDir string `toml:"publishDir"`
+	// TrailingSlash is the policy for directories, TrailingSlashAdd if empty
+	TrailingSlash string `toml:"trailingSlash"`
+	// CleanURLs serves "/page" from "page.html" and redirects "/page.html" to "/page"
+	CleanURLs bool `toml:"cleanURLs"`
+	// IndexPages are tried in order for directories, upstreamIndexPages if empty
+	IndexPages []string `toml:"indexPages"`
+	// NotFoundPages are tried in order if a file doesn't exist, upstreamNotFoundPages if empty
+	NotFoundPages []string `toml:"notFoundPages"`
+	// CorsOrigins may fetch the site with CORS requests, "*" allows all origins
+	CorsOrigins []string `toml:"corsOrigins"`
+	// CacheMaxAge in seconds replaces the default max-age of the Cache-Control header
+	CacheMaxAge *int `toml:"cacheMaxAge"`
+	// CacheControl rules are tried before the ones of the operator
+	CacheControl []config.CacheControlRule `toml:"cacheControl"`
+	// DirectoryListing allows to list directories without index page
+	DirectoryListing bool `toml:"directoryListing"`
+
+	// auth protects the site if the repository has a `.pages-auth` file
+	auth *siteAuth
+}
+
+// GetSiteConfig returns the site config of the target repo, or the defaults if there is none.
+func (o *Options) GetSiteConfig(giteaClient *gitea.Client, siteConfigCache cache.ICache) *SiteConfig {
+	if o.TargetBranch == "" {
+		// the config of the default branch is used
+		if _, err := o.GetBranchTimestamp(giteaClient); err != nil {
+			if errors.Is(err, gitea.ErrorNotFound) {
+				return &SiteConfig{}
+			}
+			// the site might be protected, so nothing is served until the config can be read
+			return &SiteConfig{auth: &siteAuth{}}
+		}
+	}
+
+	cacheKey := o.cacheKey()
+	if cachedValue, ok := siteConfigCache.Get(cacheKey); ok {
+		return cachedValue.(*SiteConfig)
+	}
+
+	siteConfig := &SiteConfig{}
+	body, err := giteaClient.GiteaRawContent(o.TargetOwner, o.TargetRepo, o.TargetBranch, siteConfigFile)
+	if err != nil && !errors.Is(err, gitea.ErrorNotFound) {
+		log.Error().Err(err).Msgf("could not read %s of %s/%s", siteConfigFile, o.TargetOwner, o.TargetRepo)
+	} else if err == nil {
+		siteConfig, err = parseSiteConfig(body)
+		if err != nil {
+			log.Info().Err(err).Msgf("could not parse %s of %s/%s", siteConfigFile, o.TargetOwner, o.TargetRepo)
+		}
+	}
+
+	auth, err := o.readSiteAuth(giteaClient)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not read %s of %s/%s", siteAuthFile, o.TargetOwner, o.TargetRepo)
+		// the site might be protected, so nothing is served until the file can be read, which is tried again soon
+		siteConfig.auth = &siteAuth{}
+		return siteConfig
+	}
+	siteConfig.auth = auth
+
+	_ = siteConfigCache.Set(cacheKey, siteConfig, siteConfigCacheTimeout)
+	return siteConfig
+}
+
+// readSiteAuth reads the `.pages-auth` file of the default branch, which protects all branches of the repository.
+// Otherwise any visitor could read a protected site from another branch that has no such file.
+// Other branches can only be protected by a file of their own if the default branch doesn't protect the site.
+// It returns nil if the site is not protected.
+func (o *Options) readSiteAuth(giteaClient *gitea.Client) (*siteAuth, error) {
+	defaultBranch, err := giteaClient.GiteaGetRepoDefaultBranch(o.TargetOwner, o.TargetRepo)
+	if err != nil {
+		return nil, err
+	}
+	branches := []string{defaultBranch}
+	if o.TargetBranch != defaultBranch {
+		branches = append(branches, o.TargetBranch)
+	}
+
+	for _, branch := range branches {
+		body, err := giteaClient.GiteaRawContent(o.TargetOwner, o.TargetRepo, branch, siteAuthFile)
+		if errors.Is(err, gitea.ErrorNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return parseSiteAuth(body), nil
+	}
+	return nil, nil
+}
+
+// parseSiteConfig parses a `.pages.toml` file, the defaults are returned along with any error.
+func parseSiteConfig(body []byte) (*SiteConfig, error) {
+	var siteConfig SiteConfig
+	if err := toml.Unmarshal(body, &siteConfig); err != nil {
+		return &SiteConfig{}, err
+	}
+	// the publish directory can never point outside of the repository
+	siteConfig.PublishDir = strings.TrimPrefix(path.Clean("/"+siteConfig.PublishDir), "/")
+
+	switch siteConfig.TrailingSlash {
+	case "":
+		siteConfig.TrailingSlash = TrailingSlashAdd
+	case TrailingSlashAdd, TrailingSlashRemove, TrailingSlashIgnore:
+	default:
+		return &SiteConfig{}, fmt.Errorf("unknown trailingSlash %q, valid options are %q, %q and %q",
+			siteConfig.TrailingSlash, TrailingSlashAdd, TrailingSlashRemove, TrailingSlashIgnore)
+	}
+	for _, pages := range [][]string{siteConfig.IndexPages, siteConfig.NotFoundPages} {
+		for _, page := range pages {
+			if page == "" || strings.Contains(page, "/") {
+				return &SiteConfig{}, fmt.Errorf("invalid page %q, only file names are allowed", page)
+			}
+		}
+	}
+	if siteConfig.CacheMaxAge != nil && *siteConfig.CacheMaxAge < 0 {
+		return &SiteConfig{}, fmt.Errorf("cacheMaxAge must not be negative, got %d", *siteConfig.CacheMaxAge)
+	}
+	if err := ValidateCacheControlRules(siteConfig.CacheControl); err != nil {
+		return &SiteConfig{}, err
+	}
+	return &siteConfig, nil
+}
+
+// ApplySiteConfig sets the options the site config of the target repo specifies.
+func (o *Options) ApplySiteConfig(siteConfig *SiteConfig) {
+	o.siteConfig = siteConfig
+	// the publish directory of a custom domain's DNS record wins
+	if o.PublishDir == "" {
+		o.PublishDir = siteConfig.PublishDir
+	}
+}
+
+func (o *Options) trailingSlash() string {
+	if o.siteConfig == nil || o.siteConfig.TrailingSlash == "" {
+		return TrailingSlashAdd
+	}
+	return o.siteConfig.TrailingSlash
+}
+
+func (o *Options) cleanURLs() bool {
+	return o.siteConfig != nil && o.siteConfig.CleanURLs
+}
+
+func (o *Options) directoryListing() bool {
+	return o.AllowDirectoryListing && o.siteConfig != nil && o.siteConfig.DirectoryListing
+}
+
+func (o *Options) indexPages() []string {
+	if o.siteConfig == nil || len(o.siteConfig.IndexPages) == 0 {
+		return upstreamIndexPages
+	}
+	return o.siteConfig.IndexPages
+}
+
+func (o *Options) notFoundPages() []string {
+	if o.siteConfig == nil || len(o.siteConfig.NotFoundPages) == 0 {
+		return upstreamNotFoundPages
+	}
+	return o.siteConfig.NotFoundPages
+}
+
+// indexPageOf returns the name of the index page urlPath requests explicitly, or "" if it doesn't.
+func (o *Options) indexPageOf(urlPath string) string {
+	for _, indexPage := range o.indexPages() {
+		if strings.HasSuffix(urlPath, "/"+indexPage) {
+			return indexPage
+		}
+	}
+	return ""
+}
+
+// directoryPath returns the path a directory is served at, dir has a trailing slash.
+func (o *Options) directoryPath(dir string) string {
+	if o.trailingSlash() == TrailingSlashRemove && dir != "/" {
+		return strings.TrimSuffix(dir, "/")
+	}
+	return dir
+}
+
+// allowedCorsOrigin returns the value of the Access-Control-Allow-Origin header for the origin of a request,
+// or "" if the site doesn't allow it.
+func (o *Options) allowedCorsOrigin(origin string) string {
+	if o.siteConfig == nil {
+		return ""
+	}
+	for _, allowed := range o.siteConfig.CorsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codeberg.org/codeberg/pages/config"
+)
+
+func TestParseSiteConfig(t *testing.T) {
+	siteConfig, err := parseSiteConfig([]byte(`publishDir = "/public/"`))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "public", siteConfig.PublishDir)
+
+	// the publish directory stays inside the repository
+	siteConfig, err = parseSiteConfig([]byte(`publishDir = "../../other/repo"`))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "other/repo", siteConfig.PublishDir)
+
+	siteConfig, err = parseSiteConfig([]byte(`publishDir = `))
+	assert.Error(t, err)
+	assert.EqualValues(t, &SiteConfig{}, siteConfig)
+}
+
+func TestContentPath(t *testing.T) {
+	o := &Options{}
+	assert.EqualValues(t, "/index.html", o.contentPath("/index.html"))
+
+	o.PublishDir = "docs"
+	assert.EqualValues(t, "docs/index.html", o.contentPath("/index.html"))
+	assert.EqualValues(t, "docs/_redirects", o.contentPath("_redirects"))
+	assert.EqualValues(t, "docs/secret", o.contentPath("../secret"))
+
+	// the publish directory of DNS records wins
+	o.ApplySiteConfig(&SiteConfig{PublishDir: "public"})
+	assert.EqualValues(t, "docs", o.PublishDir)
+	o = &Options{}
+	o.ApplySiteConfig(&SiteConfig{PublishDir: "public"})
+	assert.EqualValues(t, "public", o.PublishDir)
+}
+
+func TestParseSiteConfigOptions(t *testing.T) {
+	siteConfig, err := parseSiteConfig([]byte(`
+trailingSlash = "remove"
+cleanURLs = true
+indexPages = ["index.htm", "README.html"]
+notFoundPages = ["not-found.html"]
+corsOrigins = ["https://example.com"]
+cacheMaxAge = 60
+directoryListing = true
+
+[[cacheControl]]
+path = "/assets/**"
+value = "public, max-age=31536000, immutable"
+`))
+	assert.NoError(t, err)
+	assert.EqualValues(t, TrailingSlashRemove, siteConfig.TrailingSlash)
+	assert.True(t, siteConfig.CleanURLs)
+	assert.EqualValues(t, []string{"index.htm", "README.html"}, siteConfig.IndexPages)
+	assert.EqualValues(t, []string{"not-found.html"}, siteConfig.NotFoundPages)
+	assert.EqualValues(t, []string{"https://example.com"}, siteConfig.CorsOrigins)
+	assert.EqualValues(t, 60, *siteConfig.CacheMaxAge)
+	assert.True(t, siteConfig.DirectoryListing)
+	assert.EqualValues(t, []config.CacheControlRule{{Path: "/assets/**", Value: "public, max-age=31536000, immutable"}}, siteConfig.CacheControl)
+
+	siteConfig, err = parseSiteConfig([]byte(``))
+	assert.NoError(t, err)
+	assert.EqualValues(t, TrailingSlashAdd, siteConfig.TrailingSlash)
+	assert.Nil(t, siteConfig.CacheMaxAge)
+
+	for _, invalid := range []string{
+		`trailingSlash = "sometimes"`,
+		`indexPages = ["docs/index.html"]`,
+		`notFoundPages = [""]`,
+		`cacheMaxAge = -1`,
+		"[[cacheControl]]\npath = \"/assets/**\"",
+		"[[cacheControl]]\nvalue = \"no-store\"",
+		"[[cacheControl]]\npath = \"/[assets\"\nvalue = \"no-store\"",
+	} {
+		siteConfig, err = parseSiteConfig([]byte(invalid))
+		assert.Error(t, err, invalid)
+		assert.EqualValues(t, &SiteConfig{}, siteConfig, invalid)
+	}
+}
+
+func TestSiteConfigDefaults(t *testing.T) {
+	o := &Options{}
+	assert.EqualValues(t, TrailingSlashAdd, o.trailingSlash())
+	assert.False(t, o.cleanURLs())
+	assert.EqualValues(t, upstreamIndexPages, o.indexPages())
+	assert.EqualValues(t, upstreamNotFoundPages, o.notFoundPages())
+	assert.EqualValues(t, "index.html", o.indexPageOf("/docs/index.html"))
+	assert.EqualValues(t, "", o.indexPageOf("/docs/other.html"))
+	assert.EqualValues(t, "", o.allowedCorsOrigin("https://example.com"))
+
+	o.ApplySiteConfig(&SiteConfig{
+		TrailingSlash: TrailingSlashRemove,
+		IndexPages:    []string{"index.htm"},
+		CorsOrigins:   []string{"https://example.com/"},
+	})
+	assert.EqualValues(t, "index.htm", o.indexPageOf("/docs/index.htm"))
+	assert.EqualValues(t, "", o.indexPageOf("/docs/index.html"))
+	assert.EqualValues(t, "/docs", o.directoryPath("/docs/"))
+	assert.EqualValues(t, "/", o.directoryPath("/"))
+	assert.EqualValues(t, "https://example.com", o.allowedCorsOrigin("https://example.com"))
+	assert.EqualValues(t, "", o.allowedCorsOrigin("https://example.org"))
+
+	o.ApplySiteConfig(&SiteConfig{CorsOrigins: []string{"*"}})
+	assert.EqualValues(t, "*", o.allowedCorsOrigin("https://example.org"))
+}
+
+func TestDirectoryListingNeedsOperatorAndSite(t *testing.T) {
+	o := &Options{}
+	o.ApplySiteConfig(&SiteConfig{DirectoryListing: true})
+	assert.False(t, o.directoryListing())
+
+	o.AllowDirectoryListing = true
+	assert.True(t, o.directoryListing())
+
+	o.ApplySiteConfig(&SiteConfig{})
+	assert.False(t, o.directoryListing())
+}
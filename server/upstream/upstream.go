@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"codeberg.org/codeberg/pages/config"
 	"codeberg.org/codeberg/pages/html"
 	"codeberg.org/codeberg/pages/server/cache"
 	"codeberg.org/codeberg/pages/server/context"
@@ -19,6 +21,12 @@ import (
 const (
 	headerLastModified    = "Last-Modified"
 	headerIfModifiedSince = "If-Modified-Since"
+	headerCacheControl    = "Cache-Control"
+	headerOrigin          = "Origin"
+	headerVary            = "Vary"
+
+	headerAccessControlAllowOrigin  = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods = "Access-Control-Allow-Methods"
 
 	rawMime = "text/plain; charset=utf-8"
 )
@@ -39,19 +47,32 @@ type Options struct {
 	TargetRepo   string
 	TargetBranch string
 	TargetPath   string
+	// PublishDir is the directory of the repository that is served instead of its root
+	PublishDir string
 
 	// Used for debugging purposes.
 	Host string
 
 	TryIndexPages   bool
 	BranchTimestamp time.Time
+	// AllowDirectoryListing is the operator switch, the site config has to enable directory listings as well
+	AllowDirectoryListing bool
+	// CacheControl is the policy of the operator, sites can add their own rules
+	CacheControl config.CacheControlConfig
 	// internal
-	appendTrailingSlash bool
-	redirectIfExists    string
+	servesIndexPage  bool
+	redirectIfExists string
+	siteConfig       *SiteConfig
 
 	ServeRaw bool
 }
 
+// cacheKey identifies the branch in the caches of canonical domains, site configs and redirects.
+// Owners and repos are case-insensitive in Gitea, so they are lowercased to get a single entry per branch.
+func (o *Options) cacheKey() string {
+	return strings.ToLower(o.TargetOwner+"/"+o.TargetRepo) + "/" + o.TargetBranch
+}
+
 // Upstream requests a file from the Gitea API at GiteaRoot and writes it to the request context.
 func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redirectsCache cache.ICache) bool {
 	log := log.With().Strs("upstream", []string{o.TargetOwner, o.TargetRepo, o.TargetBranch, o.TargetPath}).Logger()
@@ -63,6 +84,12 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 		return true
 	}
 
+	// the password hashes of protected sites are never served
+	if path.Clean("/"+o.contentPath(o.TargetPath)) == "/"+siteAuthFile {
+		html.ReturnErrorPage(ctx, "", http.StatusNotFound)
+		return true
+	}
+
 	// Check if the branch exists and when it was modified
 	if o.BranchTimestamp.IsZero() {
 		branchExist, err := o.GetBranchTimestamp(giteaClient)
@@ -97,7 +124,7 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 
 	log.Debug().Msg("Preparing")
 
-	reader, header, statusCode, err := giteaClient.ServeRawContent(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.TargetPath)
+	reader, header, statusCode, err := giteaClient.ServeRawContent(o.TargetOwner, o.TargetRepo, o.TargetBranch, o.contentPath(o.TargetPath))
 	if reader != nil {
 		defer reader.Close()
 	}
@@ -119,8 +146,8 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 			// copy the o struct & try if an index page exists
 			optionsForIndexPages := *o
 			optionsForIndexPages.TryIndexPages = false
-			optionsForIndexPages.appendTrailingSlash = true
-			for _, indexPage := range upstreamIndexPages {
+			optionsForIndexPages.servesIndexPage = true
+			for _, indexPage := range o.indexPages() {
 				optionsForIndexPages.TargetPath = strings.TrimSuffix(o.TargetPath, "/") + "/" + indexPage
 				if optionsForIndexPages.Upstream(ctx, giteaClient, redirectsCache) {
 					return true
@@ -128,12 +155,20 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 			}
 			log.Trace().Msg("try html file with path name")
 			// compatibility fix for GitHub Pages (/example → /example.html)
-			optionsForIndexPages.appendTrailingSlash = false
-			optionsForIndexPages.redirectIfExists = strings.TrimSuffix(ctx.Path(), "/") + ".html"
-			optionsForIndexPages.TargetPath = o.TargetPath + ".html"
+			optionsForIndexPages.servesIndexPage = false
+			if !o.cleanURLs() {
+				optionsForIndexPages.redirectIfExists = strings.TrimSuffix(ctx.Path(), "/") + ".html"
+			}
+			optionsForIndexPages.TargetPath = strings.TrimSuffix(o.TargetPath, "/") + ".html"
 			if optionsForIndexPages.Upstream(ctx, giteaClient, redirectsCache) {
 				return true
 			}
+			if o.directoryListing() {
+				log.Trace().Msg("try directory listing")
+				if o.serveDirectoryListing(ctx, giteaClient) {
+					return true
+				}
+			}
 		}
 
 		log.Trace().Msg("not found")
@@ -144,8 +179,8 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 			// copy the o struct & try if a not found page exists
 			optionsForNotFoundPages := *o
 			optionsForNotFoundPages.TryIndexPages = false
-			optionsForNotFoundPages.appendTrailingSlash = false
-			for _, notFoundPage := range upstreamNotFoundPages {
+			optionsForNotFoundPages.servesIndexPage = false
+			for _, notFoundPage := range o.notFoundPages() {
 				optionsForNotFoundPages.TargetPath = "/" + notFoundPage
 				if optionsForNotFoundPages.Upstream(ctx, giteaClient, redirectsCache) {
 					return true
@@ -180,16 +215,21 @@ func (o *Options) Upstream(ctx *context.Context, giteaClient *gitea.Client, redi
 		return true
 	}
 
-	// Append trailing slash if missing (for index files), and redirect to fix filenames in general
-	// o.appendTrailingSlash is only true when looking for index pages
-	if o.appendTrailingSlash && !strings.HasSuffix(ctx.Path(), "/") {
-		log.Trace().Msg("append trailing slash and redirect")
-		ctx.Redirect(ctx.Path()+"/", http.StatusTemporaryRedirect)
+	// Append or remove the trailing slash of directories as configured, and redirect to fix filenames in general
+	// o.servesIndexPage is only true when looking for index pages
+	if o.servesIndexPage && o.redirectTrailingSlash(ctx) {
+		log.Trace().Msg("fix trailing slash and redirect")
+		return true
+	}
+	if indexPage := o.indexPageOf(ctx.Path()); indexPage != "" && !o.ServeRaw {
+		log.Trace().Msgf("remove %s from path and redirect", indexPage)
+		ctx.Redirect(o.directoryPath(strings.TrimSuffix(ctx.Path(), indexPage)), http.StatusTemporaryRedirect)
 		return true
 	}
-	if strings.HasSuffix(ctx.Path(), "/index.html") && !o.ServeRaw {
-		log.Trace().Msg("remove index.html from path and redirect")
-		ctx.Redirect(strings.TrimSuffix(ctx.Path(), "index.html"), http.StatusTemporaryRedirect)
+	// o.TryIndexPages is only true if the requested path exists as it is
+	if o.cleanURLs() && o.TryIndexPages && strings.HasSuffix(ctx.Path(), ".html") {
+		log.Trace().Msg("remove .html from path and redirect")
+		ctx.Redirect(strings.TrimSuffix(ctx.Path(), ".html"), http.StatusTemporaryRedirect)
 		return true
 	}
 	if o.redirectIfExists != "" {
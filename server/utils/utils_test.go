@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -67,3 +68,21 @@ func testURIPathNormalize(t *testing.T, requestURI, expectedPath string) {
 		t.Fatalf("Unexpected path %q. Expected %q. requestURI=%q", cleanedPath, expectedPath, requestURI)
 	}
 }
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"192.0.2.0/24", " 198.51.100.1 ", "", "2001:db8::1"})
+	assert.NoError(t, err)
+	assert.Len(t, networks, 3)
+
+	assert.True(t, ContainsIP(networks, net.ParseIP("192.0.2.42")))
+	assert.True(t, ContainsIP(networks, net.ParseIP("198.51.100.1")))
+	assert.False(t, ContainsIP(networks, net.ParseIP("198.51.100.2")))
+	assert.True(t, ContainsIP(networks, net.ParseIP("2001:db8::1")))
+	// IPv4 addresses mapped to IPv6 match as well
+	assert.True(t, ContainsIP(networks, net.ParseIP("::ffff:192.0.2.1")))
+
+	_, err = ParseNetworks([]string{"example.com"})
+	assert.Error(t, err)
+	_, err = ParseNetworks([]string{"192.0.2.0/33"})
+	assert.Error(t, err)
+}